@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// Mechanisms that trigger protection, as recorded in the audit trail.
+const (
+	AuditMechanismLabel      = "label"
+	AuditMechanismAnnotation = "annotation"
+	AuditMechanismPolicy     = "policy"
+)
+
+// AnnotationMechanism is set on generated Usages to the mechanism that
+// triggered protection if the Input enables the AuditTrail.
+const AnnotationMechanism = "protection.fn.crossplane.io/mechanism"
+
+// auditMechanisms maps the reasons generated by this function to the
+// mechanism that triggers them. All other reasons are triggered by a policy.
+var auditMechanisms = map[string]string{
+	ProtectionReasonLabel:            AuditMechanismLabel,
+	ProtectionReasonLabelValue:       AuditMechanismLabel,
+	ProtectionReasonAllSelectors:     AuditMechanismLabel,
+	ProtectionReasonSharedGroup:      AuditMechanismLabel,
+	ProtectionReasonConnectionSource: AuditMechanismLabel,
+	ProtectionReasonAnnotationRegex:  AuditMechanismAnnotation,
+	ProtectionReasonPriority:         AuditMechanismAnnotation,
+	ProtectionReasonExternalName:     AuditMechanismAnnotation,
+}
+
+// AuditMechanism returns the mechanism that triggers the supplied reason
+// generated by this function.
+func AuditMechanism(reason string) string {
+	if m, ok := auditMechanisms[reason]; ok {
+		return m
+	}
+	return AuditMechanismPolicy
+}
+
+// RecordMechanism records the mechanism that triggered the supplied reason on
+// a generated Usage, if the Input enables the AuditTrail. The reason is the
+// one generated by this function, before it is resolved or decorated.
+func RecordMechanism(usage map[string]any, reason string, in *v1beta1.Input) {
+	if !in.AuditTrail {
+		return
+	}
+	_ = unstructured.SetNestedField(usage, AuditMechanism(reason), "metadata", "annotations", AnnotationMechanism)
+}
+
+// AuditEntry summarizes the protection applied by a run.
+type AuditEntry struct {
+	Timestamp string            `json:"timestamp"`
+	Composite string            `json:"composite"`
+	Protected []AuditedResource `json:"protected"`
+}
+
+// AuditedResource records a protected resource in the audit trail.
+type AuditedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Reason     string `json:"reason"`
+
+	// Mechanism is what triggered protection: a label or an annotation on the
+	// resource, or a policy configured in the Input.
+	Mechanism string `json:"mechanism"`
+}
+
+// BuildAudit derives the audit entry of a run from its protection decisions.
+func BuildAudit(now time.Time, observedComposite *resource.Composite, d Decisions) AuditEntry {
+	e := AuditEntry{Timestamp: now.UTC().Format(time.RFC3339), Protected: []AuditedResource{}}
+	if observedComposite != nil && observedComposite.Resource != nil {
+		e.Composite = observedComposite.Resource.GetName()
+	}
+	for _, p := range d.Protected {
+		e.Protected = append(e.Protected, AuditedResource{
+			APIVersion: p.APIVersion,
+			Kind:       p.Kind,
+			Name:       p.Name,
+			Namespace:  p.Namespace,
+			Reason:     p.Reason,
+			Mechanism:  auditMechanism(p),
+		})
+	}
+	return e
+}
+
+// auditMechanism returns the mechanism that triggered a protection decision.
+// Decisions that do not record one were not generated by this function, e.g.
+// by a Usage supplied in the pipeline, and are attributed to a policy.
+func auditMechanism(d Decision) string {
+	if d.Mechanism == "" {
+		return AuditMechanismPolicy
+	}
+	return d.Mechanism
+}
+
+// Message encodes the audit entry as a result message.
+func (e AuditEntry) Message() (string, error) {
+	b, err := json.Marshal(e)
+	return string(b), err
+}
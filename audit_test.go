@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestBuildAudit(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	xr := &resource.Composite{Resource: composite.New()}
+	xr.Resource.SetName("my-xr")
+
+	type args struct {
+		d Decisions
+	}
+	type want struct {
+		e AuditEntry
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NothingProtected": {
+			reason: "An audit entry without protected resources should be recorded",
+			args:   args{d: Decisions{}},
+			want:   want{e: AuditEntry{Timestamp: "2026-10-15T12:00:00Z", Composite: "my-xr", Protected: []AuditedResource{}}},
+		},
+		"Mechanisms": {
+			reason: "Each protected resource should record the mechanism that triggered its protection, whatever its reason",
+			args: args{d: Decisions{Protected: []Decision{
+				{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket", Name: "my-bucket", Reason: "team-a: [REDACTED]", Usage: "bucket-my-bucket", Mechanism: AuditMechanismLabel},
+				{APIVersion: "rds.aws.upbound.io/v1beta1", Kind: "Instance", Name: "my-db", Reason: "owned by the payments team", Usage: "instance-my-db", Mechanism: AuditMechanismAnnotation},
+				{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Reason: ProtectionReasonCompositeChildResource, Mechanism: AuditMechanismPolicy},
+			}}},
+			want: want{e: AuditEntry{Timestamp: "2026-10-15T12:00:00Z", Composite: "my-xr", Protected: []AuditedResource{
+				{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket", Name: "my-bucket", Reason: "team-a: [REDACTED]", Mechanism: AuditMechanismLabel},
+				{APIVersion: "rds.aws.upbound.io/v1beta1", Kind: "Instance", Name: "my-db", Reason: "owned by the payments team", Mechanism: AuditMechanismAnnotation},
+				{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Reason: ProtectionReasonCompositeChildResource, Mechanism: AuditMechanismPolicy},
+			}}},
+		},
+		"NoMechanismRecorded": {
+			reason: "A protection that does not record its mechanism should be attributed to a policy, even if its reason mentions a label",
+			args: args{d: Decisions{Protected: []Decision{
+				{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket", Name: "my-bucket", Reason: ProtectionReasonLabel, Usage: "bucket-my-bucket"},
+			}}},
+			want: want{e: AuditEntry{Timestamp: "2026-10-15T12:00:00Z", Composite: "my-xr", Protected: []AuditedResource{
+				{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket", Name: "my-bucket", Reason: ProtectionReasonLabel, Mechanism: AuditMechanismPolicy},
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := BuildAudit(now, xr, tc.args.d)
+
+			if diff := cmp.Diff(tc.want.e, got); diff != "" {
+				t.Errorf("%s\nBuildAudit(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionAuditTrail(t *testing.T) {
+	req := &fnv1.RunFunctionRequest{
+		Input: resource.MustStructJSON(`{
+			"apiVersion": "protection.fn.crossplane.io/v1beta1",
+			"kind": "Input",
+			"auditTrail": true
+		}`),
+		Observed: &fnv1.State{
+			Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestXR",
+				"metadata": {"name": "my-xr"}
+			}`)},
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {
+						"name": "my-bucket",
+						"labels": {"protection.fn.crossplane.io/block-deletion": "true"}
+					}
+				}`)},
+			},
+		},
+		Desired: &fnv1.State{
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed"
+				}`)},
+			},
+		},
+	}
+
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return now }}
+	rsp, err := f.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): unexpected error: %v", err)
+	}
+	if len(rsp.GetResults()) != 1 {
+		t.Fatalf("f.RunFunction(...): want 1 audit result, got %d", len(rsp.GetResults()))
+	}
+	if diff := cmp.Diff(fnv1.Severity_SEVERITY_NORMAL, rsp.GetResults()[0].GetSeverity()); diff != "" {
+		t.Errorf("f.RunFunction(...): -want severity, +got severity:\n%s", diff)
+	}
+
+	got := AuditEntry{}
+	if err := json.Unmarshal([]byte(rsp.GetResults()[0].GetMessage()), &got); err != nil {
+		t.Fatalf("f.RunFunction(...): cannot decode audit result: %v", err)
+	}
+	want := AuditEntry{
+		Timestamp: "2026-10-15T12:00:00Z",
+		Composite: "my-xr",
+		Protected: []AuditedResource{
+			{APIVersion: "test.crossplane.io/v1", Kind: "TestComposed", Name: "my-bucket", Reason: ProtectionReasonLabel, Mechanism: AuditMechanismLabel},
+			{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Reason: ProtectionReasonCompositeChildResource, Mechanism: AuditMechanismPolicy},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("f.RunFunction(...): -want audit, +got audit:\n%s", diff)
+	}
+}
+
+func TestRunFunctionAuditTrailAnnotationMode(t *testing.T) {
+	req := &fnv1.RunFunctionRequest{
+		Input: resource.MustStructJSON(`{
+			"apiVersion": "protection.fn.crossplane.io/v1beta1",
+			"kind": "Input",
+			"auditTrail": true,
+			"protectionMode": "annotation",
+			"reasonPrefix": "[PROD]",
+			"matchAnnotationRegex": [{"key": "environment", "pattern": "^prod"}]
+		}`),
+		Observed: &fnv1.State{
+			Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestXR",
+				"metadata": {"name": "my-xr"}
+			}`)},
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {
+						"name": "my-bucket",
+						"annotations": {"environment": "production"}
+					}
+				}`)},
+			},
+		},
+		Desired: &fnv1.State{
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {"name": "my-bucket"}
+				}`)},
+			},
+		},
+	}
+
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return now }}
+	rsp, err := f.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): unexpected error: %v", err)
+	}
+
+	var got []AuditedResource
+	for _, r := range rsp.GetResults() {
+		e := AuditEntry{}
+		if err := json.Unmarshal([]byte(r.GetMessage()), &e); err == nil && e.Timestamp != "" {
+			got = e.Protected
+		}
+	}
+	want := []AuditedResource{
+		{APIVersion: "test.crossplane.io/v1", Kind: "TestComposed", Name: "my-bucket", Reason: "[PROD] " + ProtectionReasonAnnotationRegex, Mechanism: AuditMechanismAnnotation},
+		{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Reason: "[PROD] " + ProtectionReasonCompositeChildResource, Mechanism: AuditMechanismPolicy},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("f.RunFunction(...): -want audit, +got audit:\n%s", diff)
+	}
+}
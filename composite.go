@@ -0,0 +1,246 @@
+package main
+
+import (
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+const (
+	// LabelCompositionRevision is the label identifying the composition
+	// revision that produced a composed resource.
+	LabelCompositionRevision = "crossplane.io/composition-revision"
+	// AnnotationClaimNamespace is set on generated Usages to the namespace of
+	// the composite's claim.
+	AnnotationClaimNamespace = "protection.fn.crossplane.io/claim-namespace"
+)
+
+// ProtectionSpec is the protection a composite declares at the Input's
+// ProtectionSpecPath.
+type ProtectionSpec struct {
+	// Enabled protects the composite and its composed resources.
+	Enabled bool `json:"enabled"`
+	// Reason replaces the reason of the generated Usages.
+	Reason string `json:"reason,omitempty"`
+	// Kinds limits protection to composed resources of these kinds.
+	Kinds []v1beta1.GroupKind `json:"kinds,omitempty"`
+}
+
+// compositeString returns the first non-empty string found at the supplied
+// Crossplane v2 path, falling back to the legacy v1 path. Crossplane v2 moved
+// Crossplane machinery fields under spec.crossplane.
+func compositeString(xr *resource.Composite, field string) string {
+	if xr == nil || xr.Resource == nil {
+		return ""
+	}
+	for _, path := range []string{"spec.crossplane." + field, "spec." + field} {
+		if v, err := xr.Resource.GetString(path); err == nil && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// CompositionRevision returns the name of the composite's current
+// composition revision.
+func CompositionRevision(xr *resource.Composite) string {
+	return compositeString(xr, "compositionRevisionRef.name")
+}
+
+// CompositionName returns the name of the composite's composition.
+func CompositionName(xr *resource.Composite) string {
+	return compositeString(xr, "compositionRef.name")
+}
+
+// ClaimNamespace returns the namespace of the composite's claim. It returns an
+// empty string for composites without a claim.
+func ClaimNamespace(xr *resource.Composite) string {
+	return compositeString(xr, "claimRef.namespace")
+}
+
+// InheritedLabels returns the composite's labels with the supplied keys. The
+// label marking resources managed by this function is never inherited.
+func InheritedLabels(xr *resource.Composite, keys []string) map[string]string {
+	if xr == nil || xr.Resource == nil {
+		return nil
+	}
+	labels := xr.Resource.GetLabels()
+	inherited := map[string]string{}
+	for _, k := range keys {
+		if v, ok := labels[k]; ok && k != LabelManagedBy {
+			inherited[k] = v
+		}
+	}
+	return inherited
+}
+
+// SelectByLabels replaces the resourceRef of the supplied Usage with a
+// resourceSelector matching the listed labels of the protected resource. It
+// returns false and leaves the Usage unchanged if the resource lacks one of
+// the labels.
+func SelectByLabels(usage map[string]any, u *unstructured.Unstructured, keys []string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	labels := u.GetLabels()
+	matchLabels := make(map[string]any, len(keys))
+	for _, k := range keys {
+		v, ok := labels[k]
+		if !ok {
+			return false
+		}
+		matchLabels[k] = v
+	}
+	unstructured.RemoveNestedField(usage, "spec", "of", "resourceRef")
+	_ = unstructured.SetNestedField(usage, map[string]any{"matchLabels": matchLabels}, "spec", "of", "resourceSelector")
+	return true
+}
+
+// resolveRef resolves a reference at the supplied field path. The reference may
+// either be a name or an object with a name and an optional namespace.
+func resolveRef(p *fieldpath.Paved, path string) (name, namespace string, ok bool) {
+	if name, err := p.GetString(path); err == nil {
+		return name, "", true
+	}
+	name, err := p.GetString(path + ".name")
+	if err != nil {
+		return "", "", false
+	}
+	namespace, _ = p.GetString(path + ".namespace")
+	return name, namespace, true
+}
+
+// ReferencedBy returns the observed composed resources referenced by the
+// composite at any of the supplied field paths. A referenced value may be a
+// resource name or an object with a name and an optional namespace.
+func ReferencedBy(xr *resource.Composite, observed map[resource.Name]resource.ObservedComposed, paths []string) map[resource.Name]bool {
+	if xr == nil || xr.Resource == nil || len(paths) == 0 {
+		return nil
+	}
+	type ref struct{ name, namespace string }
+	var refs []ref
+	p := fieldpath.Pave(xr.Resource.Object)
+	for _, path := range paths {
+		if name, ns, ok := resolveRef(p, path); ok {
+			refs = append(refs, ref{name: name, namespace: ns})
+		}
+	}
+	referenced := map[resource.Name]bool{}
+	for name, o := range observed {
+		if o.Resource == nil {
+			continue
+		}
+		for _, r := range refs {
+			if r.name != "" && o.Resource.GetName() == r.name && (r.namespace == "" || o.Resource.GetNamespace() == r.namespace) {
+				referenced[name] = true
+			}
+		}
+	}
+	return referenced
+}
+
+// ReferencedObjects returns the Secrets and ConfigMaps referenced by the
+// composite at the supplied paths. References without a namespace default to
+// the composite's namespace. References that cannot be resolved to a namespaced
+// object are ignored.
+func ReferencedObjects(xr *resource.Composite, paths []v1beta1.ObjectRefPath) []*unstructured.Unstructured {
+	if xr == nil || xr.Resource == nil {
+		return nil
+	}
+	var objs []*unstructured.Unstructured
+	p := fieldpath.Pave(xr.Resource.Object)
+	for _, path := range paths {
+		name, ns, ok := resolveRef(p, path.FieldPath)
+		if !ok || name == "" {
+			continue
+		}
+		if ns == "" {
+			ns = xr.Resource.GetNamespace()
+		}
+		if ns == "" {
+			continue
+		}
+		kind := path.Kind
+		if kind == "" {
+			kind = "Secret"
+		}
+		o := &unstructured.Unstructured{}
+		o.SetAPIVersion("v1")
+		o.SetKind(kind)
+		o.SetName(name)
+		o.SetNamespace(ns)
+		objs = append(objs, o)
+	}
+	return objs
+}
+
+// HasComposed returns true if any observed composed resource exists that was
+// not generated by this function.
+func HasComposed(observed map[resource.Name]resource.ObservedComposed) bool {
+	for _, o := range observed {
+		if o.Resource != nil && !IsManaged(&o.Resource.Unstructured) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompositeReady returns true if the composite reports a Ready condition with
+// status True.
+func CompositeReady(xr *resource.Composite) bool {
+	if xr == nil || xr.Resource == nil {
+		return false
+	}
+	return xr.Resource.GetCondition(xpv1.TypeReady).Status == "True"
+}
+
+// ProtectionApplied returns true if any observed composed resource was
+// generated by this function, i.e. a previous run already applied protection.
+func ProtectionApplied(observed map[resource.Name]resource.ObservedComposed) bool {
+	for _, o := range observed {
+		if o.Resource != nil && IsManaged(&o.Resource.Unstructured) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompositeProtectionSpec returns the protection declared by the composite at
+// the supplied field path. It returns false if the path is not set or does not
+// hold a valid protection spec.
+func CompositeProtectionSpec(xr *resource.Composite, path string) (ProtectionSpec, bool) {
+	if xr == nil || xr.Resource == nil || path == "" {
+		return ProtectionSpec{}, false
+	}
+	s := ProtectionSpec{}
+	if err := fieldpath.Pave(xr.Resource.Object).GetValueInto(path, &s); err != nil {
+		return ProtectionSpec{}, false
+	}
+	return s, true
+}
+
+// Protects returns true if the spec enables protection and the supplied
+// resource is of one of its kinds. Resources of any kind are protected if the
+// spec has no kinds.
+func (s ProtectionSpec) Protects(u *unstructured.Unstructured) bool {
+	return s.Enabled && (len(s.Kinds) == 0 || MatchesGroupKind(u, s.Kinds))
+}
+
+// UsageReason returns the reason of Usages generated because of the spec.
+func (s ProtectionSpec) UsageReason() string {
+	if s.Reason == "" {
+		return ProtectionReasonSpec
+	}
+	return s.Reason
+}
+
+// CompositeMissing returns true if the request has no observed composite and
+// no required resources. Operations have no composite, but supply the
+// resources to protect as required resources.
+func CompositeMissing(req *fnv1.RunFunctionRequest) bool {
+	return req.GetObserved().GetComposite().GetResource() == nil && len(req.GetRequiredResources()) == 0
+}
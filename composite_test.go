@@ -0,0 +1,485 @@
+package main
+
+import (
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestCompositionRevision(t *testing.T) {
+	xr := func(spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{"spec": spec}
+		return c
+	}
+
+	type args struct {
+		xr *resource.Composite
+	}
+	type want struct {
+		revision string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NilComposite": {
+			reason: "A nil composite should not have a revision",
+			args:   args{xr: nil},
+			want:   want{revision: ""},
+		},
+		"V2Composite": {
+			reason: "The revision should be read from spec.crossplane for a v2 composite",
+			args: args{xr: xr(map[string]any{"crossplane": map[string]any{
+				"compositionRevisionRef": map[string]any{"name": "rev-v2"},
+			}})},
+			want: want{revision: "rev-v2"},
+		},
+		"LegacyComposite": {
+			reason: "The revision should be read from spec for a legacy composite",
+			args:   args{xr: xr(map[string]any{"compositionRevisionRef": map[string]any{"name": "rev-v1"}})},
+			want:   want{revision: "rev-v1"},
+		},
+		"NoRevision": {
+			reason: "A composite without a revision reference should not have a revision",
+			args:   args{xr: xr(map[string]any{})},
+			want:   want{revision: ""},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CompositionRevision(tc.args.xr)
+
+			if diff := cmp.Diff(tc.want.revision, got); diff != "" {
+				t.Errorf("%s\nCompositionRevision(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClaimNamespace(t *testing.T) {
+	xr := func(spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{"spec": spec}
+		return c
+	}
+
+	type args struct {
+		xr *resource.Composite
+	}
+	type want struct {
+		namespace string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoClaim": {
+			reason: "A claimless composite should have no claim namespace",
+			args:   args{xr: xr(map[string]any{})},
+			want:   want{},
+		},
+		"LegacyClaimRef": {
+			reason: "The claim namespace should be read from spec.claimRef",
+			args:   args{xr: xr(map[string]any{"claimRef": map[string]any{"name": "my-claim", "namespace": "team-a"}})},
+			want:   want{namespace: "team-a"},
+		},
+		"CrossplaneClaimRef": {
+			reason: "The claim namespace should be read from spec.crossplane.claimRef",
+			args:   args{xr: xr(map[string]any{"crossplane": map[string]any{"claimRef": map[string]any{"name": "my-claim", "namespace": "team-b"}}})},
+			want:   want{namespace: "team-b"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ClaimNamespace(tc.args.xr)
+
+			if diff := cmp.Diff(tc.want.namespace, got); diff != "" {
+				t.Errorf("%s\nClaimNamespace(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCompositionName(t *testing.T) {
+	xr := func(spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{"spec": spec}
+		return c
+	}
+
+	type args struct {
+		xr *resource.Composite
+	}
+	type want struct {
+		name string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"V2Composite": {
+			reason: "The composition should be read from spec.crossplane for a v2 composite",
+			args: args{xr: xr(map[string]any{"crossplane": map[string]any{
+				"compositionRef": map[string]any{"name": "databases"},
+			}})},
+			want: want{name: "databases"},
+		},
+		"LegacyComposite": {
+			reason: "The composition should be read from spec for a legacy composite",
+			args:   args{xr: xr(map[string]any{"compositionRef": map[string]any{"name": "databases"}})},
+			want:   want{name: "databases"},
+		},
+		"NoComposition": {
+			reason: "A composite without a composition reference should not have a composition",
+			args:   args{xr: xr(map[string]any{})},
+			want:   want{name: ""},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CompositionName(tc.args.xr)
+
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("%s\nCompositionName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInheritedLabels(t *testing.T) {
+	xr := &resource.Composite{Resource: composite.New()}
+	xr.Resource.SetLabels(map[string]string{"team": "a", "env": "prod", LabelManagedBy: "someone"})
+
+	type args struct {
+		keys []string
+	}
+	type want struct {
+		labels map[string]string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoKeys": {
+			reason: "No labels should be inherited without keys",
+			want:   want{labels: map[string]string{}},
+		},
+		"Keys": {
+			reason: "Only labels with the supplied keys should be inherited",
+			args:   args{keys: []string{"team", "missing"}},
+			want:   want{labels: map[string]string{"team": "a"}},
+		},
+		"ManagedBy": {
+			reason: "The managed-by label should never be inherited",
+			args:   args{keys: []string{LabelManagedBy}},
+			want:   want{labels: map[string]string{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := InheritedLabels(xr, tc.args.keys)
+
+			if diff := cmp.Diff(tc.want.labels, got); diff != "" {
+				t.Errorf("%s\nInheritedLabels(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReferencedBy(t *testing.T) {
+	xr := func(spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{"spec": spec}
+		return c
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"vpc": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "my-vpc"},
+		}}}},
+		"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "my-db", "namespace": "team-a"},
+		}}}},
+	}
+
+	type args struct {
+		xr    *resource.Composite
+		paths []string
+	}
+	type want struct {
+		referenced map[resource.Name]bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPaths": {
+			reason: "Nothing should be referenced without ref paths",
+			args:   args{xr: xr(map[string]any{"vpcName": "my-vpc"})},
+			want:   want{referenced: nil},
+		},
+		"ScalarRef": {
+			reason: "A resource should be referenced by a scalar name",
+			args:   args{xr: xr(map[string]any{"vpcName": "my-vpc"}), paths: []string{"spec.vpcName"}},
+			want:   want{referenced: map[resource.Name]bool{"vpc": true}},
+		},
+		"StructuredRef": {
+			reason: "A resource should be referenced by an object with a name and namespace",
+			args: args{
+				xr:    xr(map[string]any{"dbRef": map[string]any{"name": "my-db", "namespace": "team-a"}}),
+				paths: []string{"spec.dbRef"},
+			},
+			want: want{referenced: map[resource.Name]bool{"db": true}},
+		},
+		"StructuredRefOtherNamespace": {
+			reason: "A resource in another namespace should not be referenced",
+			args: args{
+				xr:    xr(map[string]any{"dbRef": map[string]any{"name": "my-db", "namespace": "team-b"}}),
+				paths: []string{"spec.dbRef"},
+			},
+			want: want{referenced: map[resource.Name]bool{}},
+		},
+		"MissingPath": {
+			reason: "A path that is not set should not reference anything",
+			args:   args{xr: xr(map[string]any{}), paths: []string{"spec.vpcName"}},
+			want:   want{referenced: map[resource.Name]bool{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ReferencedBy(tc.args.xr, observed, tc.args.paths)
+
+			if diff := cmp.Diff(tc.want.referenced, got); diff != "" {
+				t.Errorf("%s\nReferencedBy(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReferencedObjects(t *testing.T) {
+	xr := func(namespace string, spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{"metadata": map[string]any{"namespace": namespace}, "spec": spec}
+		return c
+	}
+	obj := func(kind, name, namespace string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]any{"name": name, "namespace": namespace},
+		}}
+	}
+
+	type args struct {
+		xr    *resource.Composite
+		paths []v1beta1.ObjectRefPath
+	}
+	type want struct {
+		objs []*unstructured.Unstructured
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Secret": {
+			reason: "A referenced Secret should be resolved, defaulting the kind to Secret",
+			args: args{
+				xr:    xr("", map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "db-conn", "namespace": "team-a"}}),
+				paths: []v1beta1.ObjectRefPath{{FieldPath: "spec.writeConnectionSecretToRef"}},
+			},
+			want: want{objs: []*unstructured.Unstructured{obj("Secret", "db-conn", "team-a")}},
+		},
+		"ConfigMap": {
+			reason: "A referenced ConfigMap should default to the composite's namespace",
+			args: args{
+				xr:    xr("team-a", map[string]any{"settings": "db-settings"}),
+				paths: []v1beta1.ObjectRefPath{{FieldPath: "spec.settings", Kind: "ConfigMap"}},
+			},
+			want: want{objs: []*unstructured.Unstructured{obj("ConfigMap", "db-settings", "team-a")}},
+		},
+		"NoNamespace": {
+			reason: "A reference without a namespace from a cluster-scoped composite should be ignored",
+			args: args{
+				xr:    xr("", map[string]any{"settings": "db-settings"}),
+				paths: []v1beta1.ObjectRefPath{{FieldPath: "spec.settings", Kind: "ConfigMap"}},
+			},
+			want: want{objs: nil},
+		},
+		"MissingPath": {
+			reason: "A path that is not set should be ignored",
+			args: args{
+				xr:    xr("team-a", map[string]any{}),
+				paths: []v1beta1.ObjectRefPath{{FieldPath: "spec.writeConnectionSecretToRef"}},
+			},
+			want: want{objs: nil},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ReferencedObjects(tc.args.xr, tc.args.paths)
+
+			if diff := cmp.Diff(tc.want.objs, got); diff != "" {
+				t.Errorf("%s\nReferencedObjects(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCompositeProtectionSpec(t *testing.T) {
+	xr := func(spec map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr"},
+			"spec":       spec,
+		}
+		return c
+	}
+
+	type args struct {
+		xr   *resource.Composite
+		path string
+	}
+	type want struct {
+		spec ProtectionSpec
+		ok   bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Declared": {
+			reason: "The protection declared in the composite's spec should be returned",
+			args: args{
+				xr: xr(map[string]any{"protection": map[string]any{
+					"enabled": true,
+					"reason":  "protected by the platform team",
+					"kinds":   []any{map[string]any{"group": "s3.aws.upbound.io", "kind": "Bucket"}},
+				}}),
+				path: "spec.protection",
+			},
+			want: want{
+				spec: ProtectionSpec{
+					Enabled: true,
+					Reason:  "protected by the platform team",
+					Kinds:   []v1beta1.GroupKind{{Group: "s3.aws.upbound.io", Kind: "Bucket"}},
+				},
+				ok: true,
+			},
+		},
+		"NotDeclared": {
+			reason: "A composite without protection in its spec should not declare protection",
+			args:   args{xr: xr(map[string]any{}), path: "spec.protection"},
+			want:   want{},
+		},
+		"NoPath": {
+			reason: "Protection should not be read without a path",
+			args: args{
+				xr: xr(map[string]any{"protection": map[string]any{"enabled": true}}),
+			},
+			want: want{},
+		},
+		"Invalid": {
+			reason: "A value that is not a protection spec should be ignored",
+			args: args{
+				xr:   xr(map[string]any{"protection": map[string]any{"enabled": "yes"}}),
+				path: "spec.protection",
+			},
+			want: want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec, ok := CompositeProtectionSpec(tc.args.xr, tc.args.path)
+
+			if diff := cmp.Diff(tc.want.spec, spec); diff != "" {
+				t.Errorf("%s\nCompositeProtectionSpec(...): -want spec, +got spec:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nCompositeProtectionSpec(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHasComposed(t *testing.T) {
+	obj := func(labels map[string]any) resource.ObservedComposed {
+		return resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-bucket", "labels": labels},
+		}}}}
+	}
+
+	type args struct {
+		observed map[resource.Name]resource.ObservedComposed
+	}
+	type want struct {
+		has bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Empty": {
+			reason: "A composite without composed resources should not have composed resources",
+			args:   args{},
+			want:   want{has: false},
+		},
+		"OnlyGenerated": {
+			reason: "Resources generated by this function should not count as composed resources",
+			args: args{observed: map[resource.Name]resource.ObservedComposed{
+				"xr-my-xr-usage": obj(map[string]any{LabelManagedBy: ManagedByValue}),
+			}},
+			want: want{has: false},
+		},
+		"Composed": {
+			reason: "A composite with a composed resource should have composed resources",
+			args: args{observed: map[resource.Name]resource.ObservedComposed{
+				"xr-my-xr-usage": obj(map[string]any{LabelManagedBy: ManagedByValue}),
+				"bucket":         obj(nil),
+			}},
+			want: want{has: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := HasComposed(tc.args.observed)
+
+			if diff := cmp.Diff(tc.want.has, got); diff != "" {
+				t.Errorf("%s\nHasComposed(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
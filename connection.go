@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// LabelConnectionSource marks a composed resource whose connection details
+// feed the connection secret published by the composite.
+const LabelConnectionSource = "protection.fn.crossplane.io/connection-source"
+
+// IsConnectionSource returns true if the resource is labeled as a source of the
+// composite's connection details.
+func IsConnectionSource(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	return u.GetLabels()[LabelConnectionSource] == "true"
+}
+
+// ProtectConnectionSources creates Usages for the observed composed resources
+// labeled as sources of the composite's connection details that are not
+// protected by one of the supplied Usages yet. It is only called once the
+// composite is protected, so its connection details can't be removed from
+// under it.
+func ProtectConnectionSources(desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, usages map[resource.Name]*resource.DesiredComposed, in *v1beta1.Input, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, error) {
+	dc := map[resource.Name]*resource.DesiredComposed{}
+	for name, desired := range desiredComposed {
+		observed, ok := observedComposed[name]
+		if !ok || observed.Resource == nil || observed.Resource.GetName() == "" {
+			continue
+		}
+		if _, ok := usages[name+"-usage"]; ok {
+			continue
+		}
+		if IsManaged(&desired.Resource.Unstructured) || IsManaged(&observed.Resource.Unstructured) {
+			continue
+		}
+		if !IsConnectionSource(&desired.Resource.Unstructured) && !IsConnectionSource(&observed.Resource.Unstructured) {
+			continue
+		}
+		if in.EnableV1Mode && observed.Resource.GetNamespace() != "" {
+			return nil, errors.Errorf(V1ModeError, observed.Resource.GetKind(), observed.Resource.GetName(), observed.Resource.GetNamespace())
+		}
+		usage := GenerateUsage(&observed.Resource.Unstructured, ProtectionReasonConnectionSource, in, redact)
+		dc[name+"-usage"] = &resource.DesiredComposed{Resource: asComposed(usage)}
+	}
+	return dc, nil
+}
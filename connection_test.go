@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestProtectConnectionSources(t *testing.T) {
+	type args struct {
+		desired  map[resource.Name]*resource.DesiredComposed
+		observed map[resource.Name]resource.ObservedComposed
+		usages   map[resource.Name]*resource.DesiredComposed
+		in       *v1beta1.Input
+	}
+	type want struct {
+		reasons map[resource.Name]string
+		err     error
+	}
+
+	bucket := func(name string, labels map[string]any) *composed.Unstructured {
+		return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "s3.aws.upbound.io/v1beta1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"name": name, "labels": labels},
+		}}}
+	}
+	source := map[string]any{LabelConnectionSource: "true"}
+	desired := func(labels map[string]any) map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: bucket("", labels)},
+			"logs":   {Resource: bucket("", nil)},
+		}
+	}
+	observed := func(name string) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"bucket": {Resource: bucket(name, nil)},
+			"logs":   {Resource: bucket("my-logs", nil)},
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Source": {
+			reason: "A resource labeled as a connection source should be protected",
+			args:   args{desired: desired(source), observed: observed("my-bucket"), in: &v1beta1.Input{}},
+			want:   want{reasons: map[resource.Name]string{"bucket-usage": ProtectionReasonConnectionSource}},
+		},
+		"NoSources": {
+			reason: "Resources that are not labeled as connection sources should not be protected",
+			args:   args{desired: desired(nil), observed: observed("my-bucket"), in: &v1beta1.Input{}},
+			want:   want{reasons: map[resource.Name]string{}},
+		},
+		"AlreadyProtected": {
+			reason: "A connection source that is already protected should keep its Usage",
+			args: args{
+				desired:  desired(source),
+				observed: observed("my-bucket"),
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage": {Resource: asComposed(GenerateUsage(&bucket("my-bucket", nil).Unstructured, ProtectionReasonLabel, &v1beta1.Input{}, nil))},
+				},
+				in: &v1beta1.Input{},
+			},
+			want: want{reasons: map[resource.Name]string{}},
+		},
+		"NotObserved": {
+			reason: "A connection source that does not exist yet should not be protected",
+			args:   args{desired: desired(source), observed: map[resource.Name]resource.ObservedComposed{}, in: &v1beta1.Input{}},
+			want:   want{reasons: map[resource.Name]string{}},
+		},
+		"Unnamed": {
+			reason: "A connection source without a name should not be protected",
+			args:   args{desired: desired(source), observed: observed(""), in: &v1beta1.Input{}},
+			want:   want{reasons: map[resource.Name]string{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ProtectConnectionSources(tc.args.desired, tc.args.observed, tc.args.usages, tc.args.in, nil)
+
+			reasons := map[resource.Name]string{}
+			for n, u := range got {
+				reasons[n], _ = u.Resource.GetString("spec.reason")
+			}
+			if diff := cmp.Diff(tc.want.reasons, reasons); diff != "" {
+				t.Errorf("%s\nProtectConnectionSources(...): -want reasons, +got reasons:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("%s\nProtectConnectionSources(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
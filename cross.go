@@ -0,0 +1,109 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// RequirementsNameCrossComposition prefixes the names of the requirements
+// used to look up CrossCompositionRefs by external name.
+const RequirementsNameCrossComposition = "protection.fn.crossplane.io/cross-composition-"
+
+// CrossCompositionRequirement returns the name of the requirement of the
+// CrossCompositionRef at the supplied index.
+func CrossCompositionRequirement(i int) string {
+	return RequirementsNameCrossComposition + strconv.Itoa(i)
+}
+
+// CrossCompositionSelector returns a selector for the resources a
+// CrossCompositionRef is looked up in. Resources of a namespaced composite can
+// only depend on resources in the same namespace.
+func CrossCompositionSelector(ref v1beta1.CrossCompositionRef, namespace string) *fnv1.ResourceSelector {
+	rs := &fnv1.ResourceSelector{
+		ApiVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: ref.MatchLabels}},
+	}
+	if namespace != "" {
+		rs.Namespace = &namespace
+	}
+	return rs
+}
+
+// CrossCompositionTarget returns the name of the resource the supplied
+// composed resource references via the CrossCompositionRef. Resources
+// identified by external name are looked up in the supplied required
+// resources. References to another namespace are ignored, because a Usage
+// cannot span namespaces.
+func CrossCompositionTarget(u *unstructured.Unstructured, ref v1beta1.CrossCompositionRef, required []resource.Required) (string, bool) {
+	if u == nil || u.Object == nil {
+		return "", false
+	}
+	v, ns, ok := resolveRef(fieldpath.Pave(u.Object), ref.FieldPath)
+	if !ok || v == "" || (ns != "" && ns != u.GetNamespace()) {
+		return "", false
+	}
+	if ref.IdentifyBy != v1beta1.CrossCompositionIdentityExternalName {
+		return v, true
+	}
+	for _, r := range required {
+		if r.Resource != nil && meta.GetExternalName(r.Resource) == v && r.Resource.GetNamespace() == u.GetNamespace() {
+			return r.Resource.GetName(), true
+		}
+	}
+	return "", false
+}
+
+// ProtectCrossComposition generates a Usage for every resource of another
+// composition that a protected composed resource references via the
+// CrossCompositionRefs. The Usage's spec.by points to the protected composed
+// resource, so the referenced resource cannot be deleted while it exists.
+func ProtectCrossComposition(composedUsages map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, required map[string][]resource.Required, in *v1beta1.Input, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, error) {
+	dc := map[resource.Name]*resource.DesiredComposed{}
+	if !in.CrossCompositionBy {
+		return dc, nil
+	}
+	for name, o := range observedComposed {
+		if _, ok := composedUsages[name+"-usage"]; !ok || o.Resource == nil {
+			continue
+		}
+		by := &o.Resource.Unstructured
+		for i, ref := range in.CrossCompositionRefs {
+			target, ok := CrossCompositionTarget(by, ref, required[CrossCompositionRequirement(i)])
+			if !ok {
+				continue
+			}
+			of := &unstructured.Unstructured{}
+			of.SetAPIVersion(ref.APIVersion)
+			of.SetKind(ref.Kind)
+			of.SetName(target)
+			of.SetNamespace(by.GetNamespace())
+			// Validate that v1 mode is not used with namespaced resources
+			if in.EnableV1Mode && of.GetNamespace() != "" {
+				return nil, errors.Errorf(V1ModeError, of.GetKind(), of.GetName(), of.GetNamespace())
+			}
+
+			usage := GenerateUsage(of, ProtectionReasonCrossComposition, in, redact)
+			_ = unstructured.SetNestedField(usage, map[string]any{
+				"apiVersion":  by.GetAPIVersion(),
+				"kind":        by.GetKind(),
+				"resourceRef": map[string]any{"name": by.GetName()},
+			}, "spec", "by")
+			// The referenced resource may be protected by its own composition,
+			// so the Usage is named after both resources.
+			_ = unstructured.SetNestedField(usage, GenerateName(strings.ToLower(ref.Kind+"-"+target+"-by-"+by.GetKind()+"-"+by.GetName()), UsageNameSuffix), "metadata", "name")
+			dc[resource.Name(string(name)+"-cross-composition-"+strconv.Itoa(i)+"-usage")] = &resource.DesiredComposed{Resource: asComposed(usage)}
+		}
+	}
+	return dc, nil
+}
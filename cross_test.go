@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestCrossCompositionTarget(t *testing.T) {
+	type args struct {
+		u        *unstructured.Unstructured
+		ref      v1beta1.CrossCompositionRef
+		required []resource.Required
+	}
+	type want struct {
+		name string
+		ok   bool
+	}
+
+	subnet := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "ec2.aws.upbound.io/v1beta1",
+		"kind":       "Subnet",
+		"metadata":   map[string]any{"name": "my-subnet"},
+		"spec": map[string]any{"forProvider": map[string]any{
+			"vpcId":    "vpc-0abc",
+			"vpcIdRef": map[string]any{"name": "shared-vpc"},
+		}},
+	}}
+	vpc := func(name, externalName string) resource.Required {
+		return resource.Required{Resource: &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "ec2.aws.upbound.io/v1beta1",
+			"kind":       "VPC",
+			"metadata": map[string]any{
+				"name":        name,
+				"annotations": map[string]any{"crossplane.io/external-name": externalName},
+			},
+		}}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ByName": {
+			reason: "A resource referenced by name should be identified by the value of the field",
+			args: args{
+				u:   subnet,
+				ref: v1beta1.CrossCompositionRef{FieldPath: "spec.forProvider.vpcIdRef", APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC"},
+			},
+			want: want{name: "shared-vpc", ok: true},
+		},
+		"ByExternalName": {
+			reason: "A resource referenced by external name should be looked up in the required resources",
+			args: args{
+				u:        subnet,
+				ref:      v1beta1.CrossCompositionRef{FieldPath: "spec.forProvider.vpcId", APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", IdentifyBy: v1beta1.CrossCompositionIdentityExternalName},
+				required: []resource.Required{vpc("other-vpc", "vpc-0def"), vpc("shared-vpc", "vpc-0abc")},
+			},
+			want: want{name: "shared-vpc", ok: true},
+		},
+		"ExternalNameNotFound": {
+			reason: "A resource referenced by an external name that is not required should not be identified",
+			args: args{
+				u:        subnet,
+				ref:      v1beta1.CrossCompositionRef{FieldPath: "spec.forProvider.vpcId", APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", IdentifyBy: v1beta1.CrossCompositionIdentityExternalName},
+				required: []resource.Required{vpc("other-vpc", "vpc-0def")},
+			},
+			want: want{ok: false},
+		},
+		"MissingField": {
+			reason: "A resource without the referencing field should not reference anything",
+			args: args{
+				u:   subnet,
+				ref: v1beta1.CrossCompositionRef{FieldPath: "spec.forProvider.subnetIdRef", APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "Subnet"},
+			},
+			want: want{ok: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := CrossCompositionTarget(tc.args.u, tc.args.ref, tc.args.required)
+
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("%s\nCrossCompositionTarget(...): -want name, +got name:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nCrossCompositionTarget(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectCrossComposition(t *testing.T) {
+	type args struct {
+		composedUsages map[resource.Name]*resource.DesiredComposed
+		observed       map[resource.Name]resource.ObservedComposed
+		in             *v1beta1.Input
+	}
+	type want struct {
+		usages map[resource.Name]*resource.DesiredComposed
+		err    string
+	}
+
+	cd := func(obj map[string]any) *composed.Unstructured {
+		return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"subnet": {Resource: cd(map[string]any{
+			"apiVersion": "ec2.aws.upbound.io/v1beta1",
+			"kind":       "Subnet",
+			"metadata":   map[string]any{"name": "my-subnet"},
+			"spec": map[string]any{"forProvider": map[string]any{
+				"vpcIdRef": map[string]any{"name": "shared-vpc"},
+			}},
+		})},
+	}
+	protected := map[resource.Name]*resource.DesiredComposed{"subnet-usage": {Resource: cd(map[string]any{})}}
+	refs := []v1beta1.CrossCompositionRef{{FieldPath: "spec.forProvider.vpcIdRef", APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC"}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ProtectedResource": {
+			reason: "A resource referenced by a protected composed resource should be protected by a Usage with spec.by",
+			args: args{
+				composedUsages: protected,
+				observed:       observed,
+				in:             &v1beta1.Input{CrossCompositionBy: true, CrossCompositionRefs: refs},
+			},
+			want: want{usages: map[resource.Name]*resource.DesiredComposed{
+				"subnet-cross-composition-0-usage": {Resource: cd(map[string]any{
+					"apiVersion": ProtectionGroupVersion,
+					"kind":       "ClusterUsage",
+					"metadata": map[string]any{
+						"name":   GenerateName("vpc-shared-vpc-by-subnet-my-subnet", UsageNameSuffix),
+						"labels": map[string]any{LabelManagedBy: ManagedByValue},
+					},
+					"spec": map[string]any{
+						"of": map[string]any{
+							"apiVersion":  "ec2.aws.upbound.io/v1beta1",
+							"kind":        "VPC",
+							"resourceRef": map[string]any{"name": "shared-vpc"},
+						},
+						"by": map[string]any{
+							"apiVersion":  "ec2.aws.upbound.io/v1beta1",
+							"kind":        "Subnet",
+							"resourceRef": map[string]any{"name": "my-subnet"},
+						},
+						"reason": ProtectionReasonCrossComposition,
+					},
+				})},
+			}},
+		},
+		"UnprotectedResource": {
+			reason: "A resource referenced by an unprotected composed resource should not be protected",
+			args: args{
+				composedUsages: map[resource.Name]*resource.DesiredComposed{},
+				observed:       observed,
+				in:             &v1beta1.Input{CrossCompositionBy: true, CrossCompositionRefs: refs},
+			},
+			want: want{usages: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"V1ModeNamespaced": {
+			reason: "A namespaced referenced resource should return an error in v1 mode",
+			args: args{
+				composedUsages: protected,
+				observed: map[resource.Name]resource.ObservedComposed{
+					"subnet": {Resource: cd(map[string]any{
+						"apiVersion": "ec2.aws.upbound.io/v1beta1",
+						"kind":       "Subnet",
+						"metadata":   map[string]any{"name": "my-subnet", "namespace": "team-a"},
+						"spec": map[string]any{"forProvider": map[string]any{
+							"vpcIdRef": map[string]any{"name": "shared-vpc"},
+						}},
+					})},
+				},
+				in: &v1beta1.Input{CrossCompositionBy: true, CrossCompositionRefs: refs, EnableV1Mode: true},
+			},
+			want: want{err: fmt.Sprintf(V1ModeError, "VPC", "shared-vpc", "team-a")},
+		},
+		"Disabled": {
+			reason: "No Usages should be generated unless crossCompositionBy is set",
+			args: args{
+				composedUsages: protected,
+				observed:       observed,
+				in:             &v1beta1.Input{CrossCompositionRefs: refs},
+			},
+			want: want{usages: map[resource.Name]*resource.DesiredComposed{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ProtectCrossComposition(tc.args.composedUsages, tc.args.observed, nil, tc.args.in, nil)
+
+			if diff := cmp.Diff(tc.want.usages, got); diff != "" {
+				t.Errorf("%s\nProtectCrossComposition(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, gotErr); diff != "" {
+				t.Errorf("%s\nProtectCrossComposition(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// ContextKeyDecisions is the context key the function's protection decisions
+// are written to, for use by later pipeline steps.
+const ContextKeyDecisions = "protection.fn.crossplane.io/decisions"
+
+// Decisions are the protection decisions of a run.
+type Decisions struct {
+	// Protected resources, ordered by kind, namespace and name.
+	Protected []Decision `json:"protected"`
+
+	// Skipped resources that requested protection but were not protected.
+	Skipped []SkippedDecision `json:"skipped,omitempty"`
+}
+
+// Decision records that a resource is protected.
+type Decision struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Reason     string `json:"reason"`
+
+	// Usage is the name of the Usage protecting the resource. It is empty if
+	// the resource is protected by an annotation.
+	Usage string `json:"usage,omitempty"`
+
+	// Mechanism is the AnnotationMechanism recorded for the protection. It
+	// is only recorded if the Input enables the AuditTrail.
+	Mechanism string `json:"-"`
+}
+
+// SkippedDecision records that a resource requested protection but was not
+// protected.
+type SkippedDecision struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// BuildDecisions derives the protection decisions from the desired state of a
+// run. Resources are protected either by a Usage generated by this function or
+// by the AnnotationProtected annotation.
+func BuildDecisions(desiredComposite *resource.Composite, desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposite *resource.Composite, skipped []SkippedResource) Decisions {
+	d := Decisions{Protected: []Decision{}}
+	for _, dc := range desiredComposed {
+		if dc == nil || dc.Resource == nil {
+			continue
+		}
+		u := dc.Resource
+		if reason, ok := u.GetAnnotations()[AnnotationProtected]; ok {
+			d.Protected = append(d.Protected, Decision{APIVersion: u.GetAPIVersion(), Kind: u.GetKind(), Name: u.GetName(), Namespace: u.GetNamespace(), Reason: reason, Mechanism: u.GetAnnotations()[AnnotationMechanism]})
+			continue
+		}
+		if !IsManaged(&u.Unstructured) || (u.GetKind() != protectionv1beta1.UsageKind && u.GetKind() != protectionv1beta1.ClusterUsageKind) {
+			continue
+		}
+		str := func(path string) string {
+			v, _ := u.GetString(path)
+			return v
+		}
+		d.Protected = append(d.Protected, Decision{
+			APIVersion: str("spec.of.apiVersion"),
+			Kind:       str("spec.of.kind"),
+			Name:       str("spec.of.resourceRef.name"),
+			Namespace:  u.GetNamespace(),
+			Reason:     str("spec.reason"),
+			Usage:      u.GetName(),
+			Mechanism:  u.GetAnnotations()[AnnotationMechanism],
+		})
+	}
+	if desiredComposite != nil && desiredComposite.Resource != nil && observedComposite != nil && observedComposite.Resource != nil {
+		if reason, ok := desiredComposite.Resource.GetAnnotations()[AnnotationProtected]; ok {
+			xr := observedComposite.Resource
+			d.Protected = append(d.Protected, Decision{APIVersion: xr.GetAPIVersion(), Kind: xr.GetKind(), Name: xr.GetName(), Namespace: xr.GetNamespace(), Reason: reason, Mechanism: desiredComposite.Resource.GetAnnotations()[AnnotationMechanism]})
+		}
+	}
+	slices.SortFunc(d.Protected, func(a, b Decision) int {
+		return strings.Compare(a.Kind+"/"+a.Namespace+"/"+a.Name, b.Kind+"/"+b.Namespace+"/"+b.Name)
+	})
+	for _, s := range skipped {
+		d.Skipped = append(d.Skipped, SkippedDecision{Name: string(s.Name), Reason: s.Reason})
+	}
+	return d
+}
+
+// AsValue converts the decisions to a context value.
+func (d Decisions) AsValue() (*structpb.Value, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(m)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestBuildDecisions(t *testing.T) {
+	type args struct {
+		dxr     *resource.Composite
+		desired map[resource.Name]*resource.DesiredComposed
+		oxr     *resource.Composite
+		skipped []SkippedResource
+	}
+	type want struct {
+		decisions Decisions
+	}
+
+	xr := func(annotations map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr", "annotations": annotations},
+		}
+		return c
+	}
+	dc := func(obj map[string]any) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}}
+	}
+	usage := dc(map[string]any{
+		"apiVersion": ProtectionGroupVersion,
+		"kind":       "Usage",
+		"metadata": map[string]any{
+			"name":      "bucket-my-bucket-6a2d8c-fn-protection",
+			"namespace": "default",
+			"labels":    map[string]any{LabelManagedBy: ManagedByValue},
+		},
+		"spec": map[string]any{
+			"of": map[string]any{
+				"apiVersion":  "s3.aws.m.upbound.io/v1beta1",
+				"kind":        "Bucket",
+				"resourceRef": map[string]any{"name": "my-bucket"},
+			},
+			"reason": ProtectionReasonLabel,
+		},
+	})
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NothingProtected": {
+			reason: "No decisions should be recorded when nothing is protected",
+			args:   args{dxr: xr(nil), oxr: xr(nil), desired: map[resource.Name]*resource.DesiredComposed{"bucket": dc(map[string]any{"kind": "Bucket"})}},
+			want:   want{decisions: Decisions{Protected: []Decision{}}},
+		},
+		"Usages": {
+			reason: "Resources protected by generated Usages should be recorded",
+			args: args{
+				dxr: xr(nil),
+				oxr: xr(nil),
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket":       dc(map[string]any{"kind": "Bucket"}),
+					"bucket-usage": usage,
+				},
+			},
+			want: want{decisions: Decisions{Protected: []Decision{{
+				APIVersion: "s3.aws.m.upbound.io/v1beta1",
+				Kind:       "Bucket",
+				Name:       "my-bucket",
+				Namespace:  "default",
+				Reason:     ProtectionReasonLabel,
+				Usage:      "bucket-my-bucket-6a2d8c-fn-protection",
+			}}}},
+		},
+		"AnnotationsAndSkipped": {
+			reason: "Annotated resources and skipped resources should be recorded",
+			args: args{
+				dxr: xr(map[string]any{AnnotationProtected: ProtectionReasonCompositeChildResource}),
+				oxr: xr(nil),
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db": dc(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata": map[string]any{
+							"name":        "my-db",
+							"annotations": map[string]any{AnnotationProtected: ProtectionReasonLabel},
+						},
+					}),
+				},
+				skipped: []SkippedResource{{Name: "bucket", Reason: SkipReasonNoName}},
+			},
+			want: want{decisions: Decisions{
+				Protected: []Decision{
+					{APIVersion: "test.crossplane.io/v1", Kind: "TestComposed", Name: "my-db", Reason: ProtectionReasonLabel},
+					{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Reason: ProtectionReasonCompositeChildResource},
+				},
+				Skipped: []SkippedDecision{{Name: "bucket", Reason: SkipReasonNoName}},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := BuildDecisions(tc.args.dxr, tc.args.desired, tc.args.oxr, tc.args.skipped)
+
+			if diff := cmp.Diff(tc.want.decisions, got); diff != "" {
+				t.Errorf("%s\nBuildDecisions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionExportDecisions(t *testing.T) {
+	req := &fnv1.RunFunctionRequest{
+		Input: resource.MustStructJSON(`{
+			"apiVersion": "protection.fn.crossplane.io/v1beta1",
+			"kind": "Input",
+			"exportDecisions": true
+		}`),
+		Observed: &fnv1.State{
+			Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestXR",
+				"metadata": {"name": "my-xr"}
+			}`)},
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {
+						"name": "my-bucket",
+						"labels": {"protection.fn.crossplane.io/block-deletion": "true"}
+					}
+				}`)},
+			},
+		},
+		Desired: &fnv1.State{
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed"
+				}`)},
+			},
+		},
+	}
+
+	f := &Function{log: logging.NewNopLogger()}
+	rsp, err := f.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): unexpected error: %v", err)
+	}
+
+	want := structpb.NewStructValue(resource.MustStructJSON(`{
+		"protected": [
+			{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestComposed",
+				"name": "my-bucket",
+				"reason": "created by function-deletion-protection via label protection.fn.crossplane.io/block-deletion",
+				"usage": "testcomposed-my-bucket-05156c-fn-protection"
+			},
+			{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestXR",
+				"name": "my-xr",
+				"reason": "created by function-deletion-protection because a composed resource is protected",
+				"usage": "testxr-my-xr-479e7a-fn-protection"
+			}
+		]
+	}`))
+	if diff := cmp.Diff(want, rsp.GetContext().GetFields()[ContextKeyDecisions], protocmp.Transform()); diff != "" {
+		t.Errorf("f.RunFunction(...): -want decisions, +got decisions:\n%s", diff)
+	}
+}
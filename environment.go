@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/request"
+)
+
+const (
+	// ContextKeyEnvironment is the context key used by Crossplane to pass
+	// EnvironmentConfig data to functions.
+	ContextKeyEnvironment = "apiextensions.crossplane.io/environment"
+	// DefaultProtectEnvironmentPath is the field path of the environment name
+	// if the Input does not configure one.
+	DefaultProtectEnvironmentPath = "env"
+)
+
+// GetEnvironment returns the environment from the request context. It returns
+// false if the request has no environment.
+func GetEnvironment(req *fnv1.RunFunctionRequest) (map[string]any, bool) {
+	v, ok := request.GetContextKey(req, ContextKeyEnvironment)
+	if !ok || v.GetStructValue() == nil {
+		return nil, false
+	}
+	return v.GetStructValue().AsMap(), true
+}
+
+// EnvironmentEnablesProtection returns false only if the value at the supplied
+// path of the environment explicitly disables protection. A missing
+// environment or value leaves protection enabled.
+func EnvironmentEnablesProtection(env map[string]any, path string) bool {
+	if env == nil || path == "" {
+		return true
+	}
+	v, err := fieldpath.Pave(env).GetValue(path)
+	if err != nil {
+		return true
+	}
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		enabled, err := strconv.ParseBool(val)
+		return err != nil || enabled
+	default:
+		return true
+	}
+}
+
+// EnvironmentProtected returns false only if the environment name at the
+// Input's ProtectEnvironmentPath is not one of its ProtectEnvironments. It also
+// returns the environment name. A missing environment or value leaves
+// protection enabled.
+func EnvironmentProtected(env map[string]any, in *v1beta1.Input) (string, bool) {
+	if env == nil || len(in.ProtectEnvironments) == 0 {
+		return "", true
+	}
+	path := in.ProtectEnvironmentPath
+	if path == "" {
+		path = DefaultProtectEnvironmentPath
+	}
+	v, err := fieldpath.Pave(env).GetValue(path)
+	if err != nil || v == nil {
+		return "", true
+	}
+	name := fmt.Sprint(v)
+	return name, slices.Contains(in.ProtectEnvironments, name)
+}
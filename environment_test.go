@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+func TestGetEnvironment(t *testing.T) {
+	type args struct {
+		req *fnv1.RunFunctionRequest
+	}
+	type want struct {
+		env map[string]any
+		ok  bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoContext": {
+			reason: "A request without a context should not have an environment",
+			args:   args{req: &fnv1.RunFunctionRequest{}},
+			want:   want{env: nil, ok: false},
+		},
+		"Environment": {
+			reason: "The environment should be read from the context",
+			args: args{req: &fnv1.RunFunctionRequest{
+				Context: resource.MustStructJSON(`{
+					"apiextensions.crossplane.io/environment": {
+						"protectionEnabled": false
+					}
+				}`),
+			}},
+			want: want{env: map[string]any{"protectionEnabled": false}, ok: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			env, ok := GetEnvironment(tc.args.req)
+
+			if diff := cmp.Diff(tc.want.env, env); diff != "" {
+				t.Errorf("%s\nGetEnvironment(...): -want env, +got env:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nGetEnvironment(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestEnvironmentEnablesProtection(t *testing.T) {
+	type args struct {
+		env  map[string]any
+		path string
+	}
+	type want struct {
+		enabled bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoEnvironment": {
+			reason: "A missing environment should leave protection enabled",
+			args:   args{env: nil, path: "protectionEnabled"},
+			want:   want{enabled: true},
+		},
+		"NoPath": {
+			reason: "Protection should be enabled when no path is configured",
+			args:   args{env: map[string]any{"protectionEnabled": false}},
+			want:   want{enabled: true},
+		},
+		"MissingValue": {
+			reason: "A missing value should leave protection enabled",
+			args:   args{env: map[string]any{"other": false}, path: "protectionEnabled"},
+			want:   want{enabled: true},
+		},
+		"Disabled": {
+			reason: "A false value should disable protection",
+			args:   args{env: map[string]any{"protectionEnabled": false}, path: "protectionEnabled"},
+			want:   want{enabled: false},
+		},
+		"Enabled": {
+			reason: "A true value should enable protection",
+			args:   args{env: map[string]any{"protectionEnabled": true}, path: "protectionEnabled"},
+			want:   want{enabled: true},
+		},
+		"DisabledString": {
+			reason: "A nested string false value should disable protection",
+			args:   args{env: map[string]any{"protection": map[string]any{"enabled": "false"}}, path: "protection.enabled"},
+			want:   want{enabled: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := EnvironmentEnablesProtection(tc.args.env, tc.args.path)
+
+			if diff := cmp.Diff(tc.want.enabled, got); diff != "" {
+				t.Errorf("%s\nEnvironmentEnablesProtection(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestEnvironmentProtected(t *testing.T) {
+	type args struct {
+		env map[string]any
+		in  *v1beta1.Input
+	}
+	type want struct {
+		name      string
+		protected bool
+	}
+
+	production := []string{"production"}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoEnvironments": {
+			reason: "Protection should be enabled when no environments are configured",
+			args:   args{env: map[string]any{"env": "dev"}, in: &v1beta1.Input{}},
+			want:   want{protected: true},
+		},
+		"NoEnvironment": {
+			reason: "A missing environment should leave protection enabled",
+			args:   args{in: &v1beta1.Input{ProtectEnvironments: production}},
+			want:   want{protected: true},
+		},
+		"MissingValue": {
+			reason: "A missing environment name should leave protection enabled",
+			args:   args{env: map[string]any{"region": "eu-west-1"}, in: &v1beta1.Input{ProtectEnvironments: production}},
+			want:   want{protected: true},
+		},
+		"Matches": {
+			reason: "Protection should be enabled in a configured environment",
+			args:   args{env: map[string]any{"env": "production"}, in: &v1beta1.Input{ProtectEnvironments: production}},
+			want:   want{name: "production", protected: true},
+		},
+		"DoesNotMatch": {
+			reason: "Protection should be disabled in any other environment",
+			args:   args{env: map[string]any{"env": "dev"}, in: &v1beta1.Input{ProtectEnvironments: production}},
+			want:   want{name: "dev", protected: false},
+		},
+		"CustomPath": {
+			reason: "The environment name should be read from the configured path",
+			args: args{
+				env: map[string]any{"env": "production", "platform": map[string]any{"stage": "staging"}},
+				in:  &v1beta1.Input{ProtectEnvironments: production, ProtectEnvironmentPath: "platform.stage"},
+			},
+			want: want{name: "staging", protected: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotName, got := EnvironmentProtected(tc.args.env, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.name, gotName); diff != "" {
+				t.Errorf("%s\nEnvironmentProtected(...): -want name, +got name:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.protected, got); diff != "" {
+				t.Errorf("%s\nEnvironmentProtected(...): -want protected, +got protected:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionProtectEnvironments(t *testing.T) {
+	type args struct {
+		context string
+	}
+	type want struct {
+		desired []string
+		results []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Matches": {
+			reason: "Resources should be protected in a configured environment",
+			args:   args{context: `{"apiextensions.crossplane.io/environment": {"env": "production"}}`},
+			want:   want{desired: []string{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"DoesNotMatch": {
+			reason: "No resources should be protected in any other environment",
+			args:   args{context: `{"apiextensions.crossplane.io/environment": {"env": "dev"}}`},
+			want:   want{desired: []string{"bucket"}, results: []string{`protection disabled for environment "dev"`}},
+		},
+		"NoEnvironment": {
+			reason: "Resources should be protected if the pipeline has no environment",
+			args:   args{context: `{}`},
+			want:   want{desired: []string{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"protectEnvironments": ["production"]
+				}`),
+				Context: resource.MustStructJSON(tc.args.context),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "my-bucket", "labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.desired, slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+			var results []string
+			for _, r := range rsp.GetResults() {
+				results = append(results, r.GetMessage())
+			}
+			if diff := cmp.Diff(tc.want.results, results); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+const (
+	// EventReasonDeletionProtection is the reason of the Events summarizing
+	// the protection applied by a run.
+	EventReasonDeletionProtection = "DeletionProtection"
+	// EventSourceComponent is the source component of generated Events.
+	EventSourceComponent = "function-deletion-protection"
+	// EventNameSuffix is the suffix applied when generating Event names.
+	EventNameSuffix = "protection"
+	// EventDefaultNamespace is the namespace of the Events of cluster scoped
+	// composites.
+	EventDefaultNamespace = "default"
+	// eventResourceName is the name of the generated Event in the desired
+	// composed resources.
+	eventResourceName resource.Name = "protection-event"
+)
+
+// GenerateEvent creates a core/v1 Event involving the composite that
+// summarizes the supplied protection decisions. The Event is a Warning if any
+// resource could not be protected. The timestamps and count of the supplied
+// previously observed Event are kept while its summary is unchanged, so that
+// the Event only changes when the protection does.
+func GenerateEvent(now time.Time, observedComposite *resource.Composite, previous *unstructured.Unstructured, d Decisions) map[string]any {
+	xr := observedComposite.Resource
+	namespace := xr.GetNamespace()
+	if namespace == "" {
+		namespace = EventDefaultNamespace
+	}
+
+	eventType := "Normal"
+	if len(d.Skipped) > 0 {
+		eventType = "Warning"
+	}
+
+	involved := map[string]any{
+		"apiVersion": xr.GetAPIVersion(),
+		"kind":       xr.GetKind(),
+		"name":       xr.GetName(),
+	}
+	if ns := xr.GetNamespace(); ns != "" {
+		involved["namespace"] = ns
+	}
+	if uid := string(xr.GetUID()); uid != "" {
+		involved["uid"] = uid
+	}
+
+	message := EventMessage(d)
+	event := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"name":      GenerateName(xr.GetName(), EventNameSuffix),
+			"namespace": namespace,
+			"labels": map[string]any{
+				LabelManagedBy: ManagedByValue,
+			},
+		},
+		"involvedObject": involved,
+		"reason":         EventReasonDeletionProtection,
+		"message":        message,
+		"type":           eventType,
+		"source":         map[string]any{"component": EventSourceComponent},
+	}
+	ts := now.UTC().Format(time.RFC3339)
+	first, last, count := ts, ts, int64(1)
+	if previous != nil && previous.Object != nil {
+		str := func(field string) string {
+			v, _, _ := unstructured.NestedString(previous.Object, field)
+			return v
+		}
+		if v := cmp.Or(str("firstTimestamp"), str("lastTimestamp")); v != "" {
+			first = v
+		}
+		n := eventCount(previous)
+		if str("reason") == EventReasonDeletionProtection && str("message") == message && str("type") == eventType && str("lastTimestamp") != "" {
+			last, count = str("lastTimestamp"), max(n, 1)
+		} else {
+			count = n + 1
+		}
+	}
+	event["firstTimestamp"] = first
+	event["lastTimestamp"] = last
+	event["count"] = count
+	return event
+}
+
+// eventCount returns the count of the supplied Event. Observed resources are
+// decoded from JSON, so the count may be a float64.
+func eventCount(e *unstructured.Unstructured) int64 {
+	switch v := e.Object["count"].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// EventMessage summarizes the supplied protection decisions.
+func EventMessage(d Decisions) string {
+	names := make([]string, 0, len(d.Protected))
+	for _, p := range d.Protected {
+		name := p.Name
+		if p.Namespace != "" {
+			name = p.Namespace + "/" + p.Name
+		}
+		names = append(names, p.Kind+" "+name)
+	}
+	msg := "no resources are protected"
+	if len(names) > 0 {
+		msg = "protecting " + strings.Join(names, ", ")
+	}
+	if len(d.Skipped) > 0 {
+		skipped := make([]SkippedResource, 0, len(d.Skipped))
+		for _, s := range d.Skipped {
+			skipped = append(skipped, SkippedResource{Name: resource.Name(s.Name), Reason: s.Reason})
+		}
+		msg += "; " + SkippedMessage(skipped)
+	}
+	return msg
+}
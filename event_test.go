@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestGenerateEvent(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	xr := func(namespace string) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.SetAPIVersion("test.crossplane.io/v1")
+		c.Resource.SetKind("TestXR")
+		c.Resource.SetName("my-xr")
+		c.Resource.SetNamespace(namespace)
+		c.Resource.SetUID(types.UID("0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a"))
+		return c
+	}
+	protected := []Decision{
+		{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket", Name: "my-bucket", Reason: ProtectionReasonLabel, Usage: "bucket-my-bucket"},
+		{APIVersion: "rds.aws.upbound.io/v1beta1", Kind: "Instance", Name: "my-db", Namespace: "team-a", Reason: ProtectionReasonLabel, Usage: "instance-my-db"},
+	}
+
+	previous := func(message string, count any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion":     "v1",
+			"kind":           "Event",
+			"reason":         EventReasonDeletionProtection,
+			"message":        message,
+			"type":           "Normal",
+			"firstTimestamp": "2026-10-01T00:00:00Z",
+			"lastTimestamp":  "2026-10-14T00:00:00Z",
+			"count":          count,
+		}}
+	}
+
+	type args struct {
+		xr       *resource.Composite
+		previous *unstructured.Unstructured
+		d        Decisions
+	}
+	type want struct {
+		event map[string]any
+	}
+
+	event := func(namespace, eventType, message string, involved map[string]any) map[string]any {
+		return map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Event",
+			"metadata": map[string]any{
+				"name":      "my-xr-9d53fb-protection",
+				"namespace": namespace,
+				"labels":    map[string]any{LabelManagedBy: ManagedByValue},
+			},
+			"involvedObject": involved,
+			"reason":         EventReasonDeletionProtection,
+			"message":        message,
+			"type":           eventType,
+			"source":         map[string]any{"component": EventSourceComponent},
+			"firstTimestamp": "2026-10-15T12:00:00Z",
+			"lastTimestamp":  "2026-10-15T12:00:00Z",
+			"count":          int64(1),
+		}
+	}
+	involved := map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestXR",
+		"name":       "my-xr",
+		"namespace":  "team-a",
+		"uid":        "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+	}
+	withTimestamps := func(e map[string]any, first, last string, count int64) map[string]any {
+		e["firstTimestamp"] = first
+		e["lastTimestamp"] = last
+		e["count"] = count
+		return e
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Namespaced": {
+			reason: "The Event of a namespaced composite should be created in its namespace",
+			args:   args{xr: xr("team-a"), d: Decisions{Protected: protected}},
+			want: want{event: event("team-a", "Normal", "protecting Bucket my-bucket, Instance team-a/my-db", map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestXR",
+				"name":       "my-xr",
+				"namespace":  "team-a",
+				"uid":        "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+			})},
+		},
+		"ClusterScoped": {
+			reason: "The Event of a cluster scoped composite should be created in the default namespace",
+			args:   args{xr: xr(""), d: Decisions{}},
+			want: want{event: event(EventDefaultNamespace, "Normal", "no resources are protected", map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestXR",
+				"name":       "my-xr",
+				"uid":        "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+			})},
+		},
+		"Skipped": {
+			reason: "The Event should be a Warning listing resources that could not be protected",
+			args:   args{xr: xr("team-a"), d: Decisions{Protected: protected[:1], Skipped: []SkippedDecision{{Name: "db", Reason: SkipReasonNotReadyLongEnough}}}},
+			want: want{event: event("team-a", "Warning", "protecting Bucket my-bucket; protection could not be applied to: db ("+SkipReasonNotReadyLongEnough+")", map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestXR",
+				"name":       "my-xr",
+				"namespace":  "team-a",
+				"uid":        "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+			})},
+		},
+		"Unchanged": {
+			reason: "The timestamps and count of a previous Event with the same summary should be kept",
+			args:   args{xr: xr("team-a"), previous: previous("protecting Bucket my-bucket, Instance team-a/my-db", float64(3)), d: Decisions{Protected: protected}},
+			want: want{event: withTimestamps(event("team-a", "Normal", "protecting Bucket my-bucket, Instance team-a/my-db", involved),
+				"2026-10-01T00:00:00Z", "2026-10-14T00:00:00Z", 3)},
+		},
+		"Changed": {
+			reason: "A previous Event with a different summary should be counted again and keep its first timestamp",
+			args:   args{xr: xr("team-a"), previous: previous("protecting Bucket my-bucket", float64(3)), d: Decisions{Protected: protected}},
+			want: want{event: withTimestamps(event("team-a", "Normal", "protecting Bucket my-bucket, Instance team-a/my-db", involved),
+				"2026-10-01T00:00:00Z", "2026-10-15T12:00:00Z", 4)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GenerateEvent(now, tc.args.xr, tc.args.previous, tc.args.d)
+
+			if diff := cmp.Diff(tc.want.event, got); diff != "" {
+				t.Errorf("%s\nGenerateEvent(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionKubeEvent(t *testing.T) {
+	req := &fnv1.RunFunctionRequest{
+		Input: resource.MustStructJSON(`{
+			"apiVersion": "protection.fn.crossplane.io/v1beta1",
+			"kind": "Input",
+			"emitKubeEvent": true
+		}`),
+		Observed: &fnv1.State{
+			Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestXR",
+				"metadata": {"name": "my-xr", "namespace": "team-a"}
+			}`)},
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {
+						"name": "my-bucket",
+						"namespace": "team-a",
+						"labels": {"protection.fn.crossplane.io/block-deletion": "true"}
+					}
+				}`)},
+			},
+		},
+		Desired: &fnv1.State{
+			Resources: map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed"
+				}`)},
+			},
+		},
+	}
+
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return now }}
+	rsp, err := f.RunFunction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): unexpected error: %v", err)
+	}
+
+	event := rsp.GetDesired().GetResources()[string(eventResourceName)]
+	if event == nil {
+		t.Fatalf("f.RunFunction(...): want desired resource %q, got none", eventResourceName)
+	}
+	got := event.GetResource().AsMap()
+	want := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"name":      "my-xr-9d53fb-protection",
+			"namespace": "team-a",
+			"labels":    map[string]any{LabelManagedBy: ManagedByValue},
+		},
+		"involvedObject": map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"name":       "my-xr",
+			"namespace":  "team-a",
+		},
+		"reason":         EventReasonDeletionProtection,
+		"message":        "protecting TestComposed team-a/my-bucket, TestXR team-a/my-xr",
+		"type":           "Normal",
+		"source":         map[string]any{"component": EventSourceComponent},
+		"firstTimestamp": "2026-10-15T12:00:00Z",
+		"lastTimestamp":  "2026-10-15T12:00:00Z",
+		"count":          float64(1),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("f.RunFunction(...): -want event, +got event:\n%s", diff)
+	}
+}
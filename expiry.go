@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// AnnotationExpiresAt records when the protection of a Usage generated with a
+// ProtectionTTL expires, in RFC 3339 format.
+const AnnotationExpiresAt = "protection.fn.crossplane.io/expires-at"
+
+// CreationTime returns when the supplied resource was created. If its
+// creationTimestamp is not set the RFC 3339 time in the supplied annotation is
+// used instead. It returns the zero time if neither is set.
+func CreationTime(u *unstructured.Unstructured, annotation string) time.Time {
+	if created := u.GetCreationTimestamp(); !created.IsZero() {
+		return created.Time
+	}
+	if annotation == "" {
+		return time.Time{}
+	}
+	created, err := time.Parse(time.RFC3339, u.GetAnnotations()[annotation])
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+// A ProtectedResource is a resource that may be protected by a generated Usage.
+type ProtectedResource struct {
+	// Observed is the observed resource.
+	Observed *unstructured.Unstructured
+
+	// Desired is the desired resource. It is nil if the resource is not
+	// composed by the function pipeline, e.g. a required resource.
+	Desired *unstructured.Unstructured
+}
+
+// protectedKey identifies a protected resource by its API version, kind,
+// namespace and name.
+func protectedKey(apiVersion, kind, namespace, name string) string {
+	return strings.Join([]string{apiVersion, kind, namespace, name}, "/")
+}
+
+// UsageTarget returns the key of the resource the supplied Usage protects, as
+// used by ProtectedResources.
+func UsageTarget(usage *unstructured.Unstructured) string {
+	str := func(path ...string) string {
+		v, _, _ := unstructured.NestedString(usage.Object, path...)
+		return v
+	}
+	return protectedKey(str("spec", "of", "apiVersion"), str("spec", "of", "kind"), usage.GetNamespace(), str("spec", "of", "resourceRef", "name"))
+}
+
+// ProtectedResources returns the observed composite, composed and required
+// resources keyed by UsageTarget, so that the resource protected by a Usage
+// can be looked up.
+func ProtectedResources(observedComposite, desiredComposite *resource.Composite, observedComposed map[resource.Name]resource.ObservedComposed, desiredComposed map[resource.Name]*resource.DesiredComposed, required map[string][]resource.Required) map[string]ProtectedResource {
+	protected := map[string]ProtectedResource{}
+	add := func(o, d *unstructured.Unstructured) {
+		protected[protectedKey(o.GetAPIVersion(), o.GetKind(), o.GetNamespace(), o.GetName())] = ProtectedResource{Observed: o, Desired: d}
+	}
+	for _, rs := range required {
+		for _, r := range rs {
+			if r.Resource != nil {
+				add(r.Resource, nil)
+			}
+		}
+	}
+	for name, o := range observedComposed {
+		if o.Resource == nil {
+			continue
+		}
+		var d *unstructured.Unstructured
+		if dc, ok := desiredComposed[name]; ok && dc != nil && dc.Resource != nil {
+			d = &dc.Resource.Unstructured
+		}
+		add(&o.Resource.Unstructured, d)
+	}
+	if observedComposite != nil && observedComposite.Resource != nil {
+		var d *unstructured.Unstructured
+		if desiredComposite != nil && desiredComposite.Resource != nil {
+			d = &desiredComposite.Resource.Unstructured
+		}
+		add(&observedComposite.Resource.Unstructured, d)
+	}
+	return protected
+}
+
+// ExpiresAt returns when the protection of the supplied resource by a Usage
+// expires according to the Input's ProtectionTTL. It returns false if no ttl is
+// set. The ttl must be a valid duration.
+//
+// An expiry recorded on the protected resource by an earlier run is kept, as
+// it survives the deletion of the expired Usage. Otherwise protection started
+// when the supplied observed Usage was created, or starts now if the Usage has
+// not been created yet. The expiry cannot be recorded on resources that are
+// not composed by the function pipeline, so their protection started when they
+// were created. The protected resource may be nil if it is unknown.
+func ExpiresAt(protected *ProtectedResource, observed *unstructured.Unstructured, in *v1beta1.Input, now time.Time) (time.Time, bool) {
+	if in.ProtectionTTL == "" {
+		return time.Time{}, false
+	}
+	d, err := time.ParseDuration(in.ProtectionTTL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if protected != nil && protected.Observed != nil {
+		if at, err := time.Parse(time.RFC3339, protected.Observed.GetAnnotations()[AnnotationExpiresAt]); err == nil {
+			return at.UTC(), true
+		}
+	}
+	started := now
+	if observed != nil && observed.Object != nil {
+		if created := observed.GetCreationTimestamp(); !created.IsZero() {
+			return created.Add(d).UTC(), true
+		}
+	}
+	if protected != nil && protected.Observed != nil && protected.Desired == nil {
+		if created := CreationTime(protected.Observed, in.CreationTimeAnnotation); !created.IsZero() {
+			started = created
+		}
+	}
+	return started.Add(d).UTC(), true
+}
+
+// SetExpiries annotates the supplied Usages with the expiry of their
+// protection according to the Input's ProtectionTTL, see ExpiresAt. The
+// expiry is also recorded on the desired resource each Usage protects, so
+// that an expired Usage is not generated again once it has been deleted.
+// Usages that are already annotated keep their expiry.
+func SetExpiries(usages map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, protected map[string]ProtectedResource, in *v1beta1.Input, now time.Time) {
+	for name, u := range usages {
+		var p *ProtectedResource
+		if r, ok := protected[UsageTarget(&u.Resource.Unstructured)]; ok {
+			p = &r
+		}
+		at, ok := u.Resource.GetAnnotations()[AnnotationExpiresAt]
+		if !ok {
+			var observed *unstructured.Unstructured
+			if o, ok := observedComposed[name]; ok && o.Resource != nil {
+				observed = &o.Resource.Unstructured
+			}
+			t, ok := ExpiresAt(p, observed, in, now)
+			if !ok {
+				continue
+			}
+			at = t.Format(time.RFC3339)
+			meta.AddAnnotations(u.Resource, map[string]string{AnnotationExpiresAt: at})
+		}
+		if p != nil && p.Desired != nil {
+			meta.AddAnnotations(p.Desired, map[string]string{AnnotationExpiresAt: at})
+		}
+	}
+}
+
+// ExpireUsages removes the Usages whose protection has expired at the supplied
+// time from the supplied map and returns their names.
+func ExpireUsages(usages map[resource.Name]*resource.DesiredComposed, now time.Time) []resource.Name {
+	var expired []resource.Name
+	for name, u := range usages {
+		v, ok := u.Resource.GetAnnotations()[AnnotationExpiresAt]
+		if !ok {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, v)
+		if err != nil || now.Before(at) {
+			continue
+		}
+		delete(usages, name)
+		expired = append(expired, name)
+	}
+	return expired
+}
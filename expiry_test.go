@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestExpiresAt(t *testing.T) {
+	type args struct {
+		protected *ProtectedResource
+		observed  *unstructured.Unstructured
+		ttl       string
+	}
+	type want struct {
+		at time.Time
+		ok bool
+	}
+
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	created := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "bucket-my-bucket-fn-protection", "creationTimestamp": "2026-10-01T00:00:00Z"},
+	}}
+	uncreated := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "bucket-my-bucket-fn-protection"},
+	}}
+	bucket := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "my-bucket", "creationTimestamp": "2026-01-01T00:00:00Z"},
+	}}
+	recorded := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":              "my-bucket",
+			"creationTimestamp": "2026-01-01T00:00:00Z",
+			"annotations":       map[string]any{AnnotationExpiresAt: "2026-10-04T00:00:00Z"},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoTTL": {
+			reason: "A resource should be protected indefinitely without a ttl",
+			args:   args{observed: created},
+			want:   want{},
+		},
+		"UsageCreated": {
+			reason: "Protection should expire ttl after the observed Usage was created",
+			args:   args{observed: created, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		},
+		"UsageNotObserved": {
+			reason: "Protection by a Usage that has not been observed yet should expire ttl from now",
+			args:   args{ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC), ok: true},
+		},
+		"UsageNotCreated": {
+			reason: "Protection by an observed Usage without a creationTimestamp should expire ttl from now",
+			args:   args{observed: uncreated, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC), ok: true},
+		},
+		"OldComposedResource": {
+			reason: "Protection of a composed resource older than the ttl should expire ttl from now",
+			args:   args{protected: &ProtectedResource{Observed: bucket, Desired: bucket.DeepCopy()}, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC), ok: true},
+		},
+		"RecordedOnResource": {
+			reason: "The expiry recorded on the protected resource should be kept after its Usage was deleted",
+			args:   args{protected: &ProtectedResource{Observed: recorded, Desired: bucket.DeepCopy()}, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		},
+		"RecordedWins": {
+			reason: "The expiry recorded on the protected resource should be used over the creation of the Usage",
+			args:   args{protected: &ProtectedResource{Observed: recorded}, observed: uncreated, ttl: "24h"},
+			want:   want{at: time.Date(2026, time.October, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		},
+		"NotComposed": {
+			reason: "Protection of a resource that is not composed should expire ttl after it was created",
+			args:   args{protected: &ProtectedResource{Observed: bucket}, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.January, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		},
+		"NotComposedUsageCreated": {
+			reason: "Protection of a resource that is not composed should expire ttl after its observed Usage was created",
+			args:   args{protected: &ProtectedResource{Observed: bucket}, observed: created, ttl: "72h"},
+			want:   want{at: time.Date(2026, time.October, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			at, ok := ExpiresAt(tc.args.protected, tc.args.observed, &v1beta1.Input{ProtectionTTL: tc.args.ttl}, now)
+
+			if diff := cmp.Diff(tc.want.at, at); diff != "" {
+				t.Errorf("%s\nExpiresAt(...): -want at, +got at:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nExpiresAt(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreationTime(t *testing.T) {
+	type args struct {
+		u          *unstructured.Unstructured
+		annotation string
+	}
+	type want struct {
+		created time.Time
+	}
+
+	created := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "my-bucket", "creationTimestamp": "2026-10-01T00:00:00Z"},
+	}}
+	uncreated := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "my-bucket"},
+	}}
+	annotated := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":        "my-bucket",
+			"annotations": map[string]any{"example.org/created-at": "2026-10-02T00:00:00Z"},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Created": {
+			reason: "The creationTimestamp of a resource should be its creation time",
+			args:   args{u: created},
+			want:   want{created: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"NotCreated": {
+			reason: "A resource that has not been created should have no creation time",
+			args:   args{u: uncreated},
+			want:   want{},
+		},
+		"CreationTimeAnnotation": {
+			reason: "The time in the creation time annotation should be used if the resource has no creationTimestamp",
+			args:   args{u: annotated, annotation: "example.org/created-at"},
+			want:   want{created: time.Date(2026, time.October, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		"CreationTimestampWins": {
+			reason: "The creationTimestamp should be used over the creation time annotation",
+			args: args{u: func() *unstructured.Unstructured {
+				u := created.DeepCopy()
+				u.SetAnnotations(map[string]string{"example.org/created-at": "2026-10-02T00:00:00Z"})
+				return u
+			}(), annotation: "example.org/created-at"},
+			want: want{created: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"InvalidCreationTimeAnnotation": {
+			reason: "A creation time annotation that is not an RFC 3339 time should be ignored",
+			args: args{u: func() *unstructured.Unstructured {
+				u := uncreated.DeepCopy()
+				u.SetAnnotations(map[string]string{"example.org/created-at": "yesterday"})
+				return u
+			}(), annotation: "example.org/created-at"},
+			want: want{},
+		},
+		"AnnotationNotConfigured": {
+			reason: "The creation time annotation should be ignored if it is not configured",
+			args:   args{u: annotated},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CreationTime(tc.args.u, tc.args.annotation)
+
+			if diff := cmp.Diff(tc.want.created, got.UTC()); diff != "" {
+				t.Errorf("%s\nCreationTime(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestComputeDesiredProtectionTTL(t *testing.T) {
+	type args struct {
+		now time.Time
+		in  *v1beta1.Input
+		// usageCreated is the creationTimestamp of the observed Usage. The
+		// Usage is not observed if it is empty.
+		usageCreated string
+		// recorded is the expiry recorded on the observed resource by an
+		// earlier run, if any.
+		recorded string
+	}
+	type want struct {
+		names     []resource.Name
+		expiresAt string
+		// recorded is the expiry recorded on the desired resource.
+		recorded string
+	}
+
+	xr := func() *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr"},
+		}
+		return c
+	}
+	desired := func() map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"labels": map[string]any{ProtectionLabelBlockDeletion: "true"}},
+			}}}},
+		}
+	}
+	observed := func(usageCreated, recorded string) map[resource.Name]resource.ObservedComposed {
+		o := map[resource.Name]resource.ObservedComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"name":              "my-bucket",
+					"creationTimestamp": "2026-01-01T00:00:00Z",
+					"labels":            map[string]any{ProtectionLabelBlockDeletion: "true"},
+				},
+			}}}},
+		}
+		if recorded != "" {
+			o["bucket"].Resource.SetAnnotations(map[string]string{AnnotationExpiresAt: recorded})
+		}
+		if usageCreated != "" {
+			o["bucket-usage"] = resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": ProtectionGroupVersion,
+				"kind":       "ClusterUsage",
+				"metadata": map[string]any{
+					"name":              "testcomposed-my-bucket-05156c-fn-protection",
+					"creationTimestamp": usageCreated,
+					"labels":            map[string]any{LabelManagedBy: ManagedByValue},
+				},
+			}}}}
+		}
+		return o
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoTTL": {
+			reason: "Usages should not expire without a protectionTTL",
+			args:   args{now: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), in: &v1beta1.Input{}},
+			want:   want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"OldResource": {
+			reason: "Protection of a resource older than the protectionTTL should start now rather than already be expired",
+			args:   args{now: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), in: &v1beta1.Input{ProtectionTTL: "72h"}},
+			want:   want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}, expiresAt: "2026-10-04T00:00:00Z", recorded: "2026-10-04T00:00:00Z"},
+		},
+		"BeforeExpiry": {
+			reason: "A Usage should be annotated with its expiry and kept before it expires",
+			args:   args{now: time.Date(2026, time.October, 3, 23, 59, 59, 0, time.UTC), in: &v1beta1.Input{ProtectionTTL: "72h"}, usageCreated: "2026-10-01T00:00:00Z"},
+			want:   want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}, expiresAt: "2026-10-04T00:00:00Z", recorded: "2026-10-04T00:00:00Z"},
+		},
+		"AfterExpiry": {
+			reason: "A Usage should be dropped once it expired, and no longer cause the composite to be protected",
+			args:   args{now: time.Date(2026, time.October, 4, 0, 0, 0, 0, time.UTC), in: &v1beta1.Input{ProtectionTTL: "72h"}, usageCreated: "2026-10-01T00:00:00Z"},
+			want:   want{names: []resource.Name{"bucket"}, recorded: "2026-10-04T00:00:00Z"},
+		},
+		"AfterExpiryUsageDeleted": {
+			reason: "An expired Usage should not be generated again once it was deleted, because its expiry is recorded on the protected resource",
+			args:   args{now: time.Date(2026, time.October, 5, 0, 0, 0, 0, time.UTC), in: &v1beta1.Input{ProtectionTTL: "72h"}, recorded: "2026-10-04T00:00:00Z"},
+			want:   want{names: []resource.Name{"bucket"}, recorded: "2026-10-04T00:00:00Z"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return tc.args.now }}
+			got, _, err := f.computeDesired(context.Background(), tc.args.in, xr(), xr(), observed(tc.args.usageCreated, tc.args.recorded), desired(), nil)
+			if err != nil {
+				t.Fatalf("%s\nf.computeDesired(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(got))); diff != "" {
+				t.Errorf("%s\nf.computeDesired(...): -want names, +got names:\n%s", tc.reason, diff)
+			}
+			if u, ok := got["bucket-usage"]; ok {
+				if diff := cmp.Diff(tc.want.expiresAt, u.Resource.GetAnnotations()[AnnotationExpiresAt]); diff != "" {
+					t.Errorf("%s\nf.computeDesired(...): -want expiry, +got expiry:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.recorded, got["bucket"].Resource.GetAnnotations()[AnnotationExpiresAt]); diff != "" {
+				t.Errorf("%s\nf.computeDesired(...): -want recorded expiry, +got recorded expiry:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 	apiextensionsv1beta1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1beta1"
 	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
 	"google.golang.org/protobuf/types/known/durationpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/crossplane/function-sdk-go/errors"
 	"github.com/crossplane/function-sdk-go/logging"
@@ -27,6 +35,28 @@ type Function struct {
 	fnv1.UnimplementedFunctionRunnerServiceServer
 
 	log logging.Logger
+
+	// clock returns the current time. Defaults to time.Now.
+	clock func() time.Time
+
+	// client calls the decision webhook. Defaults to http.DefaultClient.
+	client *http.Client
+}
+
+// now returns the current time according to the Function's clock.
+func (f *Function) now() time.Time {
+	if f.clock == nil {
+		return time.Now()
+	}
+	return f.clock()
+}
+
+// httpClient returns the client used to call the decision webhook.
+func (f *Function) httpClient() *http.Client {
+	if f.client == nil {
+		return http.DefaultClient
+	}
+	return f.client
 }
 
 const (
@@ -34,37 +64,233 @@ const (
 	ProtectionGroupVersion                 = protectionv1beta1.Group + "/" + protectionv1beta1.Version
 	ProtectionReason                       = "created by function-deletion-protection "
 	ProtectionReasonLabel                  = ProtectionReason + "via label " + ProtectionLabelBlockDeletion
+	ProtectionReasonLabelWithoutKey        = ProtectionReason + "via protection label"
+	ProtectionReasonLabelValue             = ProtectionReason + "because a label matches matchLabelEquals"
+	ProtectionReasonAnnotationRegex        = ProtectionReason + "because an annotation matches matchAnnotationRegex"
+	ProtectionReasonAllSelectors           = ProtectionReason + "because its labels match all requireAllSelectors"
 	ProtectionReasonCompositeChildResource = ProtectionReason + "because a composed resource is protected"
+	ProtectionReasonSharedGroup            = ProtectionReason + "because it belongs to a shared protection group"
+	ProtectionReasonOwnerKind              = ProtectionReason + "because it is owned by a protected kind"
+	ProtectionReasonProvider               = ProtectionReason + "because it belongs to a protected provider"
+	ProtectionReasonPriority               = ProtectionReason + "because its priority is at least protectIfPriorityAtLeast"
+	ProtectionReasonNamespaceGroup         = ProtectionReason + "because its namespace is protected as a group"
+	ProtectionReasonSpec                   = ProtectionReason + "because the composite declares protection at protectionSpecPath"
+	ProtectionReasonWebhook                = ProtectionReason + "because the decision webhook requires it"
+	ProtectionReasonExpression             = ProtectionReason + "because it matches protectWhen expressions"
+	ProtectionReasonDefault                = ProtectionReason + "because protection is enabled by default"
+	ProtectionReasonStatus                 = ProtectionReason + "because its status matches protectIfStatusPath"
+	ProtectionReasonCondition              = ProtectionReason + "because its protectIfConditionTrue condition is True"
+	ProtectionReasonSample                 = ProtectionReason + "because it was sampled by sampleProtectPercent"
+	ProtectionReasonExternalName           = ProtectionReason + "because its external name matches protectExternalNameRegex"
+	ProtectionReasonNewest                 = ProtectionReason + "because it is one of the newest protectNewestN resources of its kind"
+	ProtectionReasonControllerRef          = ProtectionReason + "because it is controlled by the composite"
+	ProtectionReasonReferenced             = ProtectionReason + "because it is referenced by the composite"
+	ProtectionReasonCrossComposition       = ProtectionReason + "because a protected resource of another composition depends on it"
+	ProtectionReasonConnectionSecret       = ProtectionReason + "because it writes a connection secret"
+	ProtectionReasonConnectionSource       = ProtectionReason + "because it provides connection details of the protected composite"
+	ProtectionReasonFieldMatch             = ProtectionReason + "because a field matches protectIfFieldMatches"
+	ProtectionReasonRule                   = ProtectionReason + "because it matches a protection rule"
+	ProtectionReasonRequiredSelector       = ProtectionReason + "because it matches requiredSelectors"
 	ProtectionReasonOperation              = ProtectionReason + "by an Operation"
 	ProtectionReasonWatchOperation         = ProtectionReason + "by a WatchOperation"
 	ProtectionV1GroupVersion               = apiextensionsv1beta1.Group + "/" + apiextensionsv1beta1.Version
+	// LabelManagedBy is set on every resource generated by this function.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// ManagedByValue is the value of LabelManagedBy.
+	ManagedByValue = "function-deletion-protection"
 	// UsageNameSuffix is the suffix applied when generating Usage names.
 	UsageNameSuffix = "fn-protection"
 	// RequirementsNameWatchedResource is the name passed by a WatchOperation.
 	RequirementsNameWatchedResource = "ops.crossplane.io/watched-resource"
 	// V1ModeError Error when trying to protect a namespaced resource when in v1 mode.
 	V1ModeError = "cannot protect namespaced resource (kind: %s, name: %s, namespace: %s) with enableV1Mode=true. v1 usages only support cluster-scoped resources."
+	// AnnotationProtectedBy is set on protected desired resources to the name of
+	// the Usage protecting them.
+	AnnotationProtectedBy = "protection.fn.crossplane.io/protected-by"
+	// AnnotationOnRelease documents the intended behavior when a Usage is
+	// released.
+	AnnotationOnRelease = "protection.fn.crossplane.io/on-release"
+	// AnnotationReason overrides the reason of the Usage protecting the
+	// annotated resource, subject to the Input's ReasonPrecedence.
+	AnnotationReason = "protection.fn.crossplane.io/reason"
+	// AnnotationRunbook links the procedure to safely remove protection of
+	// the annotated resource. It is appended to the reason of its Usage.
+	AnnotationRunbook = "protection.fn.crossplane.io/runbook"
+	// DefaultPriorityAnnotation holds the priority of a resource if the Input
+	// does not configure a PriorityAnnotation.
+	DefaultPriorityAnnotation = "protection.fn.crossplane.io/priority"
+	// AnnotationProtectedAt records when protection by a Usage started if
+	// IncludeTimestampInReason is set.
+	AnnotationProtectedAt = "protection.fn.crossplane.io/protected-at"
+	// LabelNamespaceGroup is set to the namespace of generated Usages if
+	// ProtectNamespaceAsGroup is set.
+	LabelNamespaceGroup = "protection.fn.crossplane.io/namespace-group"
+	// AnnotationSnapshot records the resources protected when the composite
+	// started being deleted.
+	AnnotationSnapshot = "protection.fn.crossplane.io/snapshot"
+	// AnnotationProtected is set to the protection reason on protected
+	// resources when ProtectionMode is annotation.
+	AnnotationProtected = "protection.fn.crossplane.io/protected"
+	// RedactedReplacement replaces redacted parts of a Usage reason.
+	RedactedReplacement = "[REDACTED]"
+	// ConditionTypeProtectionIncomplete is set when protection was requested
+	// but could not be applied to all resources.
+	ConditionTypeProtectionIncomplete = "DeletionProtectionIncomplete"
+	// ConditionReasonResourcesSkipped is the reason for an incomplete
+	// protection condition.
+	ConditionReasonResourcesSkipped = "ResourcesSkipped"
+	// ConditionTypeProtectionDeferred is set when protection is recorded as
+	// annotations and enforced outside of the function.
+	ConditionTypeProtectionDeferred = "DeletionProtectionDeferred"
+	// ConditionReasonAnnotationMode is the reason for a deferred protection
+	// condition.
+	ConditionReasonAnnotationMode = "AnnotationMode"
+	// PendingTTL is the response TTL used while protection of some resources
+	// is pending, so it is retried sooner than the default TTL.
+	PendingTTL = 15 * time.Second
+	// ConditionTypeDeletionProtection reports whether protection was applied
+	// when ContinueOnError or WaitForXRReady is enabled.
+	ConditionTypeDeletionProtection = "DeletionProtection"
+	// ConditionReasonProtectionApplied is the reason for applied protection.
+	ConditionReasonProtectionApplied = "ProtectionApplied"
+	// ConditionReasonWaitingForReady is the reason protection is not applied
+	// yet when WaitForXRReady is enabled.
+	ConditionReasonWaitingForReady = "WaitingForReady"
+	// ConditionReasonProtectionFailed is the reason for protection that could
+	// not be applied.
+	ConditionReasonProtectionFailed = "ProtectionFailed"
+	// ConditionTypeConfigValid reports whether the function's Input was
+	// accepted.
+	ConditionTypeConfigValid = "ConfigValid"
+	// ConditionReasonInputAccepted is the reason for a valid configuration.
+	ConditionReasonInputAccepted = "InputAccepted"
+	// ConditionReasonInvalidInput is the reason for an invalid configuration.
+	ConditionReasonInvalidInput = "InvalidInput"
+	// SkipReasonNoName is reported when an observed resource has not been named yet.
+	SkipReasonNoName = "observed resource has no name yet, protection will be retried on a later reconcile"
+	// SkipReasonNotReadyLongEnough is reported when an observed resource has not
+	// been Ready for MinReadyDurationSeconds yet.
+	SkipReasonNotReadyLongEnough = "observed resource has not been ready for minReadyDurationSeconds yet, protection will be retried on a later reconcile"
+	// SkipReasonWebhookFailed is reported when the decision webhook failed and
+	// DecisionWebhookFailurePolicy is skip.
+	SkipReasonWebhookFailed = "decision webhook failed, protection will be retried on a later reconcile"
 )
 
+// SkippedResource is a resource that requested protection but could not be
+// protected during this run.
+type SkippedResource struct {
+	Name   resource.Name
+	Reason string
+}
+
+// ProtectionResult is a resource that requested protection but was not
+// protected during a run.
+type ProtectionResult struct {
+	SkippedResource
+
+	// Dropped is true if a Usage was generated but failed validation.
+	Dropped bool
+
+	// RenamedTo is the key a Usage was stored under instead of Name, because
+	// another desired composed resource already exists under Name. The
+	// resource is still protected.
+	RenamedTo resource.Name
+}
+
+// SkippedMessage describes the supplied skipped resources.
+func SkippedMessage(skipped []SkippedResource) string {
+	msgs := make([]string, 0, len(skipped))
+	for _, s := range skipped {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", s.Name, s.Reason))
+	}
+	return "protection could not be applied to: " + strings.Join(msgs, "; ")
+}
+
 // RunFunction runs the Function.
-func (f *Function) RunFunction(_ context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+func (f *Function) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
 	f.log.Info("Running function", "tag", req.GetMeta().GetTag())
 
 	rsp := response.To(req, response.DefaultTTL)
 
 	in := &v1beta1.Input{}
 	if err := request.GetInput(req, in); err != nil {
-		response.Fatal(rsp, errors.Wrapf(err, "cannot get Function input from %T", req))
+		invalidInput(rsp, in, errors.Wrapf(err, "cannot get Function input from %T", req))
+		return rsp, nil
+	}
+	if ref := in.PolicyConfigMapRef; ref != nil {
+		require(rsp, RequirementsNamePolicy, PolicySelector(ref))
+		required, err := request.GetRequiredResources(req)
+		if err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot get required resources"))
+			return rsp, nil
+		}
+		// The policy ConfigMap is only supplied once Crossplane has seen the
+		// requirement, until then the inline Input is used.
+		policy := required[RequirementsNamePolicy]
+		if len(policy) == 0 {
+			f.log.Debug("policy ConfigMap not found", "namespace", ref.Namespace, "name", ref.Name)
+		}
+		for _, p := range policy {
+			if err := MergePolicy(in, req.GetInput().AsMap(), p.Resource); err != nil {
+				invalidInput(rsp, in, err)
+				return rsp, nil
+			}
+		}
+	}
+	if sel := in.ProtectionPolicySelector; sel != nil {
+		require(rsp, RequirementsNameProtectionPolicies, ProtectionPoliciesSelector(sel))
+		required, err := request.GetRequiredResources(req)
+		if err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot get required resources"))
+			return rsp, nil
+		}
+		// Policies are only supplied once Crossplane has seen the
+		// requirement, until then only the inline rules are used.
+		if err := MergeProtectionPolicies(in, required[RequirementsNameProtectionPolicies]); err != nil {
+			invalidInput(rsp, in, err)
+			return rsp, nil
+		}
+	}
+	if err := ValidateInput(in); err != nil {
+		invalidInput(rsp, in, errors.Wrap(err, "invalid Function input"))
 		return rsp, nil
 	}
+	for _, sel := range in.RequiredSelectors {
+		require(rsp, sel.Name, RequiredResourceSelector(sel))
+	}
 	if in.CacheTTL != "" {
 		dur, err := time.ParseDuration(in.CacheTTL)
 		if err != nil {
-			response.Fatal(rsp, errors.Wrapf(err, "cannot set cacheTTL"))
+			invalidInput(rsp, in, errors.Wrapf(err, "cannot set cacheTTL"))
 			return rsp, nil
 		}
 		rsp.Meta.Ttl = durationpb.New(dur)
 	}
+	if !in.LintOnly {
+		response.ConditionTrue(rsp, successConditionType(in), successConditionReason(in))
+	}
+
+	env, _ := GetEnvironment(req)
+	if !EnvironmentEnablesProtection(env, in.EnvironmentEnabledPath) {
+		f.log.Info("protection disabled by environment", "path", in.EnvironmentEnabledPath)
+		response.Normalf(rsp, "protection disabled by environment value %q", in.EnvironmentEnabledPath)
+		return rsp, nil
+	}
+	if name, ok := EnvironmentProtected(env, in); !ok {
+		f.log.Info("protection disabled for environment", "environment", name)
+		response.Normalf(rsp, "protection disabled for environment %q", name)
+		return rsp, nil
+	}
+
+	if CompositeMissing(req) {
+		if in.OnMissingXR == v1beta1.MissingXRPolicyFail {
+			response.Fatal(rsp, errors.New("observed composite resource is missing"))
+			return rsp, nil
+		}
+		f.log.Info("not protecting resources without an observed composite")
+		response.Normal(rsp, "protection skipped because the observed composite does not exist yet")
+		return rsp, nil
+	}
 
 	desiredComposite, err := request.GetDesiredCompositeResource(req)
 	if err != nil {
@@ -77,6 +303,19 @@ func (f *Function) RunFunction(_ context.Context, req *fnv1.RunFunctionRequest)
 		response.Fatal(rsp, errors.Wrap(err, "cannot get observed composite"))
 		return rsp, nil
 	}
+	if name := CompositionName(observedComposite); len(in.OnlyForCompositions) > 0 && !slices.Contains(in.OnlyForCompositions, name) {
+		f.log.Info("protection disabled for composition", "composition", name)
+		response.Normalf(rsp, "protection disabled for composition %q", name)
+		return rsp, nil
+	}
+
+	if in.CrossCompositionBy {
+		for i, ref := range in.CrossCompositionRefs {
+			if ref.IdentifyBy == v1beta1.CrossCompositionIdentityExternalName {
+				require(rsp, CrossCompositionRequirement(i), CrossCompositionSelector(ref, observedComposite.Resource.GetNamespace()))
+			}
+		}
+	}
 
 	observedComposed, err := request.GetObservedComposedResources(req)
 	if err != nil {
@@ -84,129 +323,777 @@ func (f *Function) RunFunction(_ context.Context, req *fnv1.RunFunctionRequest)
 		return rsp, nil
 	}
 
+	if in.WaitForXRReady && !CompositeReady(observedComposite) && !ProtectionApplied(observedComposed) {
+		f.log.Info("not protecting resources until the composite is ready", "name", observedComposite.Resource.GetName())
+		targetConditions(response.ConditionFalse(rsp, ConditionTypeDeletionProtection, ConditionReasonWaitingForReady).
+			WithMessage("protection is applied once the composite is ready"), in)
+		return rsp, nil
+	}
+
 	desiredComposed, err := request.GetDesiredComposedResources(req)
 	if err != nil {
 		response.Fatal(rsp, errors.Wrapf(err, "cannot get desired composed resources from %T", req))
 		return rsp, nil
 	}
 
-	// Process Composed Resources
-	var protectedCount int
-	composedUsages, err := f.ProtectComposedResources(desiredComposed, observedComposed, in.EnableV1Mode)
+	// Protect any required resources that are present.
+	requiredResources, err := request.GetRequiredResources(req)
 	if err != nil {
-		response.Fatal(rsp, errors.Wrap(err, "cannot process composed resources"))
+		response.Fatal(rsp, errors.Wrap(err, "cannot get required resources"))
+		return rsp, nil
+	}
+	delete(requiredResources, RequirementsNamePolicy)
+	delete(requiredResources, RequirementsNameProtectionPolicies)
+
+	unlabeled := UnlabeledOfKinds(desiredComposed, observedComposed, in)
+	for _, name := range unlabeled {
+		response.Warning(rsp, errors.Errorf("composed resource %q is of a critical kind but is not labeled with %s", name, ProtectionLabelBlockDeletion))
+	}
+	if in.LintOnly {
+		if len(unlabeled) == 0 {
+			response.Normal(rsp, "all composed resources of a critical kind are labeled with "+ProtectionLabelBlockDeletion)
+		}
+		return rsp, nil
+	}
+
+	desired, results, err := f.computeDesired(ctx, in, observedComposite, desiredComposite, observedComposed, desiredComposed, requiredResources)
+	var incomplete []SkippedResource
+	for _, r := range results {
+		switch {
+		case r.RenamedTo != "":
+			response.Warning(rsp, errors.Errorf("cannot store usage as %q: %s, using %q instead", r.Name, r.Reason, r.RenamedTo))
+			continue
+		case r.Dropped:
+			response.Warning(rsp, errors.Errorf("dropping invalid usage %q: %s", r.Name, r.Reason))
+		default:
+			response.Warning(rsp, errors.Errorf("cannot protect composed resource %q: %s", r.Name, r.Reason))
+		}
+		incomplete = append(incomplete, r.SkippedResource)
+	}
+	if len(incomplete) > 0 {
+		// Skipped resources are retried on the next run, which should happen
+		// as soon as they have settled.
+		if rsp.GetMeta().GetTtl().AsDuration() > PendingTTL {
+			rsp.Meta.Ttl = durationpb.New(PendingTTL)
+		}
+		targetConditions(response.ConditionTrue(rsp, ConditionTypeProtectionIncomplete, ConditionReasonResourcesSkipped).
+			WithMessage(SkippedMessage(incomplete)), in)
+	}
+	switch {
+	case err != nil && in.ContinueOnError:
+		// Usages generated by previous runs are kept, so that a failure does
+		// not release protection.
+		response.Warning(rsp, err)
+		targetConditions(response.ConditionFalse(rsp, ConditionTypeDeletionProtection, ConditionReasonProtectionFailed).WithMessage(err.Error()), in)
+		desired = RetainManaged(desiredComposed, observedComposed)
+	case err != nil:
+		response.Fatal(rsp, err)
+		return rsp, nil
+	case in.ContinueOnError:
+		targetConditions(response.ConditionTrue(rsp, ConditionTypeDeletionProtection, ConditionReasonProtectionApplied), in)
+	}
+
+	// The composite is only updated if its protection was recorded on it.
+	annotations := desiredComposite.Resource.GetAnnotations()
+	_, annotated := annotations[AnnotationProtected]
+	_, expiring := annotations[AnnotationExpiresAt]
+	if (annotated && in.ProtectionMode == v1beta1.ProtectionModeAnnotation) || expiring {
+		if err := response.SetDesiredCompositeResource(rsp, desiredComposite); err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot set desired composite"))
+			return rsp, nil
+		}
+	}
+	if in.ProtectionMode == v1beta1.ProtectionModeAnnotation {
+		targetConditions(response.ConditionTrue(rsp, ConditionTypeProtectionDeferred, ConditionReasonAnnotationMode).
+			WithMessage("deletion protection is recorded in the "+AnnotationProtected+" annotation and enforced by an admission webhook"), in)
+	}
+
+	var d Decisions
+	if in.ExportDecisions || in.AuditTrail || in.EmitKubeEvent || in.NotifyOnProtect {
+		d = BuildDecisions(desiredComposite, desired, observedComposite, incomplete)
+	}
+	if in.EmitKubeEvent {
+		var previous *unstructured.Unstructured
+		if o, ok := observedComposed[eventResourceName]; ok && o.Resource != nil {
+			previous = &o.Resource.Unstructured
+		}
+		desired[eventResourceName] = &resource.DesiredComposed{Resource: asComposed(GenerateEvent(f.now(), observedComposite, previous, d))}
+	}
+
+	if err := response.SetDesiredComposedResources(rsp, desired); err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot set desired resources"))
 		return rsp, nil
 	}
-	maps.Copy(desiredComposed, composedUsages)
-	protectedCount += len(composedUsages)
+
+	if in.ExportDecisions {
+		v, err := d.AsValue()
+		if err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot encode protection decisions"))
+			return rsp, nil
+		}
+		response.SetContextKey(rsp, ContextKeyDecisions, v)
+	}
+	if in.NotifyOnProtect {
+		if n, ok := BuildNotification(observedComposite, observedComposed, d); ok {
+			v, err := n.AsValue()
+			if err != nil {
+				response.Fatal(rsp, errors.Wrap(err, "cannot encode protection notification"))
+				return rsp, nil
+			}
+			response.SetContextKey(rsp, ContextKeyNotification, v)
+		}
+	}
+	if in.AuditTrail {
+		msg, err := BuildAudit(f.now(), observedComposite, d).Message()
+		if err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot encode protection audit trail"))
+			return rsp, nil
+		}
+		response.Normal(rsp, msg)
+	}
+
+	return rsp, nil
+}
+
+// require adds a required resource to the response's requirements.
+func require(rsp *fnv1.RunFunctionResponse, name string, sel *fnv1.ResourceSelector) {
+	if rsp.Requirements == nil {
+		rsp.Requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+	}
+	rsp.Requirements.Resources[name] = sel
+}
+
+// invalidInput reports a Function Input that could not be accepted, both as a
+// fatal result and as a false success condition on the composite.
+func invalidInput(rsp *fnv1.RunFunctionResponse, in *v1beta1.Input, err error) {
+	response.ConditionFalse(rsp, successConditionType(in), ConditionReasonInvalidInput).WithMessage(err.Error())
+	response.Fatal(rsp, err)
+}
+
+// successConditionType returns the Input's SuccessConditionType, or
+// ConditionTypeConfigValid if it is unset or invalid.
+func successConditionType(in *v1beta1.Input) string {
+	if in.SuccessConditionType == "" || len(validation.IsQualifiedName(in.SuccessConditionType)) > 0 {
+		return ConditionTypeConfigValid
+	}
+	return in.SuccessConditionType
+}
+
+// successConditionReason returns the Input's SuccessConditionReason, or
+// ConditionReasonInputAccepted if it is unset.
+func successConditionReason(in *v1beta1.Input) string {
+	if in.SuccessConditionReason == "" {
+		return ConditionReasonInputAccepted
+	}
+	return in.SuccessConditionReason
+}
+
+// expireUsages drops the Usages whose protection has expired.
+func (f *Function) expireUsages(usages map[resource.Name]*resource.DesiredComposed) {
+	for _, n := range ExpireUsages(usages, f.now()) {
+		f.log.Info("protection expired", "name", n)
+	}
+}
+
+// targetConditions sets the target of a protection condition according to the
+// Input's ConditionTarget.
+func targetConditions(c *response.ConditionOption, in *v1beta1.Input) {
+	if in.ConditionTarget == v1beta1.ConditionTargetComposite {
+		c.TargetComposite()
+		return
+	}
+	c.TargetCompositeAndClaim()
+}
+
+// computeDesired computes the desired composed resources for a run, including
+// any generated Usages. Resources that requested protection but were not
+// protected are returned as results. Results are also returned alongside an
+// error.
+func (f *Function) computeDesired(ctx context.Context, in *v1beta1.Input, observedComposite, desiredComposite *resource.Composite, observedComposed map[resource.Name]resource.ObservedComposed, desiredComposed map[resource.Name]*resource.DesiredComposed, requiredResources map[string][]resource.Required) (map[resource.Name]*resource.DesiredComposed, []ProtectionResult, error) {
+	deleting := observedComposite != nil && observedComposite.Resource.GetDeletionTimestamp() != nil
+	// A snapshot of the protected resources is recorded before protection is
+	// released.
+	if deleting && in.ReleasePolicy == v1beta1.ReleasePolicyReleaseOnXRDelete && (!in.SnapshotOnDeletion || SnapshotRecorded(observedComposed)) {
+		f.log.Info("releasing protection of deleting composite", "name", observedComposite.Resource.GetName())
+		if n := PauseProtected(desiredComposed, observedComposed, nil, false); n > 0 {
+			f.log.Debug("resources resumed", "total", n)
+		}
+		return ReleaseManaged(desiredComposed), nil, nil
+	}
+
+	// The reason redactions are compiled once and shared by every Usage
+	// generated during the run.
+	redact := CompileRedactions(in.RedactReasonPatterns)
+	matchers, err := CompileMatchers(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Expiries are recorded on the resources Usages protect.
+	protected := ProtectedResources(observedComposite, desiredComposite, observedComposed, desiredComposed, requiredResources)
+
+	// Generated Usages are collected separately so they can be validated
+	// before being added to the desired composed resources.
+	usages := map[resource.Name]*resource.DesiredComposed{}
+	var results []ProtectionResult
+
+	// Process Composed Resources
+	composedUsages, skipped, err := f.ProtectComposedResources(ctx, observedComposite, desiredComposed, observedComposed, in, matchers, redact)
+	if err != nil {
+		return nil, results, errors.Wrap(err, "cannot process composed resources")
+	}
+	for _, s := range skipped {
+		results = append(results, ProtectionResult{SkippedResource: s})
+	}
+	// Expired composed resources no longer cause the composite to be
+	// protected.
+	SetExpiries(composedUsages, observedComposed, protected, in, f.now())
+	f.expireUsages(composedUsages)
+	maps.Copy(usages, composedUsages)
+	if in.ProtectionMode != v1beta1.ProtectionModeAnnotation {
+		maps.Copy(usages, SubresourceUsages(composedUsages, in, redact))
+		cross, err := ProtectCrossComposition(composedUsages, observedComposed, requiredResources, in, redact)
+		if err != nil {
+			return nil, results, errors.Wrap(err, "cannot protect cross-composition references")
+		}
+		maps.Copy(usages, cross)
+	}
 
 	// Create a Usage on the Composite:
 	// - If any resources in the Composition are being protected
 	// - If the Composite has the label
-	compositeUsage, err := f.ProtectComposite(observedComposite, desiredComposite, protectedCount, in.EnableV1Mode)
-	if err != nil {
-		response.Fatal(rsp, errors.Wrap(err, "cannot protect composite resource"))
-		return rsp, nil
+	// - Unless it must have composed resources and has none yet
+	var compositeUsage map[resource.Name]*resource.DesiredComposed
+	if in.RequireComposedForXRProtection && !HasComposed(observedComposed) {
+		f.log.Debug("not protecting composite without composed resources", "name", observedComposite.Resource.GetName())
+	} else {
+		compositeUsage, err = f.ProtectComposite(observedComposite, desiredComposite, CountTriggers(composedUsages, in.XRProtectionTriggerKinds), in, matchers, redact)
+		if err != nil {
+			return nil, results, errors.Wrap(err, "cannot protect composite resource")
+		}
 	}
-	if compositeUsage != nil {
-		maps.Copy(desiredComposed, compositeUsage)
-		protectedCount++
+	maps.Copy(usages, compositeUsage)
+	if in.ProtectConnectionSources && len(compositeUsage) > 0 {
+		sources, err := ProtectConnectionSources(desiredComposed, observedComposed, usages, in, redact)
+		if err != nil {
+			return nil, results, errors.Wrap(err, "cannot protect connection sources")
+		}
+		maps.Copy(composedUsages, sources)
+		maps.Copy(usages, sources)
+	}
+	if ns := ClaimNamespace(observedComposite); ns != "" {
+		for _, u := range usages {
+			meta.AddAnnotations(u.Resource, map[string]string{AnnotationClaimNamespace: ns})
+		}
+	}
+	if labels := InheritedLabels(observedComposite, in.InheritXRLabels); len(labels) > 0 {
+		for _, u := range usages {
+			meta.AddLabels(u.Resource, labels)
+		}
 	}
 
-	// Protect any required resources that are present.
-	requiredResources, err := request.GetRequiredResources(req)
-	if err != nil {
-		response.Fatal(rsp, errors.Wrap(err, "cannot get required resources"))
-		return rsp, nil
+	if in.ProtectReferencedSecrets {
+		ro, err := f.ProtectReferencedObjects(observedComposite, in, redact)
+		if err != nil {
+			return nil, results, errors.Wrap(err, "cannot protect referenced objects")
+		}
+		maps.Copy(usages, ro)
 	}
 
+	// Protect any required resources that are present.
 	if len(requiredResources) > 0 {
 		f.log.Debug("processing required resources")
-		rr, err := ProtectRequiredResources(requiredResources)
+		rr, err := ProtectRequiredResources(requiredResources, in, redact)
 		if err != nil {
-			response.Fatal(rsp, errors.Wrap(err, "cannot process required resources"))
-			return rsp, nil
+			return nil, results, errors.Wrap(err, "cannot process required resources")
 		}
-		maps.Copy(desiredComposed, rr)
-		protectedCount += len(rr)
+		maps.Copy(usages, rr)
 	}
 
-	if err := response.SetDesiredComposedResources(rsp, desiredComposed); err != nil {
-		response.Fatal(rsp, errors.Wrap(err, "cannot set desired resources"))
-		return rsp, nil
+	if in.PreserveExistingReason {
+		n := PreserveReasons(usages, observedComposed)
+		f.log.Debug("usage reasons preserved", "total", n)
+	}
+	if in.IncludeTimestampInReason {
+		StampReasons(usages, observedComposed, f.now())
+	}
+	if in.ProtectNamespaceAsGroup {
+		n := GroupByNamespace(usages)
+		f.log.Debug("usages grouped by namespace", "total", n)
 	}
-	f.log.Debug("usages created", "total", protectedCount)
 
-	return rsp, nil
+	for _, s := range ValidateUsages(usages) {
+		f.log.Info("dropping invalid usage", "name", s.Name, "reason", s.Reason)
+		results = append(results, ProtectionResult{SkippedResource: s, Dropped: true})
+	}
+	if n := DedupeUsages(usages); n > 0 {
+		f.log.Debug("dropped duplicate usages", "total", n)
+	}
+	if in.RespectUserUsages == nil || *in.RespectUserUsages {
+		for _, n := range RespectUserUsages(usages, desiredComposed) {
+			f.log.Debug("resource is protected by a user supplied usage", "name", n)
+		}
+	}
+	SetExpiries(usages, observedComposed, protected, in, f.now())
+	f.expireUsages(usages)
+	if deleting && in.SnapshotOnDeletion {
+		if err := StampSnapshot(usages); err != nil {
+			return nil, results, errors.Wrap(err, "cannot record protection snapshot")
+		}
+	}
+	if err := CheckMaxProtected(len(usages), in.MaxProtectedPerRun); err != nil {
+		return nil, results, err
+	}
+	if in.ProtectionMode == v1beta1.ProtectionModeAnnotation {
+		n := AnnotateInsteadOfUsages(desiredComposite, desiredComposed, compositeUsage, composedUsages, usages)
+		f.log.Debug("resources annotated", "total", n)
+	}
+	results = append(results, ResolveCollisions(usages, desiredComposed)...)
+	// Only resources that are still protected by a Usage are paused.
+	if n := PauseProtected(desiredComposed, observedComposed, RetainedUsages(composedUsages, usages), in.PauseOnProtect); n > 0 {
+		f.log.Debug("resources resumed", "total", n)
+	}
+	if in.AnnotateUsageOrder {
+		AnnotateUsageOrder(usages, &observedComposite.Resource.Unstructured)
+	}
+	tracking, err := TrackClusterUsages(usages, in.TrackingNamespace)
+	if err != nil {
+		return nil, results, errors.Wrap(err, "cannot generate tracking ConfigMaps")
+	}
+
+	desired := make(map[resource.Name]*resource.DesiredComposed, len(desiredComposed)+len(usages)+len(tracking))
+	maps.Copy(desired, desiredComposed)
+	maps.Copy(desired, usages)
+	maps.Copy(desired, tracking)
+	f.log.Debug("usages created", "total", len(usages))
+
+	return desired, results, nil
 }
 
 // ProtectResource determines if a Resource requires deletion protection.
-func ProtectResource(u *unstructured.Unstructured) bool {
+func ProtectResource(u *unstructured.Unstructured, in *v1beta1.Input) bool {
 	if u == nil || u.Object == nil {
 		return false
 	}
-	labels := u.GetLabels()
+	labels, _, err := unstructured.NestedStringMap(u.Object, "metadata", "labels")
+	if err != nil {
+		// Labels that cannot be read only enable protection when failing closed.
+		return in.FailClosed && (len(in.FailClosedKinds) == 0 || MatchesGroupKind(u, in.FailClosedKinds))
+	}
+	for _, key := range in.PresenceOnlyLabels {
+		if _, ok := labels[key]; ok {
+			return true
+		}
+	}
 	val, ok := labels[ProtectionLabelBlockDeletion]
-	if ok && strings.EqualFold(val, "true") {
+	return ok && protectsValue(val, in)
+}
+
+// protectsValue returns true if the supplied value of the protection label
+// enables protection. It must be exactly "true" if StrictTrueOnly is set.
+func protectsValue(val string, in *v1beta1.Input) bool {
+	if in.StrictTrueOnly {
+		return val == "true"
+	}
+	return strings.EqualFold(val, "true")
+}
+
+// LabelProtected returns true if the protection label requests protection in
+// the desired and observed states selected by the Input's LabelSource.
+func LabelProtected(desired, observed *unstructured.Unstructured, in *v1beta1.Input) bool {
+	switch in.LabelSource {
+	case v1beta1.LabelSourceDesired:
+		return ProtectResource(desired, in)
+	case v1beta1.LabelSourceObserved:
+		return ProtectResource(observed, in)
+	case v1beta1.LabelSourceBoth:
+		return ProtectResource(desired, in) && ProtectResource(observed, in)
+	default:
+		return ProtectResource(desired, in) || ProtectResource(observed, in)
+	}
+}
+
+// IsManaged returns true if the resource was generated by this function.
+func IsManaged(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	return u.GetLabels()[LabelManagedBy] == ManagedByValue
+}
+
+// ProtectionDisabled returns true if the resource explicitly sets the protection
+// label to a value that does not enable protection, following the Input's
+// StrictTrueOnly.
+func ProtectionDisabled(u *unstructured.Unstructured, in *v1beta1.Input) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	val, ok := u.GetLabels()[ProtectionLabelBlockDeletion]
+	return ok && !protectsValue(val, in)
+}
+
+// OptedOut returns true if the resource opts out of DefaultProtect, either by
+// setting the protection label to a value other than "true" or by setting the
+// configured OptOutAnnotation to a true value.
+func OptedOut(u *unstructured.Unstructured, in *v1beta1.Input) bool {
+	if ProtectionDisabled(u, in) {
 		return true
 	}
-	return false
+	if u == nil || u.Object == nil || in.OptOutAnnotation == "" {
+		return false
+	}
+	val, ok := u.GetAnnotations()[in.OptOutAnnotation]
+	if !ok {
+		return false
+	}
+	optOut, err := strconv.ParseBool(val)
+	return err == nil && optOut
 }
 
-// ProtectComposedResources creates Usages for Composed Resources.
-func (f *Function) ProtectComposedResources(desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, enableV1Mode bool) (map[resource.Name]*resource.DesiredComposed, error) {
-	dc := map[resource.Name]*resource.DesiredComposed{}
+// matchesRevision returns true if the resource was produced by the configured
+// composition revision, or by the composite's current revision if none is
+// configured.
+func matchesRevision(u *unstructured.Unstructured, xr *resource.Composite, in *v1beta1.Input) bool {
+	rev := in.ProtectRevision
+	if rev == "" {
+		rev = CompositionRevision(xr)
+	}
+	return rev != "" && u.GetLabels()[LabelCompositionRevision] == rev
+}
+
+// ComposedProtectionReason determines if a Composed Resource requires deletion
+// protection and returns the reason to record on its Usage. The supplied
+// matchers are the compiled regular expressions and label selectors of the
+// Input.
+func ComposedProtectionReason(desired, observed *unstructured.Unstructured, in *v1beta1.Input, m Matchers) (string, bool) {
+	// The label can either be defined in the pipeline or applied outside of Crossplane
+	if LabelProtected(desired, observed, in) {
+		return ProtectionReasonLabel, true
+	}
+	if MatchesLabelEquals(desired, in.MatchLabelEquals) || MatchesLabelEquals(observed, in.MatchLabelEquals) {
+		return ProtectionReasonLabelValue, true
+	}
+	if MatchesAllSelectors(desired, m.AllSelectors) || MatchesAllSelectors(observed, m.AllSelectors) {
+		return ProtectionReasonAllSelectors, true
+	}
+	if InSharedGroup(desired, in.SharedProtectionGroupLabel) || InSharedGroup(observed, in.SharedProtectionGroupLabel) {
+		return ProtectionReasonSharedGroup, true
+	}
+	if in.DefaultProtect && !OptedOut(desired, in) && !OptedOut(observed, in) {
+		return ProtectionReasonDefault, true
+	}
+	if MatchesOwnerKind(observed, in.ProtectByOwnerKinds) {
+		return ProtectionReasonOwnerKind, true
+	}
+	if MatchesProvider(desired, in.ProtectProviders) || MatchesProvider(observed, in.ProtectProviders) {
+		return ProtectionReasonProvider, true
+	}
+	if PriorityAtLeast(desired, in.PriorityAnnotation, in.ProtectIfPriorityAtLeast) || PriorityAtLeast(observed, in.PriorityAnnotation, in.ProtectIfPriorityAtLeast) {
+		return ProtectionReasonPriority, true
+	}
+	if in.ProtectNamespaceAsGroup && (desired.GetNamespace() != "" || observed.GetNamespace() != "") {
+		return ProtectionReasonNamespaceGroup, true
+	}
+	if MatchesExpressions(desired, in.ProtectWhen) || MatchesExpressions(observed, in.ProtectWhen) {
+		return ProtectionReasonExpression, true
+	}
+	// Status is only reported on the observed resource.
+	if MatchesFieldValue(observed, in.ProtectIfStatusPath, in.ProtectIfStatusEquals) {
+		return ProtectionReasonStatus, true
+	}
+	if ConditionTrue(observed, in.ProtectIfConditionTrue) {
+		return ProtectionReasonCondition, true
+	}
+	if Sampled(observed, in.SampleProtectPercent) {
+		return ProtectionReasonSample, true
+	}
+	if MatchesExternalName(desired, m.ExternalName) || MatchesExternalName(observed, m.ExternalName) {
+		return ProtectionReasonExternalName, true
+	}
+	if MatchesFieldRegex(desired, m.Fields) || MatchesFieldRegex(observed, m.Fields) {
+		return ProtectionReasonFieldMatch, true
+	}
+	if reason, ok := RuleReason(desired, observed, in); ok {
+		return reason, true
+	}
+	if in.ProtectIfConnectionSecret && (HasConnectionSecret(desired) || HasConnectionSecret(observed)) {
+		return ProtectionReasonConnectionSecret, true
+	}
+	return "", false
+}
+
+// RuleReason returns the reason of the first ProtectionRule the desired or
+// observed resource matches. Rules without a reason use ProtectionReasonRule.
+func RuleReason(desired, observed *unstructured.Unstructured, in *v1beta1.Input) (string, bool) {
+	if len(in.ProtectionRules) == 0 {
+		return "", false
+	}
+	// Exclusions apply if either state is of an excluded kind.
+	if MatchesGroupKind(desired, in.ExcludeKinds) || MatchesGroupKind(observed, in.ExcludeKinds) {
+		return "", false
+	}
+	r, ok := MatchingRule(observed, in.ProtectionRules)
+	if !ok {
+		r, ok = MatchingRule(desired, in.ProtectionRules)
+	}
+	if !ok {
+		return "", false
+	}
+	if r.Reason == "" {
+		return ProtectionReasonRule, true
+	}
+	return r.Reason, true
+}
+
+// ProtectComposedResources creates Usages for Composed Resources. Resources
+// that request protection but cannot be protected yet are returned as skipped.
+// If AnnotateProtected or ClearLabelAfterProtect are set the desired resources
+// are updated in place. The supplied matchers and redactions are compiled from
+// the Input by the caller.
+func (f *Function) ProtectComposedResources(ctx context.Context, observedComposite *resource.Composite, desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, in *v1beta1.Input, matchers Matchers, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, []SkippedResource, error) {
+	dc := make(map[resource.Name]*resource.DesiredComposed, len(desiredComposed))
+	var skipped []SkippedResource
+	var newest map[resource.Name]bool
+	if in.ProtectNewestKind != nil {
+		newest = NewestOfKind(observedComposed, *in.ProtectNewestKind, in.ProtectNewestN, in.CreationTimeAnnotation)
+	}
+	referenced := ReferencedBy(observedComposite, observedComposed, in.RefPaths)
+	annotated, err := CompileAnnotationRegex(in.MatchAnnotationRegex)
+	if err != nil {
+		return dc, nil, err
+	}
+	spec, _ := CompositeProtectionSpec(observedComposite, in.ProtectionSpecPath)
+	decideCtx, cancel := DecisionContext(ctx, in)
+	defer cancel()
 	for name, desired := range desiredComposed {
 		// A Usage will be created if there is an Observed Resource on the Cluster
-		if observed, ok := observedComposed[name]; ok {
-			// The label can either be defined in the pipeline or applied outside of Crossplane
-			if ProtectResource(&desired.Resource.Unstructured) || ProtectResource(&observed.Resource.Unstructured) {
-				// Validate that v1 mode is not used with namespaced resources
-				if enableV1Mode && observed.Resource.GetNamespace() != "" {
-					return dc, errors.Errorf(V1ModeError, observed.Resource.GetKind(), observed.Resource.GetName(), observed.Resource.GetNamespace())
-				}
-				f.log.Debug("protecting Composed resource", "kind", observed.Resource.GetKind(), "name", observed.Resource.GetName(), "namespace", observed.Resource.GetNamespace())
-				usage := GenerateUsage(&observed.Resource.Unstructured, ProtectionReasonLabel, enableV1Mode)
-				usageComposed := composed.New()
-				if err := convertViaJSON(usageComposed, usage); err != nil {
-					return dc, err
+		observed, ok := observedComposed[name]
+		if !ok {
+			if !in.ProtectDesiredOnly {
+				continue
+			}
+			observed = desiredAsObserved(desired, observedComposite)
+		}
+		desiredOnly := !ok
+		// Resources generated by an earlier run of this function, e.g. from
+		// another pipeline step, are never protected themselves.
+		if IsManaged(&desired.Resource.Unstructured) || IsManaged(&observed.Resource.Unstructured) {
+			continue
+		}
+		if (in.OnlyOwnedResources == nil || *in.OnlyOwnedResources) && observedComposite != nil && ControlledByOther(&observed.Resource.Unstructured, &observedComposite.Resource.Unstructured) {
+			f.log.Info("not protecting resource controlled by another resource than the composite", "resource", name)
+			continue
+		}
+		prev, hasUsage := observedComposed[name+"-usage"]
+		reason, protect := ComposedProtectionReason(&desired.Resource.Unstructured, &observed.Resource.Unstructured, in, matchers)
+		if !protect && newest[name] {
+			reason, protect = ProtectionReasonNewest, true
+		}
+		if !protect && (annotated.Matches(&desired.Resource.Unstructured) || annotated.Matches(&observed.Resource.Unstructured)) {
+			reason, protect = ProtectionReasonAnnotationRegex, true
+		}
+		if !protect && referenced[name] {
+			reason, protect = ProtectionReasonReferenced, true
+		}
+		if !protect && in.ProtectByControllerRef && observedComposite != nil && ControlledBy(&observed.Resource.Unstructured, &observedComposite.Resource.Unstructured) {
+			reason, protect = ProtectionReasonControllerRef, true
+		}
+		if !protect && (spec.Protects(&desired.Resource.Unstructured) || spec.Protects(&observed.Resource.Unstructured)) {
+			reason, protect = spec.UsageReason(), true
+		}
+		if !protect && in.ClearLabelAfterProtect && hasUsage && !ProtectionDisabled(&desired.Resource.Unstructured, in) {
+			// Once the label has been cleared the existing Usage is the source of
+			// truth, until the label is explicitly set to a non-true value.
+			reason, _ = prev.Resource.GetString("spec.reason")
+			if reason == "" {
+				reason = ProtectionReasonLabel
+			}
+			protect = true
+		}
+		if !protect && in.DecisionWebhookURL != "" && !OptedOut(&desired.Resource.Unstructured, in) {
+			var xr *unstructured.Unstructured
+			if observedComposite != nil {
+				xr = &observedComposite.Resource.Unstructured
+			}
+			d, err := Decide(decideCtx, f.httpClient(), &observed.Resource.Unstructured, xr, in)
+			switch {
+			case err != nil && in.DecisionWebhookFailurePolicy == v1beta1.WebhookFailurePolicySkip:
+				f.log.Info("decision webhook failed, not protecting resource", "resource", name, "error", err)
+				skipped = append(skipped, SkippedResource{Name: name, Reason: SkipReasonWebhookFailed})
+			case err != nil:
+				f.log.Info("decision webhook failed, protecting resource", "resource", name, "error", err)
+				reason, protect = ProtectionReasonWebhook, true
+			case d.Protect:
+				reason, protect = ProtectionReasonWebhook, true
+				if d.Reason != "" {
+					reason = d.Reason
 				}
-				f.log.Debug("created usage", "kind", usageComposed.GetKind(), "name", usageComposed.GetName(), "namespace", usageComposed.GetNamespace())
-				dc[name+"-usage"] = &resource.DesiredComposed{Resource: usageComposed}
 			}
 		}
+		if !protect {
+			continue
+		}
+		if !ProtectedOn(scheduleSource(&desired.Resource.Unstructured, &observed.Resource.Unstructured), f.now()) {
+			f.log.Debug("not protecting resource outside of its protection schedule", "resource", name)
+			continue
+		}
+		if (in.SkipObserveOnly == nil || *in.SkipObserveOnly) && (ObserveOnly(&desired.Resource.Unstructured) || ObserveOnly(&observed.Resource.Unstructured)) {
+			f.log.Debug("not protecting observe-only resource", "resource", name)
+			continue
+		}
+		if in.OnlyProtectDeletePolicy && (!DeletesExternal(&desired.Resource.Unstructured) || !DeletesExternal(&observed.Resource.Unstructured)) {
+			f.log.Debug("not protecting resource that orphans its external resource", "resource", name)
+			continue
+		}
+		if in.ProtectRevisionLabel && !matchesRevision(&observed.Resource.Unstructured, observedComposite, in) {
+			f.log.Debug("not protecting resource from another composition revision", "resource", name)
+			continue
+		}
+		// A Usage cannot reference a resource that has not been named yet. A
+		// resource that has not been created yet is protected once it has
+		// been observed, like without ProtectDesiredOnly.
+		if observed.Resource.GetName() == "" && desiredOnly {
+			f.log.Debug("not protecting unnamed resource before it is observed", "resource", name)
+			continue
+		}
+		if observed.Resource.GetName() == "" {
+			f.log.Info("skipping protection of unnamed resource", "resource", name, "kind", observed.Resource.GetKind())
+			skipped = append(skipped, SkippedResource{Name: name, Reason: SkipReasonNoName})
+			continue
+		}
+		if in.MinReadyDurationSeconds > 0 {
+			since, ok := ReadySince(&observed.Resource.Unstructured)
+			if !ok || f.now().Sub(since) < time.Duration(in.MinReadyDurationSeconds)*time.Second {
+				f.log.Debug("skipping protection of resource that has not been ready long enough", "resource", name)
+				skipped = append(skipped, SkippedResource{Name: name, Reason: SkipReasonNotReadyLongEnough})
+				continue
+			}
+		}
+		// Validate that v1 mode is not used with namespaced resources
+		if in.EnableV1Mode && observed.Resource.GetNamespace() != "" {
+			return dc, skipped, errors.Errorf(V1ModeError, observed.Resource.GetKind(), observed.Resource.GetName(), observed.Resource.GetNamespace())
+		}
+		f.log.Debug("protecting Composed resource", "kind", observed.Resource.GetKind(), "name", observed.Resource.GetName(), "namespace", observed.Resource.GetNamespace())
+		usage := GenerateUsage(&observed.Resource.Unstructured, reason, in, redact)
+		usageComposed := asComposed(usage)
+		// A Usage that still references a previous API group is updated in
+		// place rather than replaced.
+		if hasUsage && prev.Resource != nil && CarryOverUsage(&usageComposed.Unstructured, &prev.Resource.Unstructured) {
+			f.log.Info("updating usage after API version change", "resource", name, "usage", usageComposed.GetName(), "to", observed.Resource.GetAPIVersion())
+		}
+		if in.AnnotateProtected {
+			meta.AddAnnotations(desired.Resource, map[string]string{AnnotationProtectedBy: usageComposed.GetName()})
+		}
+		// The label is only removed once the Usage has been observed, so the
+		// resource is never left without protection.
+		if in.ClearLabelAfterProtect && hasUsage {
+			meta.RemoveLabels(desired.Resource, ProtectionLabelBlockDeletion)
+		}
+		f.log.Debug("created usage", "kind", usageComposed.GetKind(), "name", usageComposed.GetName(), "namespace", usageComposed.GetNamespace())
+		dc[name+"-usage"] = &resource.DesiredComposed{Resource: usageComposed}
 	}
-	return dc, nil
+	slices.SortFunc(skipped, func(a, b SkippedResource) int { return strings.Compare(string(a.Name), string(b.Name)) })
+	return dc, skipped, nil
+}
+
+// CarryOverUsage keeps the identity of an observed Usage that protects the
+// same resource as the generated Usage under a previous API version, for
+// example after a provider moved the resource to a new API group. The
+// generated Usage takes the name of the observed Usage, and the labels and
+// annotations of the observed Usage that the generated Usage does not set are
+// kept. It returns false if the observed Usage references the current API
+// version or a different resource.
+func CarryOverUsage(usage, observed *unstructured.Unstructured) bool {
+	str := func(u *unstructured.Unstructured, fields ...string) string {
+		v, _, _ := unstructured.NestedString(u.Object, fields...)
+		return v
+	}
+	from, to := str(observed, "spec", "of", "apiVersion"), str(usage, "spec", "of", "apiVersion")
+	if from == "" || from == to {
+		return false
+	}
+	if str(observed, "spec", "of", "kind") != str(usage, "spec", "of", "kind") || str(observed, "spec", "of", "resourceRef", "name") != str(usage, "spec", "of", "resourceRef", "name") {
+		return false
+	}
+	if n := observed.GetName(); n != "" {
+		usage.SetName(n)
+	}
+	labels := map[string]string{}
+	maps.Copy(labels, observed.GetLabels())
+	maps.Copy(labels, usage.GetLabels())
+	if len(labels) > 0 {
+		usage.SetLabels(labels)
+	}
+	annotations := map[string]string{}
+	maps.Copy(annotations, observed.GetAnnotations())
+	maps.Copy(annotations, usage.GetAnnotations())
+	if len(annotations) > 0 {
+		usage.SetAnnotations(annotations)
+	}
+	return true
+}
+
+// desiredAsObserved returns a desired composed resource that has not been
+// observed yet as it will be observed. Composed resources of a namespaced
+// composite are created in the composite's namespace.
+func desiredAsObserved(desired *resource.DesiredComposed, observedComposite *resource.Composite) resource.ObservedComposed {
+	u := desired.Resource.DeepCopy()
+	if u.GetNamespace() == "" && observedComposite != nil && observedComposite.Resource != nil {
+		u.SetNamespace(observedComposite.Resource.GetNamespace())
+	}
+	return resource.ObservedComposed{Resource: u}
 }
 
 // ProtectComposite creates a Usage for the Composite Resource if it should be protected.
 // Protection occurs if:
+// - Any composed resources are being protected (protectedCount > 0), or
 // - The composite has the protection label, or
-// - Any composed resources are being protected (protectedCount > 0).
-func (f *Function) ProtectComposite(observedComposite *resource.Composite, desiredComposite *resource.Composite, protectedCount int, enableV1Mode bool) (map[resource.Name]*resource.DesiredComposed, error) {
-	if !ProtectResource(&observedComposite.Resource.Unstructured) && !ProtectResource(&desiredComposite.Resource.Unstructured) && protectedCount == 0 {
+// - DefaultProtect is enabled and the composite has not opted out.
+// The Usage scope follows the observed composite: a namespaced composite is
+// protected by a Usage in its namespace, a cluster-scoped composite by a
+// ClusterUsage. The composite is matched against the same compiled matchers as
+// the composed resources.
+func (f *Function) ProtectComposite(observedComposite *resource.Composite, desiredComposite *resource.Composite, protectedCount int, in *v1beta1.Input, matchers Matchers, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, error) {
+	oxr, dxr := &observedComposite.Resource.Unstructured, &desiredComposite.Resource.Unstructured
+	spec, _ := CompositeProtectionSpec(observedComposite, in.ProtectionSpecPath)
+	var reason string
+	switch {
+	case protectedCount > 0:
+		reason = ProtectionReasonCompositeChildResource
+	case LabelProtected(dxr, oxr, in):
+		reason = ProtectionReasonLabel
+	case MatchesLabelEquals(oxr, in.MatchLabelEquals) || MatchesLabelEquals(dxr, in.MatchLabelEquals):
+		reason = ProtectionReasonLabelValue
+	case MatchesAllSelectors(oxr, matchers.AllSelectors) || MatchesAllSelectors(dxr, matchers.AllSelectors):
+		reason = ProtectionReasonAllSelectors
+	case InSharedGroup(oxr, in.SharedProtectionGroupLabel) || InSharedGroup(dxr, in.SharedProtectionGroupLabel):
+		reason = ProtectionReasonSharedGroup
+	case in.DefaultProtect && !OptedOut(oxr, in) && !OptedOut(dxr, in):
+		reason = ProtectionReasonDefault
+	case in.ProtectNamespaceAsGroup && oxr.GetNamespace() != "":
+		reason = ProtectionReasonNamespaceGroup
+	case spec.Enabled:
+		reason = spec.UsageReason()
+	default:
+		return nil, nil
+	}
+	// Composed resources that are protected always protect the composite.
+	if protectedCount == 0 && !ProtectedOn(scheduleSource(dxr, oxr), f.now()) {
+		f.log.Debug("not protecting composite outside of its protection schedule", "name", observedComposite.Resource.GetName())
 		return nil, nil
 	}
 
 	// Validate that v1 mode is not used with namespaced composite resources
-	if enableV1Mode && observedComposite.Resource.GetNamespace() != "" {
+	if in.EnableV1Mode && observedComposite.Resource.GetNamespace() != "" {
 		return nil, errors.Errorf(V1ModeError, observedComposite.Resource.GetKind(), observedComposite.Resource.GetName(), observedComposite.Resource.GetNamespace())
 	}
 
 	f.log.Debug("protecting composite", "kind", observedComposite.Resource.GetKind(), "name", observedComposite.Resource.GetName(), "namespace", observedComposite.Resource.GetNamespace())
 
-	var reason string
-	if protectedCount > 0 {
-		reason = ProtectionReasonCompositeChildResource
-	} else {
-		reason = ProtectionReasonLabel
-	}
-
-	usage := GenerateUsage(&observedComposite.Resource.Unstructured, reason, enableV1Mode)
-	usageComposed := composed.New()
-	if err := convertViaJSON(usageComposed, usage); err != nil {
-		return nil, errors.Wrap(err, "cannot convert usage to unstructured")
+	usage := GenerateUsage(&observedComposite.Resource.Unstructured, reason, in, redact)
+	if in.XRUseResourceSelector && !SelectByLabels(usage, &observedComposite.Resource.Unstructured, in.XRSelectorLabels) {
+		f.log.Info("composite lacks xrSelectorLabels, referencing it by name", "name", observedComposite.Resource.GetName())
 	}
+	usageComposed := asComposed(usage)
 
 	uname := strings.ToLower("xr-" + observedComposite.Resource.GetName() + "-usage")
 	f.log.Debug("creating usage", "kind", usageComposed.GetKind(), "name", usageComposed.GetName(), "namespace", usageComposed.GetNamespace())
@@ -216,24 +1103,54 @@ func (f *Function) ProtectComposite(observedComposite *resource.Composite, desir
 	}, nil
 }
 
+// RequiredResourceSelector returns a selector for the resources matched by the
+// supplied RequiredSelector.
+func RequiredResourceSelector(sel v1beta1.RequiredSelector) *fnv1.ResourceSelector {
+	rs := &fnv1.ResourceSelector{
+		ApiVersion: sel.APIVersion,
+		Kind:       sel.Kind,
+		Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: sel.MatchLabels}},
+	}
+	if sel.Namespace != "" {
+		rs.Namespace = &sel.Namespace
+	}
+	return rs
+}
+
 // ProtectRequiredResources creates usages for Required Resources in a Composition.
-// Usages are generated for any Watched resource. Other required resources need to have the label.
-func ProtectRequiredResources(rr map[string][]resource.Required) (map[resource.Name]*resource.DesiredComposed, error) {
+// Usages are generated for any Watched resource and any resource matched by
+// the RequiredSelectors. Other required resources need to have the label or
+// belong to the shared protection group.
+func ProtectRequiredResources(rr map[string][]resource.Required, in *v1beta1.Input, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, error) {
 	dc := map[resource.Name]*resource.DesiredComposed{}
 	for resourceName, v := range rr {
+		// Resources looked up for CrossCompositionRefs are only protected by
+		// the composed resources that depend on them.
+		if strings.HasPrefix(resourceName, RequirementsNameCrossComposition) {
+			continue
+		}
+		selected := slices.ContainsFunc(in.RequiredSelectors, func(sel v1beta1.RequiredSelector) bool { return sel.Name == resourceName })
 		for _, r := range v {
-			if resourceName == RequirementsNameWatchedResource || ProtectResource(r.Resource) {
+			grouped := InSharedGroup(r.Resource, in.SharedProtectionGroupLabel)
+			if resourceName == RequirementsNameWatchedResource || selected || grouped || ProtectResource(r.Resource, in) {
 				var reason string
-				if resourceName == RequirementsNameWatchedResource {
+				switch {
+				case resourceName == RequirementsNameWatchedResource:
 					reason = ProtectionReasonWatchOperation
-				} else {
+				case selected:
+					reason = ProtectionReasonRequiredSelector
+				case grouped && !ProtectResource(r.Resource, in):
+					// The label takes precedence over the group, as it does
+					// for composed resources.
+					reason = ProtectionReasonSharedGroup
+				default:
 					reason = ProtectionReasonOperation
 				}
-				usage := GenerateV2Usage(r.Resource, reason)
-				usageComposed := composed.New()
-				if err := convertViaJSON(usageComposed, usage); err != nil {
-					return dc, errors.Wrap(err, "cannot convert usage to unstructured")
-				}
+				usage := GenerateV2Usage(r.Resource, ResolveReason(r.Resource, reason, in))
+				ApplyUsageOptions(usage, in, redact)
+				ApplyRunbook(usage, r.Resource, redact)
+				RecordMechanism(usage, reason, in)
+				usageComposed := asComposed(usage)
 				uname := fmt.Sprintf("%s-%s-%s-required-resource-fn-protection", r.Resource.GetKind(), r.Resource.GetName(), r.Resource.GetNamespace())
 				dc[resource.Name(uname)] = &resource.DesiredComposed{Resource: usageComposed}
 			}
@@ -242,20 +1159,118 @@ func ProtectRequiredResources(rr map[string][]resource.Required) (map[resource.N
 	return dc, nil
 }
 
-// GenerateUsage determines whether to return a v1 or v2 Crossplane usage.
-func GenerateUsage(u *unstructured.Unstructured, reason string, createV1Usages bool) map[string]any {
-	if createV1Usages {
-		return GenerateV1Usage(u, reason)
+// ProtectReferencedObjects creates Usages for the Secrets and ConfigMaps
+// referenced by the composite. These are not composed resources, so they are
+// protected whether or not they are part of the composition.
+func (f *Function) ProtectReferencedObjects(observedComposite *resource.Composite, in *v1beta1.Input, redact []*regexp.Regexp) (map[resource.Name]*resource.DesiredComposed, error) {
+	dc := map[resource.Name]*resource.DesiredComposed{}
+	for _, o := range ReferencedObjects(observedComposite, in.SecretRefPaths) {
+		// Secrets and ConfigMaps are always namespaced.
+		if in.EnableV1Mode {
+			return nil, errors.Errorf(V1ModeError, o.GetKind(), o.GetName(), o.GetNamespace())
+		}
+		f.log.Debug("protecting referenced object", "kind", o.GetKind(), "name", o.GetName(), "namespace", o.GetNamespace())
+		usage := asComposed(GenerateUsage(o, ProtectionReasonReferenced, in, redact))
+		dc[resource.Name(strings.ToLower(o.GetKind()+"-"+o.GetNamespace()+"-"+o.GetName()+"-usage"))] = &resource.DesiredComposed{Resource: usage}
+	}
+	return dc, nil
+}
+
+// GenerateUsage determines whether to return a v1 or v2 Crossplane usage and
+// applies any Usage options from the Input. The supplied redactions are the
+// compiled RedactReasonPatterns of the Input.
+func GenerateUsage(u *unstructured.Unstructured, reason string, in *v1beta1.Input, redact []*regexp.Regexp) map[string]any {
+	var usage map[string]any
+	if in.EnableV1Mode {
+		usage = GenerateV1Usage(u, ResolveReason(u, reason, in))
+	} else {
+		usage = GenerateV2Usage(u, ResolveReason(u, reason, in))
+	}
+	if in.UsageGroupVersion != "" {
+		usage["apiVersion"] = in.UsageGroupVersion
+	}
+	ApplyUsageOptions(usage, in, redact)
+	ApplyRunbook(usage, u, redact)
+	RecordMechanism(usage, reason, in)
+	return usage
+}
+
+// DefaultReasonPrecedence is the order in which the sources of a reason are
+// considered if the Input does not configure one.
+var DefaultReasonPrecedence = []v1beta1.ReasonSource{v1beta1.ReasonSourceAnnotation, v1beta1.ReasonSourceTrigger, v1beta1.ReasonSourceDefault}
+
+// ResolveReason returns the reason for the Usage protecting the supplied
+// resource from the first source of the Input's ReasonPrecedence that is
+// present. The supplied reason is the one generated by the function.
+func ResolveReason(u *unstructured.Unstructured, reason string, in *v1beta1.Input) string {
+	precedence := in.ReasonPrecedence
+	if len(precedence) == 0 {
+		precedence = DefaultReasonPrecedence
 	}
-	return GenerateV2Usage(u, reason)
+	for _, src := range precedence {
+		switch src {
+		case v1beta1.ReasonSourceAnnotation:
+			if v := u.GetAnnotations()[AnnotationReason]; v != "" {
+				return v
+			}
+		case v1beta1.ReasonSourceTrigger:
+			if r, ok := TriggerReason(reason); ok && in.TriggerReasons {
+				return r
+			}
+		case v1beta1.ReasonSourceDefault:
+			return reason
+		}
+	}
+	return reason
 }
 
-// GenerateV2Usage creates a v2 Usage for a resource.
+// ApplyRunbook appends the URL of the protected resource's AnnotationRunbook
+// annotation to the reason of its Usage, so responders can find the procedure
+// to safely remove protection. It is applied after the other Usage options, so
+// the URL follows any ReasonSuffix. A reason that already ends in a period is
+// not given a second one.
+func ApplyRunbook(usage map[string]any, u *unstructured.Unstructured, redact []*regexp.Regexp) {
+	url := strings.TrimSpace(u.GetAnnotations()[AnnotationRunbook])
+	if url == "" {
+		return
+	}
+	if reason, ok, _ := unstructured.NestedString(usage, "spec", "reason"); ok {
+		_ = unstructured.SetNestedField(usage, RedactReason(strings.TrimSuffix(reason, ".")+". See: "+url, redact), "spec", "reason")
+	}
+}
+
+// ApplyUsageOptions applies the Usage options from the Input to a generated
+// Usage.
+func ApplyUsageOptions(usage map[string]any, in *v1beta1.Input, redact []*regexp.Regexp) {
+	if reason, ok, _ := unstructured.NestedString(usage, "spec", "reason"); ok {
+		if reason == ProtectionReasonLabel && in.IncludeLabelInReason != nil && !*in.IncludeLabelInReason {
+			reason = ProtectionReasonLabelWithoutKey
+		}
+		reason = DecorateReason(reason, in.ReasonPrefix, in.ReasonSuffix)
+		_ = unstructured.SetNestedField(usage, RedactReason(reason, redact), "spec", "reason")
+	}
+	if in.NamingScheme == v1beta1.NamingSchemeHash {
+		_ = unstructured.SetNestedField(usage, usageName(usage, "", in.NamingScheme), "metadata", "name")
+	}
+	switch in.OnRelease {
+	case v1beta1.OnReleaseReplay:
+		_ = unstructured.SetNestedField(usage, string(in.OnRelease), "metadata", "annotations", AnnotationOnRelease)
+		_ = unstructured.SetNestedField(usage, true, "spec", "replayDeletion")
+	case v1beta1.OnReleaseBlock:
+		_ = unstructured.SetNestedField(usage, string(in.OnRelease), "metadata", "annotations", AnnotationOnRelease)
+	}
+}
+
+// GenerateV2Usage creates a v2 Usage for a resource. Namespaced resources are
+// protected by a Usage in their namespace, since the ClusterUsage resourceRef
+// cannot reference a namespace. The resource is always referenced by name, as
+// a resourceSelector is ignored once a resourceRef is set.
 func GenerateV2Usage(u *unstructured.Unstructured, reason string) map[string]any {
 	name := strings.ToLower(u.GetKind() + "-" + u.GetName())
 	usageType := protectionv1beta1.ClusterUsageKind
 	usageMeta := map[string]any{
-		"name": GenerateName(name, UsageNameSuffix),
+		"name":   GenerateName(name, UsageNameSuffix),
+		"labels": map[string]any{LabelManagedBy: ManagedByValue},
 	}
 
 	namespace := u.GetNamespace()
@@ -290,7 +1305,8 @@ func GenerateV1Usage(u *unstructured.Unstructured, reason string) map[string]any
 		"apiVersion": ProtectionV1GroupVersion,
 		"kind":       apiextensionsv1beta1.UsageKind,
 		"metadata": map[string]any{
-			"name": GenerateName(name, UsageNameSuffix),
+			"name":   GenerateName(name, UsageNameSuffix),
+			"labels": map[string]any{LabelManagedBy: ManagedByValue},
 		},
 		"spec": map[string]any{
 			"of": map[string]any{
@@ -306,10 +1322,373 @@ func GenerateV1Usage(u *unstructured.Unstructured, reason string) map[string]any
 	return usage
 }
 
-func convertViaJSON(to, from any) error {
-	bs, err := json.Marshal(from)
+// AnnotateInsteadOfUsages sets AnnotationProtected on the desired composite
+// and composed resources protected by the supplied composite and composed
+// usages, and removes those usages from usages. It returns the number of
+// annotated resources.
+func AnnotateInsteadOfUsages(desiredComposite *resource.Composite, desiredComposed, compositeUsage, composedUsages, usages map[resource.Name]*resource.DesiredComposed) int {
+	var n int
+	annotate := func(o metav1.Object, key resource.Name) {
+		u, ok := usages[key]
+		if !ok {
+			// The usage was dropped during validation.
+			return
+		}
+		reason, _ := u.Resource.GetString("spec.reason")
+		meta.AddAnnotations(o, map[string]string{AnnotationProtected: reason})
+		if m := u.Resource.GetAnnotations()[AnnotationMechanism]; m != "" {
+			meta.AddAnnotations(o, map[string]string{AnnotationMechanism: m})
+		}
+		delete(usages, key)
+		n++
+	}
+	for key := range composedUsages {
+		if d, ok := desiredComposed[resource.Name(strings.TrimSuffix(string(key), "-usage"))]; ok {
+			annotate(d.Resource, key)
+		}
+	}
+	for key := range compositeUsage {
+		annotate(desiredComposite.Resource, key)
+	}
+	return n
+}
+
+// CustomReason returns the reason of an observed Usage if it was not set by
+// this function.
+func CustomReason(u *unstructured.Unstructured) (string, bool) {
+	if u == nil || u.Object == nil {
+		return "", false
+	}
+	reason, _, err := unstructured.NestedString(u.Object, "spec", "reason")
+	if err != nil || reason == "" || strings.Contains(reason, strings.TrimSpace(ProtectionReason)) || strings.Contains(reason, TriggerReasonPrefix) {
+		return "", false
+	}
+	return reason, true
+}
+
+// PreserveReasons copies custom reasons of observed Usages to the generated
+// Usages of the same name. It returns the number of reasons preserved.
+func PreserveReasons(usages map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed) int {
+	n := 0
+	for name, u := range usages {
+		o, ok := observedComposed[name]
+		if !ok || o.Resource == nil {
+			continue
+		}
+		reason, ok := CustomReason(&o.Resource.Unstructured)
+		if !ok {
+			continue
+		}
+		if err := unstructured.SetNestedField(u.Resource.Object, reason, "spec", "reason"); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// StampReasons appends the time protection started to the reason of the
+// supplied Usages and records it in the AnnotationProtectedAt annotation. The
+// time of an observed Usage of the same name is kept, so that the reason only
+// changes when protection starts.
+func StampReasons(usages map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, now time.Time) {
+	for name, u := range usages {
+		at := now.UTC().Format(time.RFC3339)
+		if o, ok := observedComposed[name]; ok && o.Resource != nil {
+			if v, err := time.Parse(time.RFC3339, o.Resource.GetAnnotations()[AnnotationProtectedAt]); err == nil {
+				at = v.UTC().Format(time.RFC3339)
+			}
+		}
+		reason, ok, _ := unstructured.NestedString(u.Resource.Object, "spec", "reason")
+		if !ok {
+			continue
+		}
+		_ = unstructured.SetNestedField(u.Resource.Object, reason+" at "+at, "spec", "reason")
+		meta.AddAnnotations(u.Resource, map[string]string{AnnotationProtectedAt: at})
+	}
+}
+
+// GroupByNamespace labels the supplied namespaced Usages with their namespace,
+// so that the Usages protecting a namespace can be selected together.
+// ClusterUsages are not labeled. It returns the number of Usages labeled.
+func GroupByNamespace(usages map[resource.Name]*resource.DesiredComposed) int {
+	n := 0
+	for _, u := range usages {
+		ns := u.Resource.GetNamespace()
+		if ns == "" {
+			continue
+		}
+		meta.AddLabels(u.Resource, map[string]string{LabelNamespaceGroup: ns})
+		n++
+	}
+	return n
+}
+
+// DedupeUsages removes Usages that protect the same resource as another Usage,
+// e.g. when a composition renamed a resource and it is present under two
+// keys. Usages documenting different subresources are distinct. The Usage with
+// the lowest key is kept. It returns the number of Usages removed.
+func DedupeUsages(usages map[resource.Name]*resource.DesiredComposed) int {
+	seen := map[string]bool{}
+	n := 0
+	for _, name := range slices.Sorted(maps.Keys(usages)) {
+		u := usages[name].Resource
+		str := func(path ...string) string {
+			v, _, _ := unstructured.NestedString(u.Object, path...)
+			return v
+		}
+		target := strings.Join([]string{str("spec", "of", "apiVersion"), str("spec", "of", "kind"), u.GetNamespace(), str("spec", "of", "resourceRef", "name"), u.GetAnnotations()[AnnotationSubresource], str("spec", "by", "kind"), str("spec", "by", "resourceRef", "name")}, "/")
+		if seen[target] {
+			delete(usages, name)
+			n++
+			continue
+		}
+		seen[target] = true
+	}
+	return n
+}
+
+// IsUsage returns true if the supplied resource is a Crossplane v1 or v2 Usage
+// or ClusterUsage.
+func IsUsage(u *unstructured.Unstructured) bool {
+	gv, err := schema.ParseGroupVersion(u.GetAPIVersion())
+	if err != nil || (gv.Group != protectionv1beta1.Group && gv.Group != apiextensionsv1beta1.Group) {
+		return false
+	}
+	return u.GetKind() == protectionv1beta1.UsageKind || u.GetKind() == protectionv1beta1.ClusterUsageKind
+}
+
+// RespectUserUsages removes generated Usages that protect a resource that is
+// already protected by a Usage in the desired composed resources that was not
+// generated by this function. A user supplied Usage without a namespace
+// matches generated Usages in any namespace. It returns the sorted names of
+// the removed Usages.
+func RespectUserUsages(usages, desiredComposed map[resource.Name]*resource.DesiredComposed) []resource.Name {
+	target := func(u *unstructured.Unstructured, namespace string) string {
+		str := func(path ...string) string {
+			v, _, _ := unstructured.NestedString(u.Object, path...)
+			return v
+		}
+		return strings.Join([]string{str("spec", "of", "apiVersion"), str("spec", "of", "kind"), namespace, str("spec", "of", "resourceRef", "name")}, "/")
+	}
+	user := map[string]bool{}
+	for _, d := range desiredComposed {
+		if d == nil || d.Resource == nil || IsManaged(&d.Resource.Unstructured) || !IsUsage(&d.Resource.Unstructured) {
+			continue
+		}
+		user[target(&d.Resource.Unstructured, d.Resource.GetNamespace())] = true
+	}
+	if len(user) == 0 {
+		return nil
+	}
+	var removed []resource.Name
+	for _, name := range slices.Sorted(maps.Keys(usages)) {
+		u := &usages[name].Resource.Unstructured
+		if user[target(u, u.GetNamespace())] || user[target(u, "")] {
+			delete(usages, name)
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// StampSnapshot annotates every Usage with the sorted list of resources
+// protected by the supplied Usages.
+func StampSnapshot(usages map[resource.Name]*resource.DesiredComposed) error {
+	protected := make([]string, 0, len(usages))
+	for _, u := range usages {
+		kind, _, _ := unstructured.NestedString(u.Resource.Object, "spec", "of", "kind")
+		name, _, _ := unstructured.NestedString(u.Resource.Object, "spec", "of", "resourceRef", "name")
+		ref := kind + "/" + name
+		if ns := u.Resource.GetNamespace(); ns != "" {
+			ref = kind + "/" + ns + "/" + name
+		}
+		protected = append(protected, ref)
+	}
+	slices.Sort(protected)
+	// A resource may be protected by several Usages, e.g. per subresource.
+	protected = slices.Compact(protected)
+	b, err := json.Marshal(protected)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(bs, to)
+	for _, u := range usages {
+		meta.AddAnnotations(u.Resource, map[string]string{AnnotationSnapshot: string(b)})
+	}
+	return nil
+}
+
+// SnapshotRecorded returns true if an observed Usage generated by this
+// function has recorded a protection snapshot.
+func SnapshotRecorded(observedComposed map[resource.Name]resource.ObservedComposed) bool {
+	for _, o := range observedComposed {
+		if o.Resource == nil || !IsManaged(&o.Resource.Unstructured) {
+			continue
+		}
+		if _, ok := o.Resource.GetAnnotations()[AnnotationSnapshot]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseManaged returns the desired composed resources without any resources
+// generated by this function.
+func ReleaseManaged(desiredComposed map[resource.Name]*resource.DesiredComposed) map[resource.Name]*resource.DesiredComposed {
+	desired := make(map[resource.Name]*resource.DesiredComposed, len(desiredComposed))
+	for name, d := range desiredComposed {
+		if d != nil && d.Resource != nil && IsManaged(&d.Resource.Unstructured) {
+			continue
+		}
+		desired[name] = d
+	}
+	return desired
+}
+
+// RetainManaged returns the desired composed resources with the observed
+// resources generated by this function in a previous run added. Fields set by
+// the API server are removed from the retained resources.
+func RetainManaged(desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed) map[resource.Name]*resource.DesiredComposed {
+	desired := make(map[resource.Name]*resource.DesiredComposed, len(desiredComposed))
+	maps.Copy(desired, desiredComposed)
+	for name, o := range observedComposed {
+		if _, ok := desired[name]; ok || o.Resource == nil || !IsManaged(&o.Resource.Unstructured) {
+			continue
+		}
+		u := o.Resource.DeepCopy()
+		delete(u.Object, "status")
+		for _, f := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp", "managedFields", "ownerReferences"} {
+			unstructured.RemoveNestedField(u.Object, "metadata", f)
+		}
+		desired[name] = &resource.DesiredComposed{Resource: u}
+	}
+	return desired
+}
+
+// ResolveCollisions moves usages whose key is already used by a desired
+// composed resource that was not generated by this function to an alternate
+// key, so that the existing resource is not overwritten. Usages that cannot be
+// moved are removed.
+func ResolveCollisions(usages, desiredComposed map[resource.Name]*resource.DesiredComposed) []ProtectionResult {
+	collides := func(key resource.Name) bool {
+		d, ok := desiredComposed[key]
+		return ok && d.Resource != nil && !IsManaged(&d.Resource.Unstructured)
+	}
+	var results []ProtectionResult
+	for _, key := range slices.Sorted(maps.Keys(usages)) {
+		if !collides(key) {
+			continue
+		}
+		u := usages[key]
+		delete(usages, key)
+		alt := key + "-" + UsageNameSuffix
+		if _, ok := usages[alt]; ok || collides(alt) {
+			results = append(results, ProtectionResult{SkippedResource: SkippedResource{Name: key, Reason: fmt.Sprintf("desired composed resources %q and %q already exist", key, alt)}, Dropped: true})
+			continue
+		}
+		usages[alt] = u
+		results = append(results, ProtectionResult{SkippedResource: SkippedResource{Name: key, Reason: "desired composed resource already exists"}, RenamedTo: alt})
+	}
+	return results
+}
+
+// UnlabeledOfKinds returns the sorted names of observed composed resources of
+// one of the Input's WarnUnprotectedKinds that do not have the block-deletion
+// label.
+func UnlabeledOfKinds(desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, in *v1beta1.Input) []resource.Name {
+	if len(in.WarnUnprotectedKinds) == 0 {
+		return nil
+	}
+	var names []resource.Name
+	for name, desired := range desiredComposed {
+		observed, ok := observedComposed[name]
+		if !ok || IsManaged(&desired.Resource.Unstructured) || IsManaged(&observed.Resource.Unstructured) {
+			continue
+		}
+		if !MatchesGroupKind(&observed.Resource.Unstructured, in.WarnUnprotectedKinds) {
+			continue
+		}
+		if LabelProtected(&desired.Resource.Unstructured, &observed.Resource.Unstructured, in) {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// CountTriggers returns the number of usages that protect a resource of one
+// of the supplied kinds. If no kinds are supplied every usage is counted.
+func CountTriggers(usages map[resource.Name]*resource.DesiredComposed, kinds []v1beta1.GroupKind) int {
+	if len(kinds) == 0 {
+		return len(usages)
+	}
+	var n int
+	for _, u := range usages {
+		if u == nil || u.Resource == nil {
+			continue
+		}
+		apiVersion, _ := u.Resource.GetString("spec.of.apiVersion")
+		kind, _ := u.Resource.GetString("spec.of.kind")
+		of := &unstructured.Unstructured{}
+		of.SetAPIVersion(apiVersion)
+		of.SetKind(kind)
+		if MatchesGroupKind(of, kinds) {
+			n++
+		}
+	}
+	return n
+}
+
+// CheckMaxProtected returns an error if more resources are protected than the
+// configured limit allows. A limit of zero disables the check.
+func CheckMaxProtected(count, limit int) error {
+	if limit > 0 && count > limit {
+		return errors.Errorf("refusing to protect %d resources: exceeds maxProtectedPerRun limit of %d by %d", count, limit, count-limit)
+	}
+	return nil
+}
+
+// DecorateReason adds the supplied prefix and suffix to a reason, separated by
+// a single space. Surrounding whitespace is trimmed and empty parts are
+// omitted.
+func DecorateReason(reason, prefix, suffix string) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{prefix, reason, suffix} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// CompileRedactions compiles the supplied RedactReasonPatterns, so they can
+// be applied to the reasons of many Usages. Invalid patterns are rejected by
+// ValidateInput and ignored here.
+func CompileRedactions(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// RedactReason replaces any substrings of the reason that match one of the
+// supplied patterns.
+func RedactReason(reason string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		reason = re.ReplaceAllLiteralString(reason, RedactedReplacement)
+	}
+	return reason
+}
+
+// asComposed wraps an object generated by the function, such as a Usage, as a
+// composed resource. Generated objects only contain JSON compatible values, so
+// they are used as is rather than round-tripped through JSON, which is costly
+// for large compositions.
+func asComposed(obj map[string]any) *composed.Unstructured {
+	return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}
 }
@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
 	"testing"
 	"time"
 
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/durationpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
 
 	"github.com/crossplane/function-sdk-go/logging"
 	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/crossplane/function-sdk-go/resource"
 	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
 )
 
 func TestRunFunction(t *testing.T) {
@@ -27,6 +37,13 @@ func TestRunFunction(t *testing.T) {
 		err error
 	}
 
+	configValid := &fnv1.Condition{
+		Type:   ConditionTypeConfigValid,
+		Status: fnv1.Status_STATUS_CONDITION_TRUE,
+		Reason: ConditionReasonInputAccepted,
+		Target: fnv1.Target_TARGET_COMPOSITE.Enum(),
+	}
+
 	cases := map[string]struct {
 		reason string
 		args   args
@@ -35,12 +52,24 @@ func TestRunFunction(t *testing.T) {
 		"CacheTTLDefault": {
 			reason: "The Function should set the function Response cache to default",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
 						"apiVersion": "template.fn.crossplane.io/v1beta1",
 						"kind": "Input"
 					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+					},
 				},
 			},
 			want: want{
@@ -48,13 +77,14 @@ func TestRunFunction(t *testing.T) {
 					Desired:    &fnv1.State{},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"CacheTTLSet": {
 			reason: "The Function should set the function Response cache from the input",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -62,6 +92,17 @@ func TestRunFunction(t *testing.T) {
 						"kind": "Input",
 						"cacheTTL": "5m"
 					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+					},
 				},
 			},
 			want: want{
@@ -69,13 +110,14 @@ func TestRunFunction(t *testing.T) {
 					Desired:    &fnv1.State{},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(5 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"CacheTTLInvalid": {
 			reason: "The Function should return an error if the CacheTTL duration is invalid",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -95,13 +137,57 @@ func TestRunFunction(t *testing.T) {
 							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
 						},
 					},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:    ConditionTypeConfigValid,
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  ConditionReasonInvalidInput,
+							Message: ptr.To("cannot set cacheTTL: time: unknown unit \"x\" in duration \"5x\""),
+							Target:  fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+			},
+		},
+		"InvalidInput": {
+			reason: "The Function should report an invalid Input as a ConfigValid condition",
+			args: args{
+				ctx: context.Background(),
+				req: &fnv1.RunFunctionRequest{
+					Meta: &fnv1.RequestMeta{Tag: "hello"},
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "template.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"protectExternalNameRegex": "("
+					}`),
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
+					Results: []*fnv1.Result{
+						{
+							Message:  "invalid Function input: invalid protectExternalNameRegex: error parsing regexp: missing closing ): `(`",
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:    ConditionTypeConfigValid,
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  ConditionReasonInvalidInput,
+							Message: ptr.To("invalid Function input: invalid protectExternalNameRegex: error parsing regexp: missing closing ): `(`"),
+							Target:  fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
 				},
 			},
 		},
 		"NoResourcesToProtect": {
 			reason: "The Function should not create any Usages when the label is not present",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -146,13 +232,14 @@ func TestRunFunction(t *testing.T) {
 					Desired:    &fnv1.State{},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectCompositeResourceByCompositeLabel": {
 			reason: "Cluster Usages Created for a Composite when a Desired Composite resource is labeled",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -265,7 +352,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "protection.crossplane.io/v1beta1",
 									"kind": "ClusterUsage",
 									"metadata": {
-										"name": "testxr-my-test-xr-23c942-fn-protection"
+										"name": "testxr-my-test-xr-23c942-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -283,13 +371,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectNamespacedCompositeResourceByCompositeLabel": {
 			reason: "Namespaced Usages Created for a Composite when a Desired Composite resource is labeled",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -409,7 +498,8 @@ func TestRunFunction(t *testing.T) {
 									"kind": "Usage",
 									"metadata": {
 										"name": "testxr-my-test-xr-23c942-fn-protection",
-										"namespace": "test"
+										"namespace": "test",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -427,13 +517,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectComposedResourceAndCompositeResourceByDesiredComposed": {
 			reason: "Cluster Usages Created for XR and Resource when a Desired Composed resource is labeled",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -547,7 +638,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "protection.crossplane.io/v1beta1",
 									"kind": "ClusterUsage",
 									"metadata": {
-										"name": "testxr-my-test-xr-23c942-fn-protection"
+										"name": "testxr-my-test-xr-23c942-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -566,7 +658,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "protection.crossplane.io/v1beta1",
 									"kind": "ClusterUsage",
 									"metadata": {
-										"name": "testcomposed-my-test-composed-601ab8-fn-protection"
+										"name": "testcomposed-my-test-composed-601ab8-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -584,13 +677,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectComposedResourceAndCompositeResourceByObservedComposed": {
 			reason: "Cluster Usages Created for XR and Resource when an Observed Composed resource is labeled",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -701,7 +795,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "protection.crossplane.io/v1beta1",
 									"kind": "ClusterUsage",
 									"metadata": {
-										"name": "testxr-my-test-xr-23c942-fn-protection"
+										"name": "testxr-my-test-xr-23c942-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -720,7 +815,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "protection.crossplane.io/v1beta1",
 									"kind": "ClusterUsage",
 									"metadata": {
-										"name": "testcomposed-my-test-composed-601ab8-fn-protection"
+										"name": "testcomposed-my-test-composed-601ab8-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -738,13 +834,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectNamespacedComposedResourceAndCompositeResourceByDesiredComposed": {
 			reason: "Namespaced Usages Created for XR and Resource when a Desired Composed resource is labeled",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -865,7 +962,8 @@ func TestRunFunction(t *testing.T) {
 									"kind": "Usage",
 									"metadata": {
 										"name": "testxr-my-test-xr-23c942-fn-protection",
-										"namespace": "test"
+										"namespace": "test",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -885,7 +983,8 @@ func TestRunFunction(t *testing.T) {
 									"kind": "Usage",
 									"metadata": {
 										"name": "testcomposed-my-test-composed-601ab8-fn-protection",
-										"namespace": "test"
+										"namespace": "test",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -903,13 +1002,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectCompositeResourceWithV1Usage": {
 			reason: "V1 Usage Created for a Composite when EnableV1Mode is true",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -1023,7 +1123,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "apiextensions.crossplane.io/v1beta1",
 									"kind": "Usage",
 									"metadata": {
-										"name": "testxr-my-test-xr-23c942-fn-protection"
+										"name": "testxr-my-test-xr-23c942-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -1041,13 +1142,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectCompositeResourceWithV1UsageWhenProtectedResourcesExist": {
 			reason: "V1 Usage Created for a Composite when CreateV1Usages is true and protected composed resources exist",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -1161,7 +1263,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "apiextensions.crossplane.io/v1beta1",
 									"kind": "Usage",
 									"metadata": {
-										"name": "testcomposed-my-test-composed-601ab8-fn-protection"
+										"name": "testcomposed-my-test-composed-601ab8-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -1180,7 +1283,8 @@ func TestRunFunction(t *testing.T) {
 									"apiVersion": "apiextensions.crossplane.io/v1beta1",
 									"kind": "Usage",
 									"metadata": {
-										"name": "testxr-my-test-xr-23c942-fn-protection"
+										"name": "testxr-my-test-xr-23c942-fn-protection",
+										"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
 									},
 									"spec": {
 										"of": {
@@ -1198,13 +1302,14 @@ func TestRunFunction(t *testing.T) {
 					},
 					Meta:       &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
 					Results:    []*fnv1.Result{},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectNamespacedCompositeResourceWithV1UsageError": {
 			reason: "Should return error when trying to protect namespaced resource with EnableV1Mode (v1beta1 Usage is cluster-scoped only)",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -1329,13 +1434,14 @@ func TestRunFunction(t *testing.T) {
 							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
 						},
 					},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
 		"ProtectNamespacedComposedResourceWithV1UsageError": {
 			reason: "Should return error when trying to protect namespaced composed resource with EnableV1Mode",
 			args: args{
+				ctx: context.Background(),
 				req: &fnv1.RunFunctionRequest{
 					Meta: &fnv1.RequestMeta{Tag: "hello"},
 					Input: resource.MustStructJSON(`{
@@ -1460,178 +1566,388 @@ func TestRunFunction(t *testing.T) {
 							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
 						},
 					},
-					Conditions: []*fnv1.Condition{},
+					Conditions: []*fnv1.Condition{configValid},
 				},
 			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			f := &Function{log: logging.NewNopLogger()}
-			rsp, err := f.RunFunction(tc.args.ctx, tc.args.req)
-
-			if diff := cmp.Diff(tc.want.rsp, rsp, protocmp.Transform()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want rsp, +got rsp:\n%s", tc.reason, diff)
-			}
-
-			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want err, +got err:\n%s", tc.reason, diff)
-			}
-		})
-	}
-}
-
-func TestProtectRequiredResources(t *testing.T) {
-	type args struct {
-		rr map[string][]resource.Required
-	}
-	type want struct {
-		dc  map[resource.Name]*resource.DesiredComposed
-		err error
-	}
-
-	cases := map[string]struct {
-		reason string
-		args   args
-		want   want
-	}{
-		"EmptyRequiredResources": {
-			reason: "Should return empty map when no required resources are provided",
-			args: args{
-				rr: map[string][]resource.Required{},
-			},
-			want: want{
-				dc:  map[resource.Name]*resource.DesiredComposed{},
-				err: nil,
-			},
-		},
-		"WatchedResourceWithoutLabel": {
-			reason: "Should create Usage for watched resources regardless of label",
+		"SkipUnnamedObservedComposedResource": {
+			reason: "The Function should skip a labeled composed resource that has not been named yet, emit a warning and retry sooner",
 			args: args{
-				rr: map[string][]resource.Required{
-					RequirementsNameWatchedResource: {
-						{
-							Resource: &unstructured.Unstructured{
-								Object: map[string]any{
+				ctx: context.Background(),
+				req: &fnv1.RunFunctionRequest{
+					Meta: &fnv1.RequestMeta{Tag: "hello"},
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "template.fn.crossplane.io/v1beta1",
+						"kind": "Input"
+					}`),
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"unnamed-composed-resource": {
+								Resource: resource.MustStructJSON(`{
 									"apiVersion": "test.crossplane.io/v1",
-									"kind":       "TestResource",
-									"metadata": map[string]any{
-										"name": "test-watched-resource",
-									},
-								},
+									"kind": "TestComposed",
+									"metadata": {
+										"labels": {
+											"protection.fn.crossplane.io/block-deletion": "true"
+										}
+									}
+								}`),
 							},
 						},
 					},
-				},
-			},
-			want: want{
-				dc: map[resource.Name]*resource.DesiredComposed{
-					"TestResource-test-watched-resource--required-resource-fn-protection": {
-						Resource: &composed.Unstructured{
-							Unstructured: unstructured.Unstructured{
-								Object: map[string]any{
-									"apiVersion": ProtectionGroupVersion,
-									"kind":       "ClusterUsage",
-									"metadata": map[string]any{
-										"name": "testresource-test-watched-resource-bcd955-fn-protection",
-									},
-									"spec": map[string]any{
-										"of": map[string]any{
-											"apiVersion": "test.crossplane.io/v1",
-											"kind":       "TestResource",
-											"resourceRef": map[string]any{
-												"name": "test-watched-resource",
-											},
-										},
-										"reason": ProtectionReasonWatchOperation,
-									},
-								},
-							},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
 						},
-					},
-				},
-				err: nil,
-			},
-		},
-		"RequiredResourceWithLabel": {
-			reason: "Should create Usage for labeled required resources",
-			args: args{
-				rr: map[string][]resource.Required{
-					"some-requirement": {
-						{
-							Resource: &unstructured.Unstructured{
-								Object: map[string]any{
+						Resources: map[string]*fnv1.Resource{
+							"unnamed-composed-resource": {
+								Resource: resource.MustStructJSON(`{
 									"apiVersion": "test.crossplane.io/v1",
-									"kind":       "TestResource",
-									"metadata": map[string]any{
-										"name": "test-labeled-resource",
-										"labels": map[string]any{
-											ProtectionLabelBlockDeletion: "true",
-										},
-									},
-								},
+									"kind": "TestComposed",
+									"metadata": {}
+								}`),
 							},
 						},
 					},
 				},
 			},
 			want: want{
-				dc: map[resource.Name]*resource.DesiredComposed{
-					"TestResource-test-labeled-resource--required-resource-fn-protection": {
-						Resource: &composed.Unstructured{
-							Unstructured: unstructured.Unstructured{
-								Object: map[string]any{
-									"apiVersion": ProtectionGroupVersion,
-									"kind":       "ClusterUsage",
-									"metadata": map[string]any{
-										"name": "testresource-test-labeled-resource-d0dacf-fn-protection",
-									},
-									"spec": map[string]any{
-										"of": map[string]any{
-											"apiVersion": "test.crossplane.io/v1",
-											"kind":       "TestResource",
-											"resourceRef": map[string]any{
-												"name": "test-labeled-resource",
-											},
-										},
-										"reason": ProtectionReasonOperation,
-									},
-								},
+				rsp: &fnv1.RunFunctionResponse{
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"unnamed-composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed",
+									"metadata": {
+										"labels": {
+											"protection.fn.crossplane.io/block-deletion": "true"
+										}
+									}
+								}`),
 							},
 						},
 					},
+					Meta: &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(PendingTTL)},
+					Results: []*fnv1.Result{
+						{
+							Message:  "cannot protect composed resource \"unnamed-composed-resource\": " + SkipReasonNoName,
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						configValid,
+						{
+							Type:    ConditionTypeProtectionIncomplete,
+							Status:  fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason:  ConditionReasonResourcesSkipped,
+							Message: ptr.To("protection could not be applied to: unnamed-composed-resource (" + SkipReasonNoName + ")"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
 				},
-				err: nil,
 			},
 		},
-		"RequiredResourceWithoutLabel": {
-			reason: "Should not create Usage for unlabeled non-watched required resources",
+		"ProtectionDisabledByEnvironment": {
+			reason: "The Function should not create any Usages when the environment disables protection",
 			args: args{
-				rr: map[string][]resource.Required{
-					"some-requirement": {
+				ctx: context.Background(),
+				req: &fnv1.RunFunctionRequest{
+					Meta: &fnv1.RequestMeta{Tag: "hello"},
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "template.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"environmentEnabledPath": "protectionEnabled"
+					}`),
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/environment": {
+							"protectionEnabled": false
+						}
+					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr",
+									"labels": {
+										"protection.fn.crossplane.io/block-deletion": "true"
+									}
+								}
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/environment": {
+							"protectionEnabled": false
+						}
+					}`),
+					Results: []*fnv1.Result{
 						{
-							Resource: &unstructured.Unstructured{
-								Object: map[string]any{
+							Message:  "protection disabled by environment value \"protectionEnabled\"",
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{configValid},
+				},
+			},
+		},
+		"MaxProtectedPerRunExceeded": {
+			reason: "The Function should return a fatal result when more resources would be protected than allowed",
+			args: args{
+				ctx: context.Background(),
+				req: &fnv1.RunFunctionRequest{
+					Meta: &fnv1.RequestMeta{Tag: "hello"},
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "template.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"maxProtectedPerRun": 1
+					}`),
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"composed-resource": {
+								Resource: resource.MustStructJSON(`{
 									"apiVersion": "test.crossplane.io/v1",
-									"kind":       "TestResource",
-									"metadata": map[string]any{
-										"name": "test-unlabeled-resource",
-									},
-								},
+									"kind": "TestComposed",
+									"metadata": {
+										"labels": {
+											"protection.fn.crossplane.io/block-deletion": "true"
+										}
+									}
+								}`),
+							},
+						},
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed",
+									"metadata": {
+										"name": "my-test-composed"
+									}
+								}`),
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed",
+									"metadata": {
+										"labels": {
+											"protection.fn.crossplane.io/block-deletion": "true"
+										}
+									}
+								}`),
+							},
+						},
+					},
+					Meta: &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
+					Results: []*fnv1.Result{
+						{
+							Message:  "refusing to protect 2 resources: exceeds maxProtectedPerRun limit of 1 by 1",
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{configValid},
+				},
+			},
+		},
+		"ProtectionModeAnnotation": {
+			reason: "Protected resources should be annotated instead of creating Usages when protectionMode is annotation",
+			args: args{
+				ctx: context.Background(),
+				req: &fnv1.RunFunctionRequest{
+					Meta: &fnv1.RequestMeta{Tag: "hello"},
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "template.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"protectionMode": "annotation"
+					}`),
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR"
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"ready-composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed"
+								}`),
 							},
 						},
 					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"name": "my-test-xr"
+								}
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"ready-composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed",
+									"metadata": {
+										"name": "my-test-composed",
+										"labels": {
+											"protection.fn.crossplane.io/block-deletion": "true"
+										}
+									}
+								}`),
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+								"apiVersion": "test.crossplane.io/v1",
+								"kind": "TestXR",
+								"metadata": {
+									"annotations": {
+										"protection.fn.crossplane.io/protected": "created by function-deletion-protection because a composed resource is protected"
+									}
+								}
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"ready-composed-resource": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind": "TestComposed",
+									"metadata": {
+										"annotations": {
+											"protection.fn.crossplane.io/protected": "created by function-deletion-protection via label protection.fn.crossplane.io/block-deletion"
+										}
+									}
+								}`),
+							},
+						},
+					},
+					Meta:    &fnv1.ResponseMeta{Tag: "hello", Ttl: durationpb.New(1 * time.Minute)},
+					Results: []*fnv1.Result{},
+					Conditions: []*fnv1.Condition{
+						configValid,
+						{
+							Type:    ConditionTypeProtectionDeferred,
+							Status:  fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason:  ConditionReasonAnnotationMode,
+							Message: ptr.To("deletion protection is recorded in the " + AnnotationProtected + " annotation and enforced by an admission webhook"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
 				},
 			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(tc.args.ctx, tc.args.req)
+
+			if diff := cmp.Diff(tc.want.rsp, rsp, protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want rsp, +got rsp:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectRequiredResources(t *testing.T) {
+	type args struct {
+		rr map[string][]resource.Required
+		in *v1beta1.Input
+	}
+	type want struct {
+		dc  map[resource.Name]*resource.DesiredComposed
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"EmptyRequiredResources": {
+			reason: "Should return empty map when no required resources are provided",
+			args: args{
+				in: &v1beta1.Input{},
+				rr: map[string][]resource.Required{},
+			},
 			want: want{
 				dc:  map[resource.Name]*resource.DesiredComposed{},
 				err: nil,
 			},
 		},
-		"NamespacedWatchedResource": {
-			reason: "Should create namespaced Usage for namespaced watched resources",
+		"WatchedResourceWithoutLabel": {
+			reason: "Should create Usage for watched resources regardless of label",
 			args: args{
+				in: &v1beta1.Input{},
 				rr: map[string][]resource.Required{
 					RequirementsNameWatchedResource: {
 						{
@@ -1640,8 +1956,7 @@ func TestProtectRequiredResources(t *testing.T) {
 									"apiVersion": "test.crossplane.io/v1",
 									"kind":       "TestResource",
 									"metadata": map[string]any{
-										"name":      "test-watched-resource",
-										"namespace": "test-namespace",
+										"name": "test-watched-resource",
 									},
 								},
 							},
@@ -1651,15 +1966,15 @@ func TestProtectRequiredResources(t *testing.T) {
 			},
 			want: want{
 				dc: map[resource.Name]*resource.DesiredComposed{
-					"TestResource-test-watched-resource-test-namespace-required-resource-fn-protection": {
+					"TestResource-test-watched-resource--required-resource-fn-protection": {
 						Resource: &composed.Unstructured{
 							Unstructured: unstructured.Unstructured{
 								Object: map[string]any{
 									"apiVersion": ProtectionGroupVersion,
-									"kind":       "Usage",
+									"kind":       "ClusterUsage",
 									"metadata": map[string]any{
-										"name":      "testresource-test-watched-resource-bcd955-fn-protection",
-										"namespace": "test-namespace",
+										"name":   "testresource-test-watched-resource-bcd955-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
 									},
 									"spec": map[string]any{
 										"of": map[string]any{
@@ -1679,35 +1994,220 @@ func TestProtectRequiredResources(t *testing.T) {
 				err: nil,
 			},
 		},
-		"MultipleRequiredResources": {
-			reason: "Should create Usages for multiple required resources",
+		"RequiredResourceWithLabel": {
+			reason: "Should create Usage for labeled required resources",
 			args: args{
+				in: &v1beta1.Input{},
 				rr: map[string][]resource.Required{
-					RequirementsNameWatchedResource: {
-						{
-							Resource: &unstructured.Unstructured{
-								Object: map[string]any{
-									"apiVersion": "test.crossplane.io/v1",
-									"kind":       "TestResource",
-									"metadata": map[string]any{
-										"name": "watched-resource-1",
-									},
-								},
-							},
-						},
+					"some-requirement": {
 						{
 							Resource: &unstructured.Unstructured{
 								Object: map[string]any{
 									"apiVersion": "test.crossplane.io/v1",
 									"kind":       "TestResource",
 									"metadata": map[string]any{
-										"name": "watched-resource-2",
+										"name": "test-labeled-resource",
+										"labels": map[string]any{
+											ProtectionLabelBlockDeletion: "true",
+										},
 									},
 								},
 							},
 						},
 					},
-					"other-requirement": {
+				},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"TestResource-test-labeled-resource--required-resource-fn-protection": {
+						Resource: &composed.Unstructured{
+							Unstructured: unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": ProtectionGroupVersion,
+									"kind":       "ClusterUsage",
+									"metadata": map[string]any{
+										"name":   "testresource-test-labeled-resource-d0dacf-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
+									},
+									"spec": map[string]any{
+										"of": map[string]any{
+											"apiVersion": "test.crossplane.io/v1",
+											"kind":       "TestResource",
+											"resourceRef": map[string]any{
+												"name": "test-labeled-resource",
+											},
+										},
+										"reason": ProtectionReasonOperation,
+									},
+								},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"RequiredResourceInSharedGroup": {
+			reason: "Should create Usage for required resources of another composite that belong to the shared protection group",
+			args: args{
+				in: &v1beta1.Input{SharedProtectionGroupLabel: "example.org/protection-group"},
+				rr: map[string][]resource.Required{
+					"some-requirement": {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name": "test-grouped-resource",
+										"labels": map[string]any{
+											"example.org/protection-group": "payments",
+											"crossplane.io/composite":      "other-xr",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"TestResource-test-grouped-resource--required-resource-fn-protection": {
+						Resource: &composed.Unstructured{
+							Unstructured: unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": ProtectionGroupVersion,
+									"kind":       "ClusterUsage",
+									"metadata": map[string]any{
+										"name":   "testresource-test-grouped-resource-8a67cb-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
+									},
+									"spec": map[string]any{
+										"of": map[string]any{
+											"apiVersion": "test.crossplane.io/v1",
+											"kind":       "TestResource",
+											"resourceRef": map[string]any{
+												"name": "test-grouped-resource",
+											},
+										},
+										"reason": ProtectionReasonSharedGroup,
+									},
+								},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"RequiredResourceWithoutLabel": {
+			reason: "Should not create Usage for unlabeled non-watched required resources",
+			args: args{
+				in: &v1beta1.Input{},
+				rr: map[string][]resource.Required{
+					"some-requirement": {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name": "test-unlabeled-resource",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				dc:  map[resource.Name]*resource.DesiredComposed{},
+				err: nil,
+			},
+		},
+		"NamespacedWatchedResource": {
+			reason: "Should create namespaced Usage for namespaced watched resources",
+			args: args{
+				in: &v1beta1.Input{},
+				rr: map[string][]resource.Required{
+					RequirementsNameWatchedResource: {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name":      "test-watched-resource",
+										"namespace": "test-namespace",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"TestResource-test-watched-resource-test-namespace-required-resource-fn-protection": {
+						Resource: &composed.Unstructured{
+							Unstructured: unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": ProtectionGroupVersion,
+									"kind":       "Usage",
+									"metadata": map[string]any{
+										"name":      "testresource-test-watched-resource-bcd955-fn-protection",
+										"namespace": "test-namespace",
+										"labels":    map[string]any{LabelManagedBy: ManagedByValue},
+									},
+									"spec": map[string]any{
+										"of": map[string]any{
+											"apiVersion": "test.crossplane.io/v1",
+											"kind":       "TestResource",
+											"resourceRef": map[string]any{
+												"name": "test-watched-resource",
+											},
+										},
+										"reason": ProtectionReasonWatchOperation,
+									},
+								},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"MultipleRequiredResources": {
+			reason: "Should create Usages for multiple required resources",
+			args: args{
+				in: &v1beta1.Input{},
+				rr: map[string][]resource.Required{
+					RequirementsNameWatchedResource: {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name": "watched-resource-1",
+									},
+								},
+							},
+						},
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name": "watched-resource-2",
+									},
+								},
+							},
+						},
+					},
+					"other-requirement": {
 						{
 							Resource: &unstructured.Unstructured{
 								Object: map[string]any{
@@ -1734,7 +2234,8 @@ func TestProtectRequiredResources(t *testing.T) {
 									"apiVersion": ProtectionGroupVersion,
 									"kind":       "ClusterUsage",
 									"metadata": map[string]any{
-										"name": "testresource-watched-resource-1-915899-fn-protection",
+										"name":   "testresource-watched-resource-1-915899-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
 									},
 									"spec": map[string]any{
 										"of": map[string]any{
@@ -1757,7 +2258,8 @@ func TestProtectRequiredResources(t *testing.T) {
 									"apiVersion": ProtectionGroupVersion,
 									"kind":       "ClusterUsage",
 									"metadata": map[string]any{
-										"name": "testresource-watched-resource-2-47c204-fn-protection",
+										"name":   "testresource-watched-resource-2-47c204-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
 									},
 									"spec": map[string]any{
 										"of": map[string]any{
@@ -1780,7 +2282,8 @@ func TestProtectRequiredResources(t *testing.T) {
 									"apiVersion": ProtectionGroupVersion,
 									"kind":       "ClusterUsage",
 									"metadata": map[string]any{
-										"name": "testresource-labeled-resource-b3e5fe-fn-protection",
+										"name":   "testresource-labeled-resource-b3e5fe-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
 									},
 									"spec": map[string]any{
 										"of": map[string]any{
@@ -1800,11 +2303,97 @@ func TestProtectRequiredResources(t *testing.T) {
 				err: nil,
 			},
 		},
+		"SelectedResourceWithoutLabel": {
+			reason: "Should create Usage for resources matched by requiredSelectors regardless of label",
+			args: args{
+				in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{{
+					Name:        "critical-buckets",
+					APIVersion:  "test.crossplane.io/v1",
+					Kind:        "TestResource",
+					MatchLabels: map[string]string{"tier": "critical"},
+				}}},
+				rr: map[string][]resource.Required{
+					"critical-buckets": {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name":   "selected-resource",
+										"labels": map[string]any{"tier": "critical"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"TestResource-selected-resource--required-resource-fn-protection": {
+						Resource: &composed.Unstructured{
+							Unstructured: unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": ProtectionGroupVersion,
+									"kind":       "ClusterUsage",
+									"metadata": map[string]any{
+										"name":   "testresource-selected-resource-0520fd-fn-protection",
+										"labels": map[string]any{LabelManagedBy: ManagedByValue},
+									},
+									"spec": map[string]any{
+										"of": map[string]any{
+											"apiVersion": "test.crossplane.io/v1",
+											"kind":       "TestResource",
+											"resourceRef": map[string]any{
+												"name": "selected-resource",
+											},
+										},
+										"reason": ProtectionReasonRequiredSelector,
+									},
+								},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"UnselectedRequirementWithoutLabel": {
+			reason: "Should not create Usage for unlabeled resources of requirements not listed in requiredSelectors",
+			args: args{
+				in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{{
+					Name:        "critical-buckets",
+					APIVersion:  "test.crossplane.io/v1",
+					Kind:        "TestResource",
+					MatchLabels: map[string]string{"tier": "critical"},
+				}}},
+				rr: map[string][]resource.Required{
+					"some-requirement": {
+						{
+							Resource: &unstructured.Unstructured{
+								Object: map[string]any{
+									"apiVersion": "test.crossplane.io/v1",
+									"kind":       "TestResource",
+									"metadata": map[string]any{
+										"name": "test-unlabeled-resource",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				dc:  map[resource.Name]*resource.DesiredComposed{},
+				err: nil,
+			},
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			dc, err := ProtectRequiredResources(tc.args.rr)
+			dc, err := ProtectRequiredResources(tc.args.rr, tc.args.in, nil)
 
 			if diff := cmp.Diff(tc.want.dc, dc); diff != "" {
 				t.Errorf("%s\nProtectRequiredResources(...): -want dc, +got dc:\n%s", tc.reason, diff)
@@ -1816,3 +2405,4544 @@ func TestProtectRequiredResources(t *testing.T) {
 		})
 	}
 }
+
+func TestProtectResource(t *testing.T) {
+	type args struct {
+		u  *unstructured.Unstructured
+		in *v1beta1.Input
+	}
+	type want struct {
+		protect bool
+	}
+
+	labeled := func(val string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestResource",
+				"metadata": map[string]any{
+					"name": "test-resource",
+					"labels": map[string]any{
+						ProtectionLabelBlockDeletion: val,
+					},
+				},
+			},
+		}
+	}
+
+	unreadable := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestResource",
+				"metadata": map[string]any{
+					"name": "test-resource",
+					"labels": map[string]any{
+						ProtectionLabelBlockDeletion: true,
+					},
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NilResource": {
+			reason: "A nil resource should not be protected",
+			args:   args{u: nil, in: &v1beta1.Input{}},
+			want:   want{protect: false},
+		},
+		"NoLabel": {
+			reason: "A resource without the label should not be protected",
+			args: args{
+				u: &unstructured.Unstructured{Object: map[string]any{
+					"metadata": map[string]any{"name": "test-resource"},
+				}},
+				in: &v1beta1.Input{},
+			},
+			want: want{protect: false},
+		},
+		"LowerTrue": {
+			reason: "A lowercase true value should be protected by default",
+			args:   args{u: labeled("true"), in: &v1beta1.Input{}},
+			want:   want{protect: true},
+		},
+		"TitleTrue": {
+			reason: "A title-case True value should be protected by default",
+			args:   args{u: labeled("True"), in: &v1beta1.Input{}},
+			want:   want{protect: true},
+		},
+		"UpperTrue": {
+			reason: "An uppercase TRUE value should be protected by default",
+			args:   args{u: labeled("TRUE"), in: &v1beta1.Input{}},
+			want:   want{protect: true},
+		},
+		"False": {
+			reason: "A false value should not be protected",
+			args:   args{u: labeled("false"), in: &v1beta1.Input{}},
+			want:   want{protect: false},
+		},
+		"StrictLowerTrue": {
+			reason: "A lowercase true value should be protected in strict mode",
+			args:   args{u: labeled("true"), in: &v1beta1.Input{StrictTrueOnly: true}},
+			want:   want{protect: true},
+		},
+		"StrictTitleTrue": {
+			reason: "A title-case True value should not be protected in strict mode",
+			args:   args{u: labeled("True"), in: &v1beta1.Input{StrictTrueOnly: true}},
+			want:   want{protect: false},
+		},
+		"StrictUpperTrue": {
+			reason: "An uppercase TRUE value should not be protected in strict mode",
+			args:   args{u: labeled("TRUE"), in: &v1beta1.Input{StrictTrueOnly: true}},
+			want:   want{protect: false},
+		},
+		"PresenceOnlyLabelEmptyValue": {
+			reason: "A presence-only label with an empty value should be protected",
+			args: args{
+				u: &unstructured.Unstructured{Object: map[string]any{
+					"metadata": map[string]any{
+						"name":   "test-resource",
+						"labels": map[string]any{"example.com/critical": ""},
+					},
+				}},
+				in: &v1beta1.Input{PresenceOnlyLabels: []string{"example.com/critical"}},
+			},
+			want: want{protect: true},
+		},
+		"PresenceOnlyLabelArbitraryValue": {
+			reason: "A presence-only label with an arbitrary value should be protected",
+			args: args{
+				u: &unstructured.Unstructured{Object: map[string]any{
+					"metadata": map[string]any{
+						"name":   "test-resource",
+						"labels": map[string]any{"example.com/critical": "no"},
+					},
+				}},
+				in: &v1beta1.Input{PresenceOnlyLabels: []string{"other", "example.com/critical"}},
+			},
+			want: want{protect: true},
+		},
+		"PresenceOnlyLabelAbsent": {
+			reason: "A resource without any presence-only label should not be protected",
+			args: args{
+				u: &unstructured.Unstructured{Object: map[string]any{
+					"metadata": map[string]any{
+						"name":   "test-resource",
+						"labels": map[string]any{"example.com/other": "true"},
+					},
+				}},
+				in: &v1beta1.Input{PresenceOnlyLabels: []string{"example.com/critical"}},
+			},
+			want: want{protect: false},
+		},
+		"UnreadableLabelsFailOpen": {
+			reason: "A resource whose labels cannot be read should not be protected by default",
+			args:   args{u: unreadable(), in: &v1beta1.Input{}},
+			want:   want{protect: false},
+		},
+		"UnreadableLabelsFailClosed": {
+			reason: "A resource whose labels cannot be read should be protected when failing closed",
+			args:   args{u: unreadable(), in: &v1beta1.Input{FailClosed: true}},
+			want:   want{protect: true},
+		},
+		"UnreadableLabelsFailClosedMatchingKind": {
+			reason: "A critical resource whose labels cannot be read should be protected when failing closed",
+			args: args{u: unreadable(), in: &v1beta1.Input{
+				FailClosed:      true,
+				FailClosedKinds: []v1beta1.GroupKind{{Group: "test.crossplane.io", Kind: "TestResource"}},
+			}},
+			want: want{protect: true},
+		},
+		"UnreadableLabelsFailClosedOtherKind": {
+			reason: "A non-critical resource whose labels cannot be read should not be protected when failing closed",
+			args: args{u: unreadable(), in: &v1beta1.Input{
+				FailClosed:      true,
+				FailClosedKinds: []v1beta1.GroupKind{{Group: "test.crossplane.io", Kind: "OtherResource"}},
+			}},
+			want: want{protect: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ProtectResource(tc.args.u, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.protect, got); diff != "" {
+				t.Errorf("%s\nProtectResource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLabelProtected(t *testing.T) {
+	type args struct {
+		desired  *unstructured.Unstructured
+		observed *unstructured.Unstructured
+		source   v1beta1.LabelSource
+	}
+	type want struct {
+		protect bool
+	}
+
+	labeled := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"labels": map[string]any{ProtectionLabelBlockDeletion: "true"}},
+	}}
+	unlabeled := &unstructured.Unstructured{Object: map[string]any{}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"EitherDesired": {
+			reason: "A label in the desired state should protect by default",
+			args:   args{desired: labeled, observed: unlabeled},
+			want:   want{protect: true},
+		},
+		"EitherObserved": {
+			reason: "A label in the observed state should protect with either source",
+			args:   args{desired: unlabeled, observed: labeled, source: v1beta1.LabelSourceEither},
+			want:   want{protect: true},
+		},
+		"DesiredOnlyDesiredLabeled": {
+			reason: "A label in the desired state should protect with the desired source",
+			args:   args{desired: labeled, observed: unlabeled, source: v1beta1.LabelSourceDesired},
+			want:   want{protect: true},
+		},
+		"DesiredOnlyObservedLabeled": {
+			reason: "A label in the observed state should not protect with the desired source",
+			args:   args{desired: unlabeled, observed: labeled, source: v1beta1.LabelSourceDesired},
+			want:   want{protect: false},
+		},
+		"ObservedOnlyObservedLabeled": {
+			reason: "A label in the observed state should protect with the observed source",
+			args:   args{desired: unlabeled, observed: labeled, source: v1beta1.LabelSourceObserved},
+			want:   want{protect: true},
+		},
+		"ObservedOnlyDesiredLabeled": {
+			reason: "A label in the desired state should not protect with the observed source",
+			args:   args{desired: labeled, observed: unlabeled, source: v1beta1.LabelSourceObserved},
+			want:   want{protect: false},
+		},
+		"BothLabeled": {
+			reason: "Labels in both states should protect with the both source",
+			args:   args{desired: labeled, observed: labeled, source: v1beta1.LabelSourceBoth},
+			want:   want{protect: true},
+		},
+		"BothOneLabeled": {
+			reason: "A label in only one state should not protect with the both source",
+			args:   args{desired: labeled, observed: unlabeled, source: v1beta1.LabelSourceBoth},
+			want:   want{protect: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := LabelProtected(tc.args.desired, tc.args.observed, &v1beta1.Input{LabelSource: tc.args.source})
+
+			if diff := cmp.Diff(tc.want.protect, got); diff != "" {
+				t.Errorf("%s\nLabelProtected(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOptedOut(t *testing.T) {
+	type args struct {
+		u  *unstructured.Unstructured
+		in *v1beta1.Input
+	}
+	type want struct {
+		optedOut bool
+	}
+
+	withMeta := func(labels, annotations map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"labels": labels, "annotations": annotations},
+		}}
+	}
+	in := &v1beta1.Input{OptOutAnnotation: "example.org/skip-protection"}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Nil": {
+			reason: "A missing resource should not opt out",
+			args:   args{in: in},
+			want:   want{},
+		},
+		"Label": {
+			reason: "A resource that sets the protection label to false should opt out",
+			args:   args{u: withMeta(map[string]any{ProtectionLabelBlockDeletion: "false"}, nil), in: &v1beta1.Input{}},
+			want:   want{optedOut: true},
+		},
+		"LabelTrueCaseInsensitive": {
+			reason: "A resource that sets the protection label to True should not opt out by default",
+			args:   args{u: withMeta(map[string]any{ProtectionLabelBlockDeletion: "True"}, nil), in: &v1beta1.Input{}},
+			want:   want{},
+		},
+		"LabelTrueStrict": {
+			reason: "A resource that sets the protection label to True should opt out with strictTrueOnly, as the value does not enable protection",
+			args:   args{u: withMeta(map[string]any{ProtectionLabelBlockDeletion: "True"}, nil), in: &v1beta1.Input{StrictTrueOnly: true}},
+			want:   want{optedOut: true},
+		},
+		"Annotation": {
+			reason: "A resource that sets the opt-out annotation to a true value should opt out",
+			args:   args{u: withMeta(nil, map[string]any{"example.org/skip-protection": "1"}), in: in},
+			want:   want{optedOut: true},
+		},
+		"AnnotationFalse": {
+			reason: "A resource that sets the opt-out annotation to false should not opt out",
+			args:   args{u: withMeta(nil, map[string]any{"example.org/skip-protection": "false"}), in: in},
+			want:   want{},
+		},
+		"AnnotationNotBool": {
+			reason: "A resource that sets the opt-out annotation to an unrecognized value should not opt out",
+			args:   args{u: withMeta(nil, map[string]any{"example.org/skip-protection": "please"}), in: in},
+			want:   want{},
+		},
+		"AnnotationNotConfigured": {
+			reason: "The annotation should be ignored if no opt-out annotation is configured",
+			args:   args{u: withMeta(nil, map[string]any{"example.org/skip-protection": "true"}), in: &v1beta1.Input{}},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := OptedOut(tc.args.u, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.optedOut, got); diff != "" {
+				t.Errorf("%s\nOptedOut(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRuleReason(t *testing.T) {
+	type args struct {
+		desired  *unstructured.Unstructured
+		observed *unstructured.Unstructured
+		in       *v1beta1.Input
+	}
+	type want struct {
+		reason  string
+		protect bool
+	}
+
+	instance := func(labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata":   map[string]any{"name": "my-db", "labels": labels},
+		}}
+	}
+	critical := []v1beta1.ProtectionRule{{MatchLabels: map[string]string{"tier": "critical"}}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoRules": {
+			reason: "A resource should not be protected without rules",
+			args:   args{desired: instance(nil), observed: instance(nil), in: &v1beta1.Input{}},
+			want:   want{},
+		},
+		"ObservedMatches": {
+			reason: "A resource should be protected if its observed state matches a rule",
+			args:   args{desired: instance(nil), observed: instance(map[string]any{"tier": "critical"}), in: &v1beta1.Input{ProtectionRules: critical}},
+			want:   want{reason: ProtectionReasonRule, protect: true},
+		},
+		"DesiredMatches": {
+			reason: "A resource should be protected if its desired state matches a rule",
+			args:   args{desired: instance(map[string]any{"tier": "critical"}), observed: instance(nil), in: &v1beta1.Input{ProtectionRules: critical}},
+			want:   want{reason: ProtectionReasonRule, protect: true},
+		},
+		"RuleReason": {
+			reason: "The reason of the matching rule should be used",
+			args: args{desired: instance(nil), observed: instance(nil), in: &v1beta1.Input{ProtectionRules: []v1beta1.ProtectionRule{
+				{Kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}, Reason: "databases are protected by the platform team"},
+			}}},
+			want: want{reason: "databases are protected by the platform team", protect: true},
+		},
+		"ExcludedKind": {
+			reason: "A resource of an excluded kind should not be protected by rules",
+			args: args{desired: instance(nil), observed: instance(map[string]any{"tier": "critical"}), in: &v1beta1.Input{
+				ProtectionRules: critical,
+				ExcludeKinds:    []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}},
+			}},
+			want: want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			reason, protect := RuleReason(tc.args.desired, tc.args.observed, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Errorf("%s\nRuleReason(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.protect, protect); diff != "" {
+				t.Errorf("%s\nRuleReason(...): -want protect, +got protect:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectComposedResources(t *testing.T) {
+	type args struct {
+		oxr      *resource.Composite
+		desired  map[resource.Name]*resource.DesiredComposed
+		observed map[resource.Name]resource.ObservedComposed
+		in       *v1beta1.Input
+	}
+	type want struct {
+		dc      map[resource.Name]*resource.DesiredComposed
+		skipped []SkippedResource
+		err     error
+	}
+
+	cd := func(obj map[string]any) *composed.Unstructured {
+		return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}
+	}
+	revisioned := func(rev string) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"db": {Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"name":   "my-db",
+					"labels": map[string]any{LabelCompositionRevision: rev},
+				},
+			})},
+		}
+	}
+	controlledBy := func(owner string) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"db": {Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"name": "my-db",
+					"ownerReferences": []any{
+						map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestXR", "name": owner, "uid": "1", "controller": true},
+					},
+				},
+			})},
+		}
+	}
+	labeledDB := func() map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"db": {Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+				},
+			})},
+		}
+	}
+	dbUsage := func(reason string) map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"db-usage": {Resource: cd(map[string]any{
+				"apiVersion": ProtectionGroupVersion,
+				"kind":       "ClusterUsage",
+				"metadata": map[string]any{
+					"name":   GenerateName("testcomposed-my-db", UsageNameSuffix),
+					"labels": map[string]any{LabelManagedBy: ManagedByValue},
+				},
+				"spec": map[string]any{
+					"of": map[string]any{
+						"apiVersion":  "test.crossplane.io/v1",
+						"kind":        "TestComposed",
+						"resourceRef": map[string]any{"name": "my-db"},
+					},
+					"reason": reason,
+				},
+			})},
+		}
+	}
+	xrAtRevision := &resource.Composite{Resource: composite.New()}
+	xrAtRevision.Resource.Object = map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestXR",
+		"metadata":   map[string]any{"name": "my-xr"},
+		"spec": map[string]any{
+			"crossplane": map[string]any{
+				"compositionRevisionRef": map[string]any{"name": "my-composition-abc123"},
+			},
+		},
+	}
+	declaring := func(protection map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr"},
+			"spec":       map[string]any{"protection": protection},
+		}
+		return c
+	}
+	conditioned := func(conditions ...any) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"db": {Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"name": "my-db"},
+				"status":     map[string]any{"conditions": conditions},
+			})},
+		}
+	}
+	unlabeledDB := func() map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"db": {Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+			})},
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"OwnedResource": {
+			reason: "A resource controlled by the composite should be protected",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: controlledBy("my-xr"),
+				in:       &v1beta1.Input{},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"ForeignResource": {
+			reason: "A resource controlled by another composite should not be protected by default",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: controlledBy("other-xr"),
+				in:       &v1beta1.Input{},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ForeignResourceAllowed": {
+			reason: "A resource controlled by another composite should be protected if onlyOwnedResources is false",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: controlledBy("other-xr"),
+				in:       &v1beta1.Input{OnlyOwnedResources: ptr.To(false)},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"ProtectionSpec": {
+			reason: "A resource should be protected with the reason declared in the composite's spec",
+			args: args{
+				oxr: declaring(map[string]any{
+					"enabled": true,
+					"reason":  "protected by the platform team",
+					"kinds":   []any{map[string]any{"group": "test.crossplane.io", "kind": "TestComposed"}},
+				}),
+				desired:  unlabeledDB(),
+				observed: revisioned(""),
+				in:       &v1beta1.Input{ProtectionSpecPath: "spec.protection"},
+			},
+			want: want{dc: dbUsage("protected by the platform team")},
+		},
+		"ProtectionSpecDefaultReason": {
+			reason: "A resource should be protected with the default reason if the composite's spec declares none",
+			args: args{
+				oxr:      declaring(map[string]any{"enabled": true}),
+				desired:  unlabeledDB(),
+				observed: revisioned(""),
+				in:       &v1beta1.Input{ProtectionSpecPath: "spec.protection"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonSpec)},
+		},
+		"ProtectionSpecOtherKind": {
+			reason: "A resource should not be protected if its kind is not declared in the composite's spec",
+			args: args{
+				oxr: declaring(map[string]any{
+					"enabled": true,
+					"kinds":   []any{map[string]any{"group": "s3.aws.upbound.io", "kind": "Bucket"}},
+				}),
+				desired:  unlabeledDB(),
+				observed: revisioned(""),
+				in:       &v1beta1.Input{ProtectionSpecPath: "spec.protection"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ProtectionSpecDisabled": {
+			reason: "A resource should not be protected if the composite's spec does not enable protection",
+			args: args{
+				oxr:      declaring(map[string]any{"enabled": false}),
+				desired:  unlabeledDB(),
+				observed: revisioned(""),
+				in:       &v1beta1.Input{ProtectionSpecPath: "spec.protection"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"CurrentRevision": {
+			reason: "A resource from the composite's current revision should be protected",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: revisioned("my-composition-abc123"),
+				in:       &v1beta1.Input{ProtectRevisionLabel: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"OtherRevision": {
+			reason: "A resource from another revision should not be protected",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: revisioned("my-composition-old"),
+				in:       &v1beta1.Input{ProtectRevisionLabel: true},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ConfiguredRevision": {
+			reason: "A resource from the configured revision should be protected even if it is not current",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: revisioned("my-composition-canary"),
+				in:       &v1beta1.Input{ProtectRevisionLabel: true, ProtectRevision: "my-composition-canary"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"DefaultProtect": {
+			reason: "An unlabeled resource should be protected when protection is on by default",
+			args: args{
+				desired:  map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: revisioned(""),
+				in:       &v1beta1.Input{DefaultProtect: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonDefault)},
+		},
+		"DefaultProtectOptOut": {
+			reason: "A resource that opts out should not be protected when protection is on by default",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"labels": map[string]any{ProtectionLabelBlockDeletion: "false"},
+					},
+				})}},
+				observed: revisioned(""),
+				in:       &v1beta1.Input{DefaultProtect: true},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"DefaultProtectOptOutAnnotation": {
+			reason: "A resource that opts out with the annotation should not be protected when protection is on by default",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"annotations": map[string]any{"example.org/skip-protection": "true"},
+					},
+				})}},
+				observed: revisioned(""),
+				in:       &v1beta1.Input{DefaultProtect: true, OptOutAnnotation: "example.org/skip-protection"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"OptOutAnnotationDoesNotOverrideLabel": {
+			reason: "A labeled resource should be protected even if it opts out of default protection with the annotation",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"labels":      map[string]any{ProtectionLabelBlockDeletion: "true"},
+						"annotations": map[string]any{"example.org/skip-protection": "true"},
+					},
+				})}},
+				observed: revisioned(""),
+				in:       &v1beta1.Input{DefaultProtect: true, OptOutAnnotation: "example.org/skip-protection"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"AllSelectorsMatch": {
+			reason: "A resource whose labels match all of requireAllSelectors should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"labels": map[string]any{"tier": "critical", "env": "prod"},
+					},
+				})}},
+				observed: revisioned(""),
+				in: &v1beta1.Input{RequireAllSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"tier": "critical"}},
+					{MatchLabels: map[string]string{"env": "prod"}},
+				}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonAllSelectors)},
+		},
+		"AllSelectorsPartialMatch": {
+			reason: "A resource whose labels only match some of requireAllSelectors should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"labels": map[string]any{"tier": "critical", "env": "dev"},
+					},
+				})}},
+				observed: revisioned(""),
+				in: &v1beta1.Input{RequireAllSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"tier": "critical"}},
+					{MatchLabels: map[string]string{"env": "prod"}},
+				}},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"SharedGroup": {
+			reason: "A resource that belongs to the shared protection group should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":   "my-db",
+						"labels": map[string]any{"example.org/protection-group": "payments"},
+					},
+				})}},
+				in: &v1beta1.Input{SharedProtectionGroupLabel: "example.org/protection-group"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonSharedGroup)},
+		},
+		"DefaultProtectLabelWins": {
+			reason: "A resource labeled true should be protected by the label even if the other state opts out",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":   "my-db",
+						"labels": map[string]any{ProtectionLabelBlockDeletion: "false"},
+					},
+				})}},
+				in: &v1beta1.Input{DefaultProtect: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"StatusMatches": {
+			reason: "A resource whose status matches should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"status":     map[string]any{"atProvider": map[string]any{"hasData": true}},
+				})}},
+				in: &v1beta1.Input{ProtectIfStatusPath: "status.atProvider.hasData", ProtectIfStatusEquals: "true"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonStatus)},
+		},
+		"StatusDoesNotMatch": {
+			reason: "A resource whose status does not match should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"status":     map[string]any{"atProvider": map[string]any{"hasData": false}},
+				})}},
+				in: &v1beta1.Input{ProtectIfStatusPath: "status.atProvider.hasData", ProtectIfStatusEquals: "true"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ExternalNameMatches": {
+			reason: "A resource whose external name matches protectExternalNameRegex should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":        "my-db",
+						"annotations": map[string]any{"crossplane.io/external-name": "legacy-prod-db"},
+					},
+				})}},
+				in: &v1beta1.Input{ProtectExternalNameRegex: "prod"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonExternalName)},
+		},
+		"FieldMatches": {
+			reason: "A resource with a field matching protectIfFieldMatches should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"forProvider": map[string]any{"ami": "ami-0abc123"}},
+				})}},
+				in: &v1beta1.Input{ProtectIfFieldMatches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.ami", Regex: `^ami-0abc`}}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonFieldMatch)},
+		},
+		"FieldDoesNotMatch": {
+			reason: "A resource with a field not matching protectIfFieldMatches should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"forProvider": map[string]any{"ami": "ami-0def456"}},
+				})}},
+				in: &v1beta1.Input{ProtectIfFieldMatches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.ami", Regex: `^ami-0abc`}}},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ProtectNewestN": {
+			reason: "Only the newest protectNewestN resources of protectNewestKind should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db":     {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})},
+					"old-db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"db": {Resource: cd(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata":   map[string]any{"name": "my-db", "creationTimestamp": "2026-01-02T00:00:00Z"},
+					})},
+					"old-db": {Resource: cd(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata":   map[string]any{"name": "my-old-db", "creationTimestamp": "2026-01-01T00:00:00Z"},
+					})},
+				},
+				in: &v1beta1.Input{ProtectNewestN: 1, ProtectNewestKind: &v1beta1.GroupKind{Group: "test.crossplane.io", Kind: "TestComposed"}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonNewest)},
+		},
+		"ControlledByComposite": {
+			reason: "A resource controlled by the composite should be protected when protectByControllerRef is set",
+			args: args{
+				oxr:     xrAtRevision,
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name": "my-db",
+						"ownerReferences": []any{map[string]any{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind":       "TestXR",
+							"name":       "my-xr",
+							"controller": true,
+						}},
+					},
+				})}},
+				in: &v1beta1.Input{ProtectByControllerRef: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonControllerRef)},
+		},
+		"ControlledByOtherComposite": {
+			reason: "A resource controlled by another composite should not be protected",
+			args: args{
+				oxr:     xrAtRevision,
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name": "my-db",
+						"ownerReferences": []any{map[string]any{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind":       "TestXR",
+							"name":       "other-xr",
+							"controller": true,
+						}},
+					},
+				})}},
+				in: &v1beta1.Input{ProtectByControllerRef: true},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ObserveOnlySkipped": {
+			reason: "An observe-only resource should not be protected by default",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"managementPolicies": []any{"Observe"}},
+				})}},
+				in: &v1beta1.Input{},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ObserveOnlyProtected": {
+			reason: "An observe-only resource should be protected when skipObserveOnly is false",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"managementPolicies": []any{"Observe"}},
+				})}},
+				in: &v1beta1.Input{SkipObserveOnly: ptr.To(false)},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"ReferencedByComposite": {
+			reason: "A resource referenced by the composite at a refPaths entry should be protected",
+			args: args{
+				oxr: &resource.Composite{Resource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestXR",
+					"metadata":   map[string]any{"name": "my-xr"},
+					"spec":       map[string]any{"dbRef": map[string]any{"name": "my-db"}},
+				}}}},
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+				})}},
+				in: &v1beta1.Input{RefPaths: []string{"spec.dbRef"}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonReferenced)},
+		},
+		"DesiredOnly": {
+			reason: "A named desired resource should be protected before it is observed when protectDesiredOnly is set",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":   "my-db",
+						"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+					},
+				})}},
+				observed: map[resource.Name]resource.ObservedComposed{},
+				in:       &v1beta1.Input{ProtectDesiredOnly: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"DesiredOnlyNamespacedComposite": {
+			reason: "A desired resource of a namespaced composite should be protected in the composite's namespace",
+			args: args{
+				oxr: &resource.Composite{Resource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestXR",
+					"metadata":   map[string]any{"name": "my-xr", "namespace": "team-a"},
+				}}}},
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":   "my-db",
+						"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+					},
+				})}},
+				observed: map[resource.Name]resource.ObservedComposed{},
+				in:       &v1beta1.Input{ProtectDesiredOnly: true},
+			},
+			want: want{dc: func() map[resource.Name]*resource.DesiredComposed {
+				dc := dbUsage(ProtectionReasonLabel)
+				dc["db-usage"].Resource.SetKind("Usage")
+				dc["db-usage"].Resource.SetNamespace("team-a")
+				return dc
+			}()},
+		},
+		"DesiredOnlyUnnamed": {
+			reason: "An unnamed desired resource should quietly wait to be protected until it is observed",
+			args: args{
+				desired:  labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{},
+				in:       &v1beta1.Input{ProtectDesiredOnly: true},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{},
+			},
+		},
+		"DesiredOnlyDisabled": {
+			reason: "A desired resource should not be protected before it is observed by default",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"name":   "my-db",
+						"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+					},
+				})}},
+				observed: map[resource.Name]resource.ObservedComposed{},
+				in:       &v1beta1.Input{},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ManagedResourcesOnly": {
+			reason: "protectWhen should be able to protect managed resources but not helper objects without a kinds list",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db": {Resource: cd(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"spec":       map[string]any{"forProvider": map[string]any{"region": "eu-west-1"}},
+					})},
+					"settings": {Resource: cd(map[string]any{"apiVersion": "v1", "kind": "ConfigMap"})},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"db": {Resource: cd(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata":   map[string]any{"name": "my-db"},
+					})},
+					"settings": {Resource: cd(map[string]any{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata":   map[string]any{"name": "my-settings"},
+					})},
+				},
+				in: &v1beta1.Input{ProtectWhen: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider", Operator: v1beta1.MatchOperatorExists}}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonExpression)},
+		},
+		"LabelValue": {
+			reason: "A resource with a label matching matchLabelEquals should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"labels": map[string]any{"tier": "critical"}},
+				})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+				})}},
+				in: &v1beta1.Input{MatchLabelEquals: []v1beta1.LabelMatch{{Key: "tier", Value: "critical"}}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabelValue)},
+		},
+		"OrphanDeletionPolicySkipped": {
+			reason: "A resource with an Orphan deletion policy should not be protected when onlyProtectDeletePolicy is set",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"deletionPolicy": "Orphan"},
+				})}},
+				in: &v1beta1.Input{OnlyProtectDeletePolicy: true},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"DeleteDeletionPolicyProtected": {
+			reason: "A resource with a Delete deletion policy should be protected when onlyProtectDeletePolicy is set",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"deletionPolicy": "Delete"},
+				})}},
+				in: &v1beta1.Input{OnlyProtectDeletePolicy: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"OrphanDeletionPolicyProtectedByDefault": {
+			reason: "A resource with an Orphan deletion policy should be protected unless onlyProtectDeletePolicy is set",
+			args: args{
+				desired: labeledDB(),
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+					"spec":       map[string]any{"deletionPolicy": "Orphan"},
+				})}},
+				in: &v1beta1.Input{},
+			},
+			want: want{dc: dbUsage(ProtectionReasonLabel)},
+		},
+		"ConnectionSecret": {
+			reason: "A resource that writes a connection secret should be protected when protectIfConnectionSecret is set",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"spec":       map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "db-conn"}},
+				})}},
+				observed: map[resource.Name]resource.ObservedComposed{"db": {Resource: cd(map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata":   map[string]any{"name": "my-db"},
+				})}},
+				in: &v1beta1.Input{ProtectIfConnectionSecret: true},
+			},
+			want: want{dc: dbUsage(ProtectionReasonConnectionSecret)},
+		},
+		"APIVersionMigration": {
+			reason: "A Usage that references a previous API version should be regenerated with the observed API version",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.m.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+						},
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.m.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"name":      "my-bucket",
+							"namespace": "default",
+						},
+					}}}},
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "Usage",
+						"metadata": map[string]any{
+							"name":      GenerateName("bucket-my-bucket", UsageNameSuffix),
+							"namespace": "default",
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonLabel,
+						},
+					}}}},
+				},
+				in: &v1beta1.Input{},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "Usage",
+						"metadata": map[string]any{
+							"name":      GenerateName("bucket-my-bucket", UsageNameSuffix),
+							"namespace": "default",
+							"labels":    map[string]any{LabelManagedBy: ManagedByValue},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.m.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonLabel,
+						},
+					}}}},
+				},
+			},
+		},
+		"APIVersionMigrationKeepsIdentity": {
+			reason: "A Usage that references a previous API version should keep its name, labels and annotations when it is regenerated",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.m.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+						},
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.m.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"name":      "my-bucket",
+							"namespace": "default",
+						},
+					}}}},
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "Usage",
+						"metadata": map[string]any{
+							"name":        "bucket-my-bucket-0a1b2c-fn-protection",
+							"namespace":   "default",
+							"labels":      map[string]any{LabelManagedBy: ManagedByValue, "example.org/team": "storage"},
+							"annotations": map[string]any{"example.org/ticket": "OPS-1"},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonLabel,
+						},
+					}}}},
+				},
+				in: &v1beta1.Input{NamingScheme: v1beta1.NamingSchemeHash},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "Usage",
+						"metadata": map[string]any{
+							"name":        "bucket-my-bucket-0a1b2c-fn-protection",
+							"namespace":   "default",
+							"labels":      map[string]any{LabelManagedBy: ManagedByValue, "example.org/team": "storage"},
+							"annotations": map[string]any{"example.org/ticket": "OPS-1"},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.m.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonLabel,
+						},
+					}}}},
+				},
+			},
+		},
+		"OwnedByProtectedKind": {
+			reason: "A resource owned by a protected kind should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata": map[string]any{
+							"name": "my-db",
+							"ownerReferences": []any{
+								map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-xr", "uid": "1"},
+							},
+						},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectByOwnerKinds: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XDatabase"}}},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"db-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "ClusterUsage",
+						"metadata": map[string]any{
+							"name":   GenerateName("testcomposed-my-db", UsageNameSuffix),
+							"labels": map[string]any{LabelManagedBy: ManagedByValue},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "test.crossplane.io/v1",
+								"kind":        "TestComposed",
+								"resourceRef": map[string]any{"name": "my-db"},
+							},
+							"reason": ProtectionReasonOwnerKind,
+						},
+					}}}},
+				},
+			},
+		},
+		"ProtectedProvider": {
+			reason: "A resource from a protected provider should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata":   map[string]any{"name": "my-bucket"},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectProviders: []string{"aws.upbound.io"}},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "ClusterUsage",
+						"metadata": map[string]any{
+							"name":   GenerateName("bucket-my-bucket", UsageNameSuffix),
+							"labels": map[string]any{LabelManagedBy: ManagedByValue},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonProvider,
+						},
+					}}}},
+				},
+			},
+		},
+		"PriorityAtLeast": {
+			reason: "A resource with a priority at or above the threshold should be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"annotations": map[string]any{DefaultPriorityAnnotation: "90"},
+						},
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata":   map[string]any{"name": "my-bucket"},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectIfPriorityAtLeast: ptr.To(90)},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "ClusterUsage",
+						"metadata": map[string]any{
+							"name":   GenerateName("bucket-my-bucket", UsageNameSuffix),
+							"labels": map[string]any{LabelManagedBy: ManagedByValue},
+						},
+						"spec": map[string]any{
+							"of": map[string]any{
+								"apiVersion":  "s3.aws.upbound.io/v1beta1",
+								"kind":        "Bucket",
+								"resourceRef": map[string]any{"name": "my-bucket"},
+							},
+							"reason": ProtectionReasonPriority,
+						},
+					}}}},
+				},
+			},
+		},
+		"AnnotationRegex": {
+			reason: "A resource with an annotation matching matchAnnotationRegex should be protected",
+			args: args{
+				oxr: xrAtRevision,
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db": {Resource: cd(map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata": map[string]any{
+							"annotations": map[string]any{"environment": "production"},
+						},
+					})},
+				},
+				observed: revisioned("my-composition-abc123"),
+				in:       &v1beta1.Input{MatchAnnotationRegex: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "^prod"}}},
+			},
+			want: want{dc: dbUsage(ProtectionReasonAnnotationRegex)},
+		},
+		"ConditionTrue": {
+			reason: "A resource reporting the protectIfConditionTrue condition with status True should be protected",
+			args: args{
+				desired:  unlabeledDB(),
+				observed: conditioned(map[string]any{"type": "Critical", "status": "True"}),
+				in:       &v1beta1.Input{ProtectIfConditionTrue: "Critical"},
+			},
+			want: want{dc: dbUsage(ProtectionReasonCondition)},
+		},
+		"ConditionFalse": {
+			reason: "A resource reporting the protectIfConditionTrue condition with status False should not be protected",
+			args: args{
+				desired:  unlabeledDB(),
+				observed: conditioned(map[string]any{"type": "Critical", "status": "False"}),
+				in:       &v1beta1.Input{ProtectIfConditionTrue: "Critical"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ConditionAbsent": {
+			reason: "A resource without the protectIfConditionTrue condition should not be protected",
+			args: args{
+				desired:  unlabeledDB(),
+				observed: conditioned(map[string]any{"type": "Ready", "status": "True"}),
+				in:       &v1beta1.Input{ProtectIfConditionTrue: "Critical"},
+			},
+			want: want{dc: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"Sampled": {
+			reason: "A resource sampled by sampleProtectPercent should be protected",
+			args: args{
+				desired:  unlabeledDB(),
+				observed: revisioned(""),
+				in:       &v1beta1.Input{SampleProtectPercent: 100},
+			},
+			want: want{dc: dbUsage(ProtectionReasonSample)},
+		},
+		"InvalidAnnotationRegex": {
+			reason: "An invalid matchAnnotationRegex pattern should return an error",
+			args: args{
+				oxr:      xrAtRevision,
+				desired:  labeledDB(),
+				observed: revisioned("my-composition-abc123"),
+				in:       &v1beta1.Input{MatchAnnotationRegex: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "[prod"}}},
+			},
+			want: want{
+				dc:  map[resource.Name]*resource.DesiredComposed{},
+				err: cmpopts.AnyError,
+			},
+		},
+		"PriorityBelow": {
+			reason: "A resource with a priority below the threshold should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"annotations": map[string]any{DefaultPriorityAnnotation: "50"},
+						},
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "s3.aws.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata":   map[string]any{"name": "my-bucket"},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectIfPriorityAtLeast: ptr.To(90)},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{},
+			},
+		},
+		"OtherProvider": {
+			reason: "A resource from a provider that is not protected should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "storage.gcp.upbound.io/v1beta1",
+						"kind":       "Bucket",
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "storage.gcp.upbound.io/v1beta1",
+						"kind":       "Bucket",
+						"metadata":   map[string]any{"name": "my-bucket"},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectProviders: []string{"aws.upbound.io"}},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{},
+			},
+		},
+		"OwnedByOtherKind": {
+			reason: "A resource owned by a kind that is not protected should not be protected",
+			args: args{
+				desired: map[resource.Name]*resource.DesiredComposed{
+					"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+					}}}},
+				},
+				observed: map[resource.Name]resource.ObservedComposed{
+					"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind":       "TestComposed",
+						"metadata": map[string]any{
+							"name": "my-db",
+							"ownerReferences": []any{
+								map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XNetwork", "name": "my-xr", "uid": "1"},
+							},
+						},
+					}}}},
+				},
+				in: &v1beta1.Input{ProtectByOwnerKinds: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XDatabase"}}},
+			},
+			want: want{
+				dc: map[resource.Name]*resource.DesiredComposed{},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := CompileMatchers(tc.args.in)
+			if err != nil {
+				t.Fatalf("%s\nCompileMatchers(...): unexpected error: %v", tc.reason, err)
+			}
+			f := &Function{log: logging.NewNopLogger()}
+			dc, skipped, err := f.ProtectComposedResources(context.Background(), tc.args.oxr, tc.args.desired, tc.args.observed, tc.args.in, m, nil)
+
+			if diff := cmp.Diff(tc.want.dc, dc); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want dc, +got dc:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.skipped, skipped); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want skipped, +got skipped:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAnnotateProtected(t *testing.T) {
+	type args struct {
+		oxr      *resource.Composite
+		desired  map[resource.Name]*resource.DesiredComposed
+		observed map[resource.Name]resource.ObservedComposed
+		in       *v1beta1.Input
+	}
+	type want struct {
+		annotations map[resource.Name]map[string]string
+	}
+
+	labeled := func() map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+				},
+			}}}},
+			"pending": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata": map[string]any{
+					"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+				},
+			}}}},
+		}
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-bucket"},
+		}}}},
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"AnnotateProtected": {
+			reason: "A protected desired resource should be annotated with its Usage name, resources that are not observed yet should not",
+			args:   args{desired: labeled(), observed: observed, in: &v1beta1.Input{AnnotateProtected: true}},
+			want: want{
+				annotations: map[resource.Name]map[string]string{
+					"bucket":  {AnnotationProtectedBy: GenerateName("testcomposed-my-bucket", UsageNameSuffix)},
+					"pending": nil,
+				},
+			},
+		},
+		"AnnotateProtectedDisabled": {
+			reason: "Desired resources should not be annotated by default",
+			args:   args{desired: labeled(), observed: observed, in: &v1beta1.Input{}},
+			want: want{
+				annotations: map[resource.Name]map[string]string{
+					"bucket":  nil,
+					"pending": nil,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger()}
+			if _, _, err := f.ProtectComposedResources(context.Background(), tc.args.oxr, tc.args.desired, tc.args.observed, tc.args.in, Matchers{}, nil); err != nil {
+				t.Fatalf("%s\nf.ProtectComposedResources(...): unexpected error: %v", tc.reason, err)
+			}
+
+			got := map[resource.Name]map[string]string{}
+			for n, d := range tc.args.desired {
+				got[n] = d.Resource.GetAnnotations()
+			}
+			if diff := cmp.Diff(tc.want.annotations, got); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClearLabelAfterProtect(t *testing.T) {
+	in := &v1beta1.Input{ClearLabelAfterProtect: true}
+	usageName := resource.Name("bucket-usage")
+
+	desired := func(labels map[string]any) map[resource.Name]*resource.DesiredComposed {
+		obj := map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{},
+		}
+		if labels != nil {
+			obj["metadata"] = map[string]any{"labels": labels}
+		}
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}},
+		}
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-bucket"},
+		}}}},
+	}
+
+	f := &Function{log: logging.NewNopLogger()}
+
+	// The first reconcile creates the Usage and keeps the label, since the
+	// Usage does not exist yet.
+	d1 := desired(map[string]any{ProtectionLabelBlockDeletion: "true"})
+	dc1, _, err := f.ProtectComposedResources(context.Background(), nil, d1, observed, in, Matchers{}, nil)
+	if err != nil {
+		t.Fatalf("first reconcile: unexpected error: %v", err)
+	}
+	if _, ok := dc1[usageName]; !ok {
+		t.Fatalf("first reconcile: expected Usage %q to be generated", usageName)
+	}
+	if diff := cmp.Diff(map[string]string{ProtectionLabelBlockDeletion: "true"}, d1["bucket"].Resource.GetLabels()); diff != "" {
+		t.Errorf("first reconcile: label should be kept until the Usage exists: -want, +got:\n%s", diff)
+	}
+
+	// The second reconcile observes the Usage and clears the label, while
+	// still generating the Usage.
+	observed[usageName] = resource.ObservedComposed{Resource: dc1[usageName].Resource}
+	d2 := desired(map[string]any{ProtectionLabelBlockDeletion: "true"})
+	dc2, _, err := f.ProtectComposedResources(context.Background(), nil, d2, observed, in, Matchers{}, nil)
+	if err != nil {
+		t.Fatalf("second reconcile: unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(dc1[usageName], dc2[usageName]); diff != "" {
+		t.Errorf("second reconcile: Usage should be unchanged: -want, +got:\n%s", diff)
+	}
+	if got := d2["bucket"].Resource.GetLabels(); len(got) != 0 {
+		t.Errorf("second reconcile: label should be cleared, got %v", got)
+	}
+
+	// Once the label is gone the existing Usage keeps the resource protected.
+	d3 := desired(nil)
+	dc3, _, err := f.ProtectComposedResources(context.Background(), nil, d3, observed, in, Matchers{}, nil)
+	if err != nil {
+		t.Fatalf("third reconcile: unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(dc1[usageName], dc3[usageName]); diff != "" {
+		t.Errorf("third reconcile: Usage should be kept without the label: -want, +got:\n%s", diff)
+	}
+
+	// Explicitly setting the label to false releases protection.
+	d4 := desired(map[string]any{ProtectionLabelBlockDeletion: "false"})
+	dc4, _, err := f.ProtectComposedResources(context.Background(), nil, d4, observed, in, Matchers{}, nil)
+	if err != nil {
+		t.Fatalf("release: unexpected error: %v", err)
+	}
+	if _, ok := dc4[usageName]; ok {
+		t.Errorf("release: Usage %q should not be generated when the label is false", usageName)
+	}
+}
+
+func TestGenerateUsage(t *testing.T) {
+	type args struct {
+		u      *unstructured.Unstructured
+		reason string
+		in     *v1beta1.Input
+	}
+	type want struct {
+		usage map[string]any
+	}
+
+	bucket := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "s3.aws.upbound.io/v1beta1",
+		"kind":       "Bucket",
+		"metadata":   map[string]any{"name": "my-bucket"},
+	}}
+	annotated := bucket.DeepCopy()
+	annotated.SetAnnotations(map[string]string{AnnotationReason: "holds audit logs"})
+	runbook := bucket.DeepCopy()
+	runbook.SetAnnotations(map[string]string{AnnotationRunbook: "https://runbooks.example.org/buckets"})
+	usage := func(metadata, spec map[string]any) map[string]any {
+		m := map[string]any{
+			"name":   "bucket-my-bucket-018c9b-fn-protection",
+			"labels": map[string]any{LabelManagedBy: ManagedByValue},
+		}
+		maps.Copy(m, metadata)
+		sp := map[string]any{
+			"of": map[string]any{
+				"apiVersion":  "s3.aws.upbound.io/v1beta1",
+				"kind":        "Bucket",
+				"resourceRef": map[string]any{"name": "my-bucket"},
+			},
+			"reason": ProtectionReasonLabel,
+		}
+		maps.Copy(sp, spec)
+		return map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata":   m,
+			"spec":       sp,
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Default": {
+			reason: "A Usage should not set release options by default",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{}},
+			want:   want{usage: usage(nil, nil)},
+		},
+		"HashNamingScheme": {
+			reason: "A Usage should be named with a hash of the protected resource's reference with the hash naming scheme",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{NamingScheme: v1beta1.NamingSchemeHash}},
+			want:   want{usage: usage(map[string]any{"name": "bucket-my-bucket-16e648-fn-protection"}, nil)},
+		},
+		"Runbook": {
+			reason: "The URL of the runbook annotation should be appended to the reason",
+			args:   args{u: runbook, reason: ProtectionReasonLabel, in: &v1beta1.Input{}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": ProtectionReasonLabel + ". See: https://runbooks.example.org/buckets",
+			})},
+		},
+		"RunbookDefaultReason": {
+			reason: "The URL of the runbook annotation should be appended to the default reason",
+			args:   args{u: runbook, reason: ProtectionReasonDefault, in: &v1beta1.Input{}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": ProtectionReasonDefault + ". See: https://runbooks.example.org/buckets",
+			})},
+		},
+		"RunbookReasonWithPeriod": {
+			reason: "A reason that already ends in a period should not get a second one before the runbook URL",
+			args: args{u: func() *unstructured.Unstructured {
+				u := runbook.DeepCopy()
+				u.SetAnnotations(map[string]string{AnnotationRunbook: "https://runbooks.example.org/buckets", AnnotationReason: "holds audit logs."})
+				return u
+			}(), reason: ProtectionReasonLabel, in: &v1beta1.Input{}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "holds audit logs. See: https://runbooks.example.org/buckets",
+			})},
+		},
+		"RunbookAfterSuffix": {
+			reason: "The URL of the runbook annotation should follow the reason suffix",
+			args:   args{u: runbook, reason: ProtectionReasonLabel, in: &v1beta1.Input{ReasonSuffix: "(team-a)", IncludeLabelInReason: ptr.To(false)}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": ProtectionReasonLabelWithoutKey + " (team-a). See: https://runbooks.example.org/buckets",
+			})},
+		},
+		"UsageGroupVersion": {
+			reason: "A ClusterUsage should use the overridden group and version",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{UsageGroupVersion: "protection.example.org/v1alpha1"}},
+			want: want{usage: func() map[string]any {
+				u := usage(nil, nil)
+				u["apiVersion"] = "protection.example.org/v1alpha1"
+				return u
+			}()},
+		},
+		"UsageGroupVersionNamespaced": {
+			reason: "A Usage of a namespaced resource should use the overridden group and version",
+			args: args{u: func() *unstructured.Unstructured {
+				u := bucket.DeepCopy()
+				u.SetNamespace("team-a")
+				return u
+			}(), reason: ProtectionReasonLabel, in: &v1beta1.Input{UsageGroupVersion: "protection.example.org/v1alpha1"}},
+			want: want{usage: func() map[string]any {
+				u := usage(map[string]any{"namespace": "team-a"}, nil)
+				u["apiVersion"] = "protection.example.org/v1alpha1"
+				u["kind"] = "Usage"
+				return u
+			}()},
+		},
+		"OnReleaseReplay": {
+			reason: "A replay release policy should set the annotation and spec.replayDeletion",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{OnRelease: v1beta1.OnReleaseReplay}},
+			want: want{usage: usage(
+				map[string]any{"annotations": map[string]any{AnnotationOnRelease: "replay"}},
+				map[string]any{"replayDeletion": true},
+			)},
+		},
+		"OnReleaseBlock": {
+			reason: "A block release policy should only set the annotation",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{OnRelease: v1beta1.OnReleaseBlock}},
+			want: want{usage: usage(
+				map[string]any{"annotations": map[string]any{AnnotationOnRelease: "block"}},
+				nil,
+			)},
+		},
+		"RedactReason": {
+			reason: "Matching parts of the reason should be redacted",
+			args:   args{u: bucket, reason: "protected for ticket SEC-1234", in: &v1beta1.Input{RedactReasonPatterns: []string{`SEC-[0-9]+`}}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "protected for ticket [REDACTED]",
+			})},
+		},
+		"IncludeLabelInReason": {
+			reason: "The label reason should include the label key when includeLabelInReason is true",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{IncludeLabelInReason: ptr.To(true)}},
+			want:   want{usage: usage(nil, nil)},
+		},
+		"ExcludeLabelFromReason": {
+			reason: "The label reason should omit the label key when includeLabelInReason is false",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{IncludeLabelInReason: ptr.To(false)}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": ProtectionReasonLabelWithoutKey,
+			})},
+		},
+		"ExcludeLabelKeepsOtherReasons": {
+			reason: "Reasons other than the label reason should not be changed when includeLabelInReason is false",
+			args:   args{u: bucket, reason: ProtectionReasonDefault, in: &v1beta1.Input{IncludeLabelInReason: ptr.To(false)}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": ProtectionReasonDefault,
+			})},
+		},
+		"TriggerReasonLabel": {
+			reason: "The reason should name the label trigger when triggerReasons is set",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{TriggerReasons: true}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "Protected due to: label(" + ProtectionLabelBlockDeletion + ")",
+			})},
+		},
+		"TriggerReasonPolicy": {
+			reason: "The reason should name the policy trigger when triggerReasons is set",
+			args:   args{u: bucket, reason: ProtectionReasonExpression, in: &v1beta1.Input{TriggerReasons: true}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "Protected due to: policy(protectWhen)",
+			})},
+		},
+		"ReasonAnnotation": {
+			reason: "The reason annotation of the protected resource should win by default",
+			args:   args{u: annotated, reason: ProtectionReasonLabel, in: &v1beta1.Input{TriggerReasons: true}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "holds audit logs",
+			})},
+		},
+		"ReasonPrecedenceTriggerFirst": {
+			reason: "The trigger reason should win over the annotation if it comes first",
+			args: args{u: annotated, reason: ProtectionReasonLabel, in: &v1beta1.Input{
+				TriggerReasons:   true,
+				ReasonPrecedence: []v1beta1.ReasonSource{v1beta1.ReasonSourceTrigger, v1beta1.ReasonSourceAnnotation, v1beta1.ReasonSourceDefault},
+			}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "Protected due to: label(" + ProtectionLabelBlockDeletion + ")",
+			})},
+		},
+		"ReasonPrecedenceDefaultFirst": {
+			reason: "The generated reason should win over all other sources if it comes first",
+			args: args{u: annotated, reason: ProtectionReasonLabel, in: &v1beta1.Input{
+				TriggerReasons:   true,
+				ReasonPrecedence: []v1beta1.ReasonSource{v1beta1.ReasonSourceDefault, v1beta1.ReasonSourceAnnotation},
+			}},
+			want: want{usage: usage(nil, nil)},
+		},
+		"ReasonPrecedenceSkipsAbsent": {
+			reason: "Sources that are not present should be skipped",
+			args: args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{
+				TriggerReasons:   true,
+				ReasonPrecedence: []v1beta1.ReasonSource{v1beta1.ReasonSourceAnnotation, v1beta1.ReasonSourceTrigger},
+			}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "Protected due to: label(" + ProtectionLabelBlockDeletion + ")",
+			})},
+		},
+		"ReasonPrecedenceTriggerDisabled": {
+			reason: "The trigger source should not be present unless triggerReasons is set",
+			args: args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{
+				ReasonPrecedence: []v1beta1.ReasonSource{v1beta1.ReasonSourceTrigger, v1beta1.ReasonSourceDefault},
+			}},
+			want: want{usage: usage(nil, nil)},
+		},
+		"ReasonAnnotationDecorated": {
+			reason: "The annotation reason should still be decorated",
+			args:   args{u: annotated, reason: ProtectionReasonLabel, in: &v1beta1.Input{ReasonPrefix: "[PROD]"}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "[PROD] holds audit logs",
+			})},
+		},
+		"ReasonPrefixAndSuffix": {
+			reason: "The reason should be decorated with the configured prefix and suffix",
+			args:   args{u: bucket, reason: ProtectionReasonLabel, in: &v1beta1.Input{ReasonPrefix: "[PROD-PROTECTION]", ReasonSuffix: "(contact: platform)"}},
+			want: want{usage: usage(nil, map[string]any{
+				"reason": "[PROD-PROTECTION] " + ProtectionReasonLabel + " (contact: platform)",
+			})},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GenerateUsage(tc.args.u, tc.args.reason, tc.args.in, CompileRedactions(tc.args.in.RedactReasonPatterns))
+
+			if diff := cmp.Diff(tc.want.usage, got); diff != "" {
+				t.Errorf("%s\nGenerateUsage(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectComposite(t *testing.T) {
+	type args struct {
+		oxr            *resource.Composite
+		dxr            *resource.Composite
+		protectedCount int
+		in             *v1beta1.Input
+	}
+	type want struct {
+		reason string
+		err    error
+	}
+
+	xr := func(labels map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr", "labels": labels},
+		}
+		return c
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NotProtected": {
+			reason: "An unlabeled composite without protected resources should not be protected",
+			args:   args{oxr: xr(nil), dxr: xr(nil), in: &v1beta1.Input{}},
+			want:   want{},
+		},
+		"ChildProtected": {
+			reason: "A composite should be protected when a composed resource is protected",
+			args:   args{oxr: xr(nil), dxr: xr(nil), protectedCount: 1, in: &v1beta1.Input{}},
+			want:   want{reason: ProtectionReasonCompositeChildResource},
+		},
+		"Labeled": {
+			reason: "A labeled composite should be protected",
+			args:   args{oxr: xr(map[string]any{ProtectionLabelBlockDeletion: "true"}), dxr: xr(nil), in: &v1beta1.Input{}},
+			want:   want{reason: ProtectionReasonLabel},
+		},
+		"DefaultProtect": {
+			reason: "An unlabeled composite should be protected when protection is on by default",
+			args:   args{oxr: xr(nil), dxr: xr(nil), in: &v1beta1.Input{DefaultProtect: true}},
+			want:   want{reason: ProtectionReasonDefault},
+		},
+		"DefaultProtectOptOut": {
+			reason: "A composite that opts out should not be protected when protection is on by default",
+			args:   args{oxr: xr(nil), dxr: xr(map[string]any{ProtectionLabelBlockDeletion: "false"}), in: &v1beta1.Input{DefaultProtect: true}},
+			want:   want{},
+		},
+		"SharedGroup": {
+			reason: "A composite that belongs to the shared protection group should be protected",
+			args:   args{oxr: xr(map[string]any{"example.org/protection-group": "payments"}), dxr: xr(nil), in: &v1beta1.Input{SharedProtectionGroupLabel: "example.org/protection-group"}},
+			want:   want{reason: ProtectionReasonSharedGroup},
+		},
+		"ProtectionSpec": {
+			reason: "A composite declaring protection in its spec should be protected with the declared reason",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr(nil)
+					c.Resource.Object["spec"] = map[string]any{"protection": map[string]any{"enabled": true, "reason": "protected by the platform team"}}
+					return c
+				}(),
+				dxr: xr(nil),
+				in:  &v1beta1.Input{ProtectionSpecPath: "spec.protection"},
+			},
+			want: want{reason: "protected by the platform team"},
+		},
+		"ProtectionSpecNotConfigured": {
+			reason: "A composite declaring protection in its spec should not be protected without protectionSpecPath",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr(nil)
+					c.Resource.Object["spec"] = map[string]any{"protection": map[string]any{"enabled": true}}
+					return c
+				}(),
+				dxr: xr(nil),
+				in:  &v1beta1.Input{},
+			},
+			want: want{},
+		},
+		"DefaultProtectOptOutAnnotation": {
+			reason: "A composite that opts out with the annotation should not be protected when protection is on by default",
+			args: args{
+				oxr: xr(nil),
+				dxr: func() *resource.Composite {
+					c := xr(nil)
+					c.Resource.SetAnnotations(map[string]string{"example.org/skip-protection": "true"})
+					return c
+				}(),
+				in: &v1beta1.Input{DefaultProtect: true, OptOutAnnotation: "example.org/skip-protection"},
+			},
+			want: want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := CompileMatchers(tc.args.in)
+			if err != nil {
+				t.Fatalf("%s\nCompileMatchers(...): unexpected error: %v", tc.reason, err)
+			}
+			f := &Function{log: logging.NewNopLogger()}
+			got, err := f.ProtectComposite(tc.args.oxr, tc.args.dxr, tc.args.protectedCount, tc.args.in, m, nil)
+
+			var reason string
+			for _, u := range got {
+				reason, _ = u.Resource.GetString("spec.reason")
+			}
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Errorf("%s\nf.ProtectComposite(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nf.ProtectComposite(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectCompositeScope(t *testing.T) {
+	type args struct {
+		namespace string
+	}
+	type want struct {
+		kind      string
+		namespace string
+	}
+
+	xr := func(namespace string) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr", "namespace": namespace},
+		}
+		return c
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NamespacedXR": {
+			reason: "A namespaced composite should be protected by a Usage in its namespace",
+			args:   args{namespace: "team-a"},
+			want:   want{kind: protectionv1beta1.UsageKind, namespace: "team-a"},
+		},
+		"ClusterScopedXR": {
+			reason: "A cluster-scoped composite should be protected by a ClusterUsage",
+			args:   args{},
+			want:   want{kind: protectionv1beta1.ClusterUsageKind},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger()}
+			got, err := f.ProtectComposite(xr(tc.args.namespace), xr(tc.args.namespace), 1, &v1beta1.Input{}, Matchers{}, nil)
+			if err != nil {
+				t.Fatalf("%s\nf.ProtectComposite(...): unexpected error: %v", tc.reason, err)
+			}
+
+			u, ok := got["xr-my-xr-usage"]
+			if !ok {
+				t.Fatalf("%s\nf.ProtectComposite(...): want usage xr-my-xr-usage, got %v", tc.reason, slices.Sorted(maps.Keys(got)))
+			}
+			if diff := cmp.Diff(tc.want.kind, u.Resource.GetKind()); diff != "" {
+				t.Errorf("%s\nf.ProtectComposite(...): -want kind, +got kind:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.namespace, u.Resource.GetNamespace()); diff != "" {
+				t.Errorf("%s\nf.ProtectComposite(...): -want namespace, +got namespace:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectCompositeResourceSelector(t *testing.T) {
+	type args struct {
+		labels map[string]any
+		in     *v1beta1.Input
+	}
+	type want struct {
+		of map[string]any
+	}
+
+	byName := map[string]any{
+		"apiVersion":  "test.crossplane.io/v1",
+		"kind":        "TestXR",
+		"resourceRef": map[string]any{"name": "my-xr-x7k2p"},
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Selector": {
+			reason: "The composite should be selected by the configured labels",
+			args: args{
+				labels: map[string]any{"team": "a", "app": "db", "env": "prod"},
+				in:     &v1beta1.Input{XRUseResourceSelector: true, XRSelectorLabels: []string{"team", "app"}},
+			},
+			want: want{of: map[string]any{
+				"apiVersion":       "test.crossplane.io/v1",
+				"kind":             "TestXR",
+				"resourceSelector": map[string]any{"matchLabels": map[string]any{"team": "a", "app": "db"}},
+			}},
+		},
+		"MissingLabel": {
+			reason: "A composite without one of the configured labels should be referenced by name",
+			args: args{
+				labels: map[string]any{"team": "a"},
+				in:     &v1beta1.Input{XRUseResourceSelector: true, XRSelectorLabels: []string{"team", "app"}},
+			},
+			want: want{of: byName},
+		},
+		"Disabled": {
+			reason: "The composite should be referenced by name by default",
+			args: args{
+				labels: map[string]any{"team": "a", "app": "db"},
+				in:     &v1beta1.Input{XRSelectorLabels: []string{"team", "app"}},
+			},
+			want: want{of: byName},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr := &resource.Composite{Resource: composite.New()}
+			xr.Resource.Object = map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestXR",
+				"metadata":   map[string]any{"name": "my-xr-x7k2p", "labels": tc.args.labels},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			got, err := f.ProtectComposite(xr, xr, 1, tc.args.in, Matchers{}, nil)
+			if err != nil {
+				t.Fatalf("%s\nf.ProtectComposite(...): unexpected error: %v", tc.reason, err)
+			}
+
+			u, ok := got["xr-my-xr-x7k2p-usage"]
+			if !ok {
+				t.Fatalf("%s\nf.ProtectComposite(...): want usage xr-my-xr-x7k2p-usage, got %v", tc.reason, slices.Sorted(maps.Keys(got)))
+			}
+			of, _, _ := unstructured.NestedMap(u.Resource.Object, "spec", "of")
+			if diff := cmp.Diff(tc.want.of, of); diff != "" {
+				t.Errorf("%s\nf.ProtectComposite(...): -want spec.of, +got spec.of:\n%s", tc.reason, diff)
+			}
+			if err := ValidateUsage(u.Resource); err != nil {
+				t.Errorf("%s\nValidateUsage(...): unexpected error: %v", tc.reason, err)
+			}
+		})
+	}
+}
+
+func TestRedactReason(t *testing.T) {
+	type args struct {
+		reason   string
+		patterns []string
+	}
+	type want struct {
+		reason string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPatterns": {
+			reason: "A reason should be unchanged without patterns",
+			args:   args{reason: "protected for ticket SEC-1234"},
+			want:   want{reason: "protected for ticket SEC-1234"},
+		},
+		"NoMatch": {
+			reason: "A reason should be unchanged if no pattern matches",
+			args:   args{reason: "protected for ticket SEC-1234", patterns: []string{`OPS-[0-9]+`}},
+			want:   want{reason: "protected for ticket SEC-1234"},
+		},
+		"MultipleMatches": {
+			reason: "Every match of every pattern should be redacted",
+			args:   args{reason: "SEC-1 and SEC-2 owned by alice@example.com", patterns: []string{`SEC-[0-9]+`, `[a-z]+@example\.com`}},
+			want:   want{reason: "[REDACTED] and [REDACTED] owned by [REDACTED]"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RedactReason(tc.args.reason, CompileRedactions(tc.args.patterns))
+
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("%s\nRedactReason(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDecorateReason(t *testing.T) {
+	type args struct {
+		reason string
+		prefix string
+		suffix string
+	}
+	type want struct {
+		reason string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoDecoration": {
+			reason: "The reason should be unchanged without a prefix or suffix",
+			args:   args{reason: ProtectionReasonDefault},
+			want:   want{reason: ProtectionReasonDefault},
+		},
+		"PrefixAndDefaultReason": {
+			reason: "A prefix should be separated from the reason by a single space",
+			args:   args{reason: ProtectionReasonDefault, prefix: "[PROD-PROTECTION]"},
+			want:   want{reason: "[PROD-PROTECTION] " + ProtectionReasonDefault},
+		},
+		"SuffixAndCustomReason": {
+			reason: "A suffix should be separated from the reason by a single space",
+			args:   args{reason: "protected by the platform team", suffix: "see runbook"},
+			want:   want{reason: "protected by the platform team see runbook"},
+		},
+		"NoDoubleSpacing": {
+			reason: "Surrounding whitespace should not result in double spacing",
+			args:   args{reason: " protected ", prefix: "[PROD] ", suffix: " (ticket)"},
+			want:   want{reason: "[PROD] protected (ticket)"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := DecorateReason(tc.args.reason, tc.args.prefix, tc.args.suffix)
+
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("%s\nDecorateReason(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheckMaxProtected(t *testing.T) {
+	type args struct {
+		count int
+		limit int
+	}
+	type want struct {
+		err string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoLimit": {
+			reason: "A limit of zero should allow any number of resources",
+			args:   args{count: 5000, limit: 0},
+			want:   want{},
+		},
+		"UnderLimit": {
+			reason: "A count under the limit should be allowed",
+			args:   args{count: 2, limit: 3},
+			want:   want{},
+		},
+		"AtLimit": {
+			reason: "A count at the limit should be allowed",
+			args:   args{count: 3, limit: 3},
+			want:   want{},
+		},
+		"OverLimit": {
+			reason: "A count over the limit should be rejected",
+			args:   args{count: 5, limit: 3},
+			want:   want{err: "refusing to protect 5 resources: exceeds maxProtectedPerRun limit of 3 by 2"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckMaxProtected(tc.args.count, tc.args.limit)
+
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, got); diff != "" {
+				t.Errorf("%s\nCheckMaxProtected(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSkippedMessage(t *testing.T) {
+	type args struct {
+		skipped []SkippedResource
+	}
+	type want struct {
+		msg string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"SingleResource": {
+			reason: "A single skipped resource should be described with its reason",
+			args:   args{skipped: []SkippedResource{{Name: "bucket", Reason: SkipReasonNoName}}},
+			want:   want{msg: "protection could not be applied to: bucket (" + SkipReasonNoName + ")"},
+		},
+		"MultipleResources": {
+			reason: "Multiple skipped resources should be separated",
+			args: args{skipped: []SkippedResource{
+				{Name: "bucket", Reason: SkipReasonNoName},
+				{Name: "db-usage", Reason: "spec.reason is required"},
+			}},
+			want: want{msg: "protection could not be applied to: bucket (" + SkipReasonNoName + "); db-usage (spec.reason is required)"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := SkippedMessage(tc.args.skipped)
+
+			if diff := cmp.Diff(tc.want.msg, got); diff != "" {
+				t.Errorf("%s\nSkippedMessage(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCountTriggers(t *testing.T) {
+	usage := func(apiVersion, kind string) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion": apiVersion,
+					"kind":       kind,
+				},
+			},
+		}}}}
+	}
+	usages := map[resource.Name]*resource.DesiredComposed{
+		"bucket-usage":   usage("s3.aws.upbound.io/v1beta1", "Bucket"),
+		"instance-usage": usage("rds.aws.upbound.io/v1beta1", "Instance"),
+	}
+
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+		kinds  []v1beta1.GroupKind
+	}
+	type want struct {
+		count int
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoTriggerKinds": {
+			reason: "Every usage should trigger composite protection when no kinds are configured",
+			args:   args{usages: usages},
+			want:   want{count: 2},
+		},
+		"TriggerKindProtected": {
+			reason: "A usage of a trigger kind should trigger composite protection",
+			args:   args{usages: usages, kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}},
+			want:   want{count: 1},
+		},
+		"NoTriggerKindProtected": {
+			reason: "Usages of other kinds should not trigger composite protection",
+			args:   args{usages: usages, kinds: []v1beta1.GroupKind{{Group: "sql.gcp.upbound.io", Kind: "DatabaseInstance"}}},
+			want:   want{count: 0},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CountTriggers(tc.args.usages, tc.args.kinds)
+
+			if diff := cmp.Diff(tc.want.count, got); diff != "" {
+				t.Errorf("%s\nCountTriggers(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestComputeDesired(t *testing.T) {
+	type args struct {
+		oxr              *resource.Composite
+		in               *v1beta1.Input
+		observedComposed map[resource.Name]resource.ObservedComposed
+		desiredComposed  map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		names       []resource.Name
+		annotations map[resource.Name]map[string]string
+		labels      map[resource.Name]map[string]string
+		results     []ProtectionResult
+		err         error
+	}
+
+	xr := func() *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr"},
+		}
+		return c
+	}
+	desired := func(labels map[string]any) map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"labels": labels},
+			}}}},
+		}
+	}
+	observed := func(name string) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"name": name},
+			}}}},
+		}
+	}
+	labeled := map[string]any{ProtectionLabelBlockDeletion: "true"}
+	withUserUsage := func(d map[resource.Name]*resource.DesiredComposed) map[resource.Name]*resource.DesiredComposed {
+		d["my-usage"] = &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":  "test.crossplane.io/v1",
+					"kind":        "TestComposed",
+					"resourceRef": map[string]any{"name": "my-bucket"},
+				},
+				"reason": "protected by the platform team",
+			},
+		}}}}
+		return d
+	}
+	labeledXR := func() *resource.Composite {
+		c := xr()
+		c.Resource.SetLabels(map[string]string{ProtectionLabelBlockDeletion: "true"})
+		return c
+	}
+	pausedObserved := func() map[resource.Name]resource.ObservedComposed {
+		o := observed("my-bucket")
+		o["bucket"].Resource.SetAnnotations(map[string]string{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"})
+		return o
+	}
+	deleting := func() *resource.Composite {
+		c := xr()
+		c.Resource.SetDeletionTimestamp(&metav1.Time{Time: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)})
+		return c
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NothingProtected": {
+			reason: "Desired resources should be returned unchanged when nothing is protected",
+			args:   args{in: &v1beta1.Input{}, observedComposed: observed("my-bucket"), desiredComposed: desired(nil)},
+			want:   want{names: []resource.Name{"bucket"}},
+		},
+		"LabeledCompositeWithoutComposed": {
+			reason: "A labeled composite should be protected even if it has no composed resources by default",
+			args:   args{oxr: labeledXR(), in: &v1beta1.Input{}},
+			want:   want{names: []resource.Name{"xr-my-xr-usage"}},
+		},
+		"RequireComposedWithoutComposed": {
+			reason: "A labeled composite without composed resources should not be protected when requireComposedForXRProtection is set",
+			args:   args{oxr: labeledXR(), in: &v1beta1.Input{RequireComposedForXRProtection: true}},
+			want:   want{names: nil},
+		},
+		"RequireComposedWithComposed": {
+			reason: "A labeled composite with composed resources should be protected when requireComposedForXRProtection is set",
+			args:   args{oxr: labeledXR(), in: &v1beta1.Input{RequireComposedForXRProtection: true}, observedComposed: observed("my-bucket"), desiredComposed: desired(nil)},
+			want:   want{names: []resource.Name{"bucket", "xr-my-xr-usage"}},
+		},
+		"ComposedAndCompositeProtected": {
+			reason: "A protected composed resource should add Usages for it and the composite",
+			args:   args{in: &v1beta1.Input{}, observedComposed: observed("my-bucket"), desiredComposed: desired(labeled)},
+			want:   want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"SubresourceReasons": {
+			reason: "A protected composed resource should get an additional Usage per subresource that is not deduplicated",
+			args: args{
+				in:               &v1beta1.Input{SubresourceReasons: map[string]string{"storage": "holds customer data", "backups": "holds backups"}},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "bucket-usage-backups", "bucket-usage-storage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage-storage": {AnnotationSubresource: "storage"},
+				},
+			},
+		},
+		"PauseOnProtect": {
+			reason: "A protected resource should be paused when pauseOnProtect is enabled",
+			args:   args{in: &v1beta1.Input{PauseOnProtect: true}, observedComposed: observed("my-bucket"), desiredComposed: desired(labeled)},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket": {meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"},
+				},
+			},
+		},
+		"AnnotateUsageOrder": {
+			reason: "Usages should be annotated with their order, the composite's Usage last",
+			args:   args{in: &v1beta1.Input{AnnotateUsageOrder: true}, observedComposed: observed("my-bucket"), desiredComposed: desired(labeled)},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage":   {AnnotationOrder: "0"},
+					"xr-my-xr-usage": {AnnotationOrder: "1"},
+				},
+			},
+		},
+		"IncludeTimestampInReason": {
+			reason: "Usages should record the time protection started",
+			args:   args{in: &v1beta1.Input{IncludeTimestampInReason: true}, observedComposed: observed("my-bucket"), desiredComposed: desired(labeled)},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage":   {AnnotationProtectedAt: "2026-10-15T12:00:00Z"},
+					"xr-my-xr-usage": {AnnotationProtectedAt: "2026-10-15T12:00:00Z"},
+				},
+			},
+		},
+		"PauseOnProtectUserUsage": {
+			reason: "A resource should not be paused when its generated Usage is dropped in favor of a user supplied Usage",
+			args:   args{in: &v1beta1.Input{PauseOnProtect: true}, observedComposed: observed("my-bucket"), desiredComposed: withUserUsage(desired(labeled))},
+			want: want{
+				names:       []resource.Name{"bucket", "my-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{"bucket": nil},
+			},
+		},
+		"PauseOnProtectResumesUnprotected": {
+			reason: "A resource paused by the function should be resumed once it is no longer protected",
+			args:   args{in: &v1beta1.Input{PauseOnProtect: true}, observedComposed: pausedObserved(), desiredComposed: desired(nil)},
+			want: want{
+				names:       []resource.Name{"bucket"},
+				annotations: map[resource.Name]map[string]string{"bucket": nil},
+			},
+		},
+		"SubresourceReasonsAnnotationMode": {
+			reason: "No subresource Usages should be generated in annotation mode",
+			args: args{
+				in:               &v1beta1.Input{ProtectionMode: v1beta1.ProtectionModeAnnotation, SubresourceReasons: map[string]string{"storage": "holds customer data"}},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{names: []resource.Name{"bucket"}},
+		},
+		"UserUsageRespected": {
+			reason: "No Usage should be generated for a resource the user already protects with a Usage",
+			args:   args{in: &v1beta1.Input{}, observedComposed: observed("my-bucket"), desiredComposed: withUserUsage(desired(labeled))},
+			want:   want{names: []resource.Name{"bucket", "my-usage", "xr-my-xr-usage"}},
+		},
+		"UserUsageNotRespected": {
+			reason: "A Usage should be generated alongside a user supplied Usage when respectUserUsages is false",
+			args:   args{in: &v1beta1.Input{RespectUserUsages: ptr.To(false)}, observedComposed: observed("my-bucket"), desiredComposed: withUserUsage(desired(labeled))},
+			want:   want{names: []resource.Name{"bucket", "bucket-usage", "my-usage", "xr-my-xr-usage"}},
+		},
+		"UnnamedResourceSkipped": {
+			reason: "A protected resource without a name should be returned as a result",
+			args:   args{in: &v1beta1.Input{}, observedComposed: observed(""), desiredComposed: desired(labeled)},
+			want: want{
+				names:   []resource.Name{"bucket"},
+				results: []ProtectionResult{{SkippedResource: SkippedResource{Name: "bucket", Reason: SkipReasonNoName}}},
+			},
+		},
+		"PreExistingDesiredResource": {
+			reason: "A Usage should not overwrite a desired resource that already uses its key",
+			args: args{
+				in:               &v1beta1.Input{},
+				observedComposed: observed("my-bucket"),
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{
+					"bucket":       desired(labeled)["bucket"],
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap"}}}},
+				},
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "bucket-usage-fn-protection", "xr-my-xr-usage"},
+				results: []ProtectionResult{{
+					SkippedResource: SkippedResource{Name: "bucket-usage", Reason: "desired composed resource already exists"},
+					RenamedTo:       "bucket-usage-fn-protection",
+				}},
+			},
+		},
+		"ClaimNamespace": {
+			reason: "Usages of a claimed composite should be annotated with the claim namespace",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr()
+					c.Resource.Object["spec"] = map[string]any{"claimRef": map[string]any{"name": "my-claim", "namespace": "team-a"}}
+					return c
+				}(),
+				in:               &v1beta1.Input{},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage":   {AnnotationClaimNamespace: "team-a"},
+					"xr-my-xr-usage": {AnnotationClaimNamespace: "team-a"},
+				},
+			},
+		},
+		"InheritXRLabels": {
+			reason: "Usages should inherit the configured labels of the composite",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr()
+					c.Resource.SetLabels(map[string]string{"team": "a", "env": "prod"})
+					return c
+				}(),
+				in:               &v1beta1.Input{InheritXRLabels: []string{"team"}},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				labels: map[resource.Name]map[string]string{
+					"bucket-usage":   {LabelManagedBy: ManagedByValue, "team": "a"},
+					"xr-my-xr-usage": {LabelManagedBy: ManagedByValue, "team": "a"},
+				},
+			},
+		},
+		"ProtectConnectionSources": {
+			reason: "Connection sources should be protected alongside a protected composite",
+			args: args{
+				oxr:              labeledXR(),
+				in:               &v1beta1.Input{ProtectConnectionSources: true},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(map[string]any{LabelConnectionSource: "true"}),
+			},
+			want: want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"ProtectConnectionSourcesUnprotectedComposite": {
+			reason: "Connection sources should not be protected if the composite is not protected",
+			args: args{
+				in:               &v1beta1.Input{ProtectConnectionSources: true},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(map[string]any{LabelConnectionSource: "true"}),
+			},
+			want: want{names: []resource.Name{"bucket"}},
+		},
+		"ProtectNamespaceAsGroup": {
+			reason: "Usages of a namespaced composite and its resources should be labeled with their namespace",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr()
+					c.Resource.SetNamespace("team-a")
+					return c
+				}(),
+				in: &v1beta1.Input{ProtectNamespaceAsGroup: true},
+				observedComposed: func() map[resource.Name]resource.ObservedComposed {
+					o := observed("my-bucket")
+					o["bucket"].Resource.SetNamespace("team-a")
+					return o
+				}(),
+				desiredComposed: desired(nil),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				labels: map[resource.Name]map[string]string{
+					"bucket-usage":   {LabelManagedBy: ManagedByValue, LabelNamespaceGroup: "team-a"},
+					"xr-my-xr-usage": {LabelManagedBy: ManagedByValue, LabelNamespaceGroup: "team-a"},
+				},
+			},
+		},
+		"ProtectNamespaceAsGroupClusterScoped": {
+			reason: "Cluster scoped resources should not be protected as a namespace group",
+			args:   args{in: &v1beta1.Input{ProtectNamespaceAsGroup: true}, observedComposed: observed("my-bucket"), desiredComposed: desired(nil)},
+			want:   want{names: []resource.Name{"bucket"}},
+		},
+		"DeletingXRAlwaysProtect": {
+			reason: "Protection should be kept for a deleting composite by default",
+			args: args{
+				oxr:              deleting(),
+				in:               &v1beta1.Input{ReleasePolicy: v1beta1.ReleasePolicyAlwaysProtect},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"DeletingXRReleaseOnDelete": {
+			reason: "Usages generated by the function should be dropped for a deleting composite when releasing on delete",
+			args: args{
+				oxr:              deleting(),
+				in:               &v1beta1.Input{ReleasePolicy: v1beta1.ReleasePolicyReleaseOnXRDelete},
+				observedComposed: observed("my-bucket"),
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{
+					"bucket": desired(labeled)["bucket"],
+					"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "ClusterUsage",
+						"metadata":   map[string]any{"labels": map[string]any{LabelManagedBy: ManagedByValue}},
+					}}}},
+				},
+			},
+			want: want{names: []resource.Name{"bucket"}},
+		},
+		"DeletingXRResumesPaused": {
+			reason: "Resources paused by the function should be resumed when protection of a deleting composite is released",
+			args: args{
+				oxr:              deleting(),
+				in:               &v1beta1.Input{ReleasePolicy: v1beta1.ReleasePolicyReleaseOnXRDelete, PauseOnProtect: true},
+				observedComposed: pausedObserved(),
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestComposed",
+					"metadata": map[string]any{
+						"labels":      labeled,
+						"annotations": map[string]any{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"},
+					},
+				}}}}},
+			},
+			want: want{
+				names:       []resource.Name{"bucket"},
+				annotations: map[resource.Name]map[string]string{"bucket": {}},
+			},
+		},
+		"DeletingXRSnapshot": {
+			reason: "Usages of a deleting composite should record a snapshot of the protected resources before they are released",
+			args: args{
+				oxr: deleting(),
+				in: &v1beta1.Input{
+					ReleasePolicy:      v1beta1.ReleasePolicyReleaseOnXRDelete,
+					SnapshotOnDeletion: true,
+				},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage":   {AnnotationSnapshot: `["TestComposed/my-bucket","TestXR/my-xr"]`},
+					"xr-my-xr-usage": {AnnotationSnapshot: `["TestComposed/my-bucket","TestXR/my-xr"]`},
+				},
+			},
+		},
+		"DeletingXRSnapshotRecorded": {
+			reason: "Protection of a deleting composite should be released once the snapshot has been recorded",
+			args: args{
+				oxr: deleting(),
+				in: &v1beta1.Input{
+					ReleasePolicy:      v1beta1.ReleasePolicyReleaseOnXRDelete,
+					SnapshotOnDeletion: true,
+				},
+				observedComposed: func() map[resource.Name]resource.ObservedComposed {
+					o := observed("my-bucket")
+					o["bucket-usage"] = resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": ProtectionGroupVersion,
+						"kind":       "ClusterUsage",
+						"metadata": map[string]any{
+							"labels":      map[string]any{LabelManagedBy: ManagedByValue},
+							"annotations": map[string]any{AnnotationSnapshot: `["TestComposed/my-bucket","TestXR/my-xr"]`},
+						},
+					}}}}
+					return o
+				}(),
+				desiredComposed: desired(labeled),
+			},
+			want: want{names: []resource.Name{"bucket"}},
+		},
+		"DeletingXRSnapshotAlwaysProtect": {
+			reason: "Usages of a deleting composite should record a snapshot and be kept by default",
+			args: args{
+				oxr:              deleting(),
+				in:               &v1beta1.Input{SnapshotOnDeletion: true},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage": {AnnotationSnapshot: `["TestComposed/my-bucket","TestXR/my-xr"]`},
+				},
+			},
+		},
+		"NotDeletingXRSnapshot": {
+			reason: "No snapshot should be recorded for a composite that is not being deleted",
+			args: args{
+				in:               &v1beta1.Input{SnapshotOnDeletion: true},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{
+				names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"},
+				annotations: map[resource.Name]map[string]string{
+					"bucket-usage": nil,
+				},
+			},
+		},
+		"NotDeletingXRReleaseOnDelete": {
+			reason: "Protection should be kept for a composite that is not being deleted",
+			args: args{
+				in:               &v1beta1.Input{ReleasePolicy: v1beta1.ReleasePolicyReleaseOnXRDelete},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(labeled),
+			},
+			want: want{names: []resource.Name{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"RenamedResource": {
+			reason: "A resource present under two keys should only be protected by one Usage",
+			args: args{
+				in: &v1beta1.Input{},
+				observedComposed: map[resource.Name]resource.ObservedComposed{
+					"bucket":     observed("my-bucket")["bucket"],
+					"old-bucket": observed("my-bucket")["bucket"],
+				},
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{
+					"bucket":     desired(labeled)["bucket"],
+					"old-bucket": desired(labeled)["bucket"],
+				},
+			},
+			want: want{names: []resource.Name{"bucket", "bucket-usage", "old-bucket", "xr-my-xr-usage"}},
+		},
+		"ReferencedSecret": {
+			reason: "A Secret referenced by the composite should be protected when protectReferencedSecrets is set",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr()
+					c.Resource.Object["spec"] = map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "db-conn", "namespace": "team-a"}}
+					return c
+				}(),
+				in: &v1beta1.Input{
+					ProtectReferencedSecrets: true,
+					SecretRefPaths:           []v1beta1.ObjectRefPath{{FieldPath: "spec.writeConnectionSecretToRef"}},
+				},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(nil),
+			},
+			want: want{names: []resource.Name{"bucket", "secret-team-a-db-conn-usage"}},
+		},
+		"ReferencedSecretV1Mode": {
+			reason: "A referenced Secret cannot be protected by a v1 Usage",
+			args: args{
+				oxr: func() *resource.Composite {
+					c := xr()
+					c.Resource.Object["spec"] = map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "db-conn", "namespace": "team-a"}}
+					return c
+				}(),
+				in: &v1beta1.Input{
+					EnableV1Mode:             true,
+					ProtectReferencedSecrets: true,
+					SecretRefPaths:           []v1beta1.ObjectRefPath{{FieldPath: "spec.writeConnectionSecretToRef"}},
+				},
+				observedComposed: observed("my-bucket"),
+				desiredComposed:  desired(nil),
+			},
+			want: want{err: cmpopts.AnyError},
+		},
+		"MaxProtectedPerRunExceeded": {
+			reason: "Exceeding maxProtectedPerRun should return an error",
+			args:   args{in: &v1beta1.Input{MaxProtectedPerRun: 1}, observedComposed: observed("my-bucket"), desiredComposed: desired(labeled)},
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			oxr := tc.args.oxr
+			if oxr == nil {
+				oxr = xr()
+			}
+			f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC) }}
+			got, results, err := f.computeDesired(context.Background(), tc.args.in, oxr, xr(), tc.args.observedComposed, tc.args.desiredComposed, nil)
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(got))); diff != "" {
+				t.Errorf("%s\nf.computeDesired(...): -want names, +got names:\n%s", tc.reason, diff)
+			}
+
+			for n, want := range tc.want.annotations {
+				if diff := cmp.Diff(want, got[n].Resource.GetAnnotations()); diff != "" {
+					t.Errorf("%s\nf.computeDesired(...): -want %s annotations, +got %s annotations:\n%s", tc.reason, n, n, diff)
+				}
+			}
+
+			for n, want := range tc.want.labels {
+				if diff := cmp.Diff(want, got[n].Resource.GetLabels()); diff != "" {
+					t.Errorf("%s\nf.computeDesired(...): -want %s labels, +got %s labels:\n%s", tc.reason, n, n, diff)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.results, results); diff != "" {
+				t.Errorf("%s\nf.computeDesired(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nf.computeDesired(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionIdempotent(t *testing.T) {
+	// The function appears twice in a pipeline: the second run receives the
+	// desired state of the first and observes the Usages it generated.
+	input := resource.MustStructJSON(`{
+		"apiVersion": "protection.fn.crossplane.io/v1beta1",
+		"kind": "Input",
+		"defaultProtect": true
+	}`)
+	observed := &fnv1.State{
+		Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind": "TestXR",
+			"metadata": {"name": "my-xr"}
+		}`)},
+		Resources: map[string]*fnv1.Resource{
+			"bucket": {Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestComposed",
+				"metadata": {"name": "my-bucket"}
+			}`)},
+		},
+	}
+	desired := &fnv1.State{
+		Resources: map[string]*fnv1.Resource{
+			"bucket": {Resource: resource.MustStructJSON(`{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind": "TestComposed"
+			}`)},
+		},
+	}
+
+	f := &Function{log: logging.NewNopLogger()}
+	first, err := f.RunFunction(context.Background(), &fnv1.RunFunctionRequest{Input: input, Observed: observed, Desired: desired})
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): first run: unexpected error: %v", err)
+	}
+
+	// Everything the first run generated now exists on the cluster.
+	for name, r := range first.GetDesired().GetResources() {
+		if _, ok := observed.Resources[name]; !ok {
+			observed.Resources[name] = r
+		}
+	}
+	second, err := f.RunFunction(context.Background(), &fnv1.RunFunctionRequest{Input: input, Observed: observed, Desired: first.GetDesired()})
+	if err != nil {
+		t.Fatalf("f.RunFunction(...): second run: unexpected error: %v", err)
+	}
+
+	want := []string{"bucket", "bucket-usage", "xr-my-xr-usage"}
+	if diff := cmp.Diff(want, slices.Sorted(maps.Keys(second.GetDesired().GetResources()))); diff != "" {
+		t.Errorf("f.RunFunction(...): second run should not protect generated Usages: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(first.GetDesired(), second.GetDesired(), protocmp.Transform()); diff != "" {
+		t.Errorf("f.RunFunction(...): second run should not change the desired state: -want, +got:\n%s", diff)
+	}
+}
+
+func TestDedupeUsages(t *testing.T) {
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		usages []resource.Name
+		n      int
+	}
+
+	usage := func(name string) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"spec": map[string]any{"of": map[string]any{
+				"apiVersion":  "s3.aws.upbound.io/v1beta1",
+				"kind":        "Bucket",
+				"resourceRef": map[string]any{"name": name},
+			}},
+		}}}}
+	}
+
+	byUsage := func(name, by string) *resource.DesiredComposed {
+		u := usage(name)
+		u.Resource.Object["spec"].(map[string]any)["by"] = map[string]any{
+			"apiVersion":  "rds.aws.upbound.io/v1beta1",
+			"kind":        "Instance",
+			"resourceRef": map[string]any{"name": by},
+		}
+		return u
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"DistinctTargets": {
+			reason: "Usages protecting different resources should be kept",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"a-usage": usage("bucket-a"),
+				"b-usage": usage("bucket-b"),
+			}},
+			want: want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+		"DistinctUsers": {
+			reason: "Usages of the same resource by different resources should be kept",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"a-usage": byUsage("my-bucket", "my-db"),
+				"b-usage": byUsage("my-bucket", "my-cache"),
+			}},
+			want: want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+		"SameTarget": {
+			reason: "Only one Usage should be kept for resources present under two keys",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"old-bucket-usage": usage("my-bucket"),
+				"bucket-usage":     usage("my-bucket"),
+			}},
+			want: want{usages: []resource.Name{"bucket-usage"}, n: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := DedupeUsages(tc.args.usages)
+
+			if diff := cmp.Diff(tc.want.n, n); diff != "" {
+				t.Errorf("%s\nDedupeUsages(...): -want count, +got count:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.usages, slices.Sorted(maps.Keys(tc.args.usages))); diff != "" {
+				t.Errorf("%s\nDedupeUsages(...): -want usages, +got usages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRespectUserUsages(t *testing.T) {
+	type args struct {
+		usages          map[resource.Name]*resource.DesiredComposed
+		desiredComposed map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		usages  []resource.Name
+		removed []resource.Name
+	}
+
+	usage := func(apiVersion, namespace, name string, labels map[string]any) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": apiVersion,
+			"kind":       "Usage",
+			"metadata":   map[string]any{"namespace": namespace, "labels": labels},
+			"spec": map[string]any{"of": map[string]any{
+				"apiVersion":  "s3.aws.upbound.io/v1beta1",
+				"kind":        "Bucket",
+				"resourceRef": map[string]any{"name": name},
+			}},
+		}}}}
+	}
+	managed := map[string]any{LabelManagedBy: ManagedByValue}
+	generated := func() map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"a-usage": usage(ProtectionGroupVersion, "team-a", "bucket-a", managed),
+			"b-usage": usage(ProtectionGroupVersion, "team-a", "bucket-b", managed),
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoUserUsages": {
+			reason: "Generated Usages should be kept if the user supplied none",
+			args:   args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{}},
+			want:   want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+		"UserUsage": {
+			reason: "A generated Usage should be removed if a user supplied Usage protects the same resource",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"my-usage": usage(ProtectionGroupVersion, "team-a", "bucket-a", nil),
+			}},
+			want: want{usages: []resource.Name{"b-usage"}, removed: []resource.Name{"a-usage"}},
+		},
+		"UserUsageWithoutNamespace": {
+			reason: "A user supplied Usage without a namespace should match generated Usages in any namespace",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"my-usage": usage(ProtectionGroupVersion, "", "bucket-b", nil),
+			}},
+			want: want{usages: []resource.Name{"a-usage"}, removed: []resource.Name{"b-usage"}},
+		},
+		"UserUsageInOtherNamespace": {
+			reason: "A user supplied Usage in another namespace protects a different resource",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"my-usage": usage(ProtectionGroupVersion, "team-b", "bucket-a", nil),
+			}},
+			want: want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+		"UserV1Usage": {
+			reason: "A user supplied Crossplane v1 Usage should be respected",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"my-usage": usage("apiextensions.crossplane.io/v1beta1", "", "bucket-a", nil),
+			}},
+			want: want{usages: []resource.Name{"b-usage"}, removed: []resource.Name{"a-usage"}},
+		},
+		"ManagedUsage": {
+			reason: "Usages generated by this function should not count as user supplied",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"a-usage": usage(ProtectionGroupVersion, "team-a", "bucket-a", managed),
+			}},
+			want: want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+		"NotAUsage": {
+			reason: "Other resources with a spec.of should not count as Usages",
+			args: args{usages: generated(), desiredComposed: map[resource.Name]*resource.DesiredComposed{
+				"other": usage("example.org/v1", "team-a", "bucket-a", nil),
+			}},
+			want: want{usages: []resource.Name{"a-usage", "b-usage"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			removed := RespectUserUsages(tc.args.usages, tc.args.desiredComposed)
+
+			if diff := cmp.Diff(tc.want.removed, removed); diff != "" {
+				t.Errorf("%s\nRespectUserUsages(...): -want removed, +got removed:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.usages, slices.Sorted(maps.Keys(tc.args.usages))); diff != "" {
+				t.Errorf("%s\nRespectUserUsages(...): -want usages, +got usages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	type args struct {
+		usages          map[resource.Name]*resource.DesiredComposed
+		desiredComposed map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		usages  []resource.Name
+		results []ProtectionResult
+	}
+
+	obj := func(labels map[string]any) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"labels": labels},
+		}}}}
+	}
+	managed := map[string]any{LabelManagedBy: ManagedByValue}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoCollision": {
+			reason: "Usages should keep their keys if no desired resource uses them",
+			args: args{
+				usages:          map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(managed)},
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{"bucket": obj(nil)},
+			},
+			want: want{usages: []resource.Name{"bucket-usage"}},
+		},
+		"ManagedResource": {
+			reason: "A Usage generated by an earlier run should be replaced",
+			args: args{
+				usages:          map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(managed)},
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(managed)},
+			},
+			want: want{usages: []resource.Name{"bucket-usage"}},
+		},
+		"Collision": {
+			reason: "A Usage should be moved to an alternate key if another resource uses its key",
+			args: args{
+				usages:          map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(managed)},
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(nil)},
+			},
+			want: want{
+				usages: []resource.Name{"bucket-usage-fn-protection"},
+				results: []ProtectionResult{{
+					SkippedResource: SkippedResource{Name: "bucket-usage", Reason: "desired composed resource already exists"},
+					RenamedTo:       "bucket-usage-fn-protection",
+				}},
+			},
+		},
+		"AlternateKeyCollision": {
+			reason: "A Usage should be dropped if its alternate key is also used",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{"bucket-usage": obj(managed)},
+				desiredComposed: map[resource.Name]*resource.DesiredComposed{
+					"bucket-usage":               obj(nil),
+					"bucket-usage-fn-protection": obj(nil),
+				},
+			},
+			want: want{
+				usages: nil,
+				results: []ProtectionResult{{
+					SkippedResource: SkippedResource{Name: "bucket-usage", Reason: `desired composed resources "bucket-usage" and "bucket-usage-fn-protection" already exist`},
+					Dropped:         true,
+				}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			results := ResolveCollisions(tc.args.usages, tc.args.desiredComposed)
+
+			if diff := cmp.Diff(tc.want.usages, slices.Sorted(maps.Keys(tc.args.usages))); diff != "" {
+				t.Errorf("%s\nResolveCollisions(...): -want usages, +got usages:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.results, results); diff != "" {
+				t.Errorf("%s\nResolveCollisions(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPreserveReasons(t *testing.T) {
+	type args struct {
+		observedReason string
+	}
+	type want struct {
+		reason string
+		n      int
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoObservedReason": {
+			reason: "The generated reason should be used if the observed Usage has no reason",
+			want:   want{reason: ProtectionReasonLabel},
+		},
+		"GeneratedReason": {
+			reason: "A reason set by an earlier run of this function should be replaced",
+			args:   args{observedReason: ProtectionReasonDefault},
+			want:   want{reason: ProtectionReasonLabel},
+		},
+		"CustomReason": {
+			reason: "A reason set by another function should be preserved",
+			args:   args{observedReason: "protected by the platform team"},
+			want:   want{reason: "protected by the platform team", n: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			usages := map[resource.Name]*resource.DesiredComposed{
+				"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": ProtectionGroupVersion,
+					"kind":       "ClusterUsage",
+					"spec":       map[string]any{"reason": ProtectionReasonLabel},
+				}}}},
+			}
+			spec := map[string]any{}
+			if tc.args.observedReason != "" {
+				spec["reason"] = tc.args.observedReason
+			}
+			observedComposed := map[resource.Name]resource.ObservedComposed{
+				"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": ProtectionGroupVersion,
+					"kind":       "ClusterUsage",
+					"spec":       spec,
+				}}}},
+			}
+
+			n := PreserveReasons(usages, observedComposed)
+			if diff := cmp.Diff(tc.want.n, n); diff != "" {
+				t.Errorf("%s\nPreserveReasons(...): -want count, +got count:\n%s", tc.reason, diff)
+			}
+			got, _, _ := unstructured.NestedString(usages["bucket-usage"].Resource.Object, "spec", "reason")
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("%s\nPreserveReasons(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStampReasons(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+
+	type args struct {
+		observedAt string
+	}
+	type want struct {
+		reason string
+		at     string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NewUsage": {
+			reason: "A new Usage should be stamped with the current time",
+			want: want{
+				reason: ProtectionReasonLabel + " at 2026-10-15T12:00:00Z",
+				at:     "2026-10-15T12:00:00Z",
+			},
+		},
+		"ObservedUsage": {
+			reason: "An observed Usage should keep the time protection started",
+			args:   args{observedAt: "2026-10-01T08:30:00Z"},
+			want: want{
+				reason: ProtectionReasonLabel + " at 2026-10-01T08:30:00Z",
+				at:     "2026-10-01T08:30:00Z",
+			},
+		},
+		"InvalidObservedTime": {
+			reason: "An observed Usage with an invalid time should be stamped with the current time",
+			args:   args{observedAt: "yesterday"},
+			want: want{
+				reason: ProtectionReasonLabel + " at 2026-10-15T12:00:00Z",
+				at:     "2026-10-15T12:00:00Z",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			usages := map[resource.Name]*resource.DesiredComposed{
+				"bucket-usage": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": ProtectionGroupVersion,
+					"kind":       "ClusterUsage",
+					"spec":       map[string]any{"reason": ProtectionReasonLabel},
+				}}}},
+			}
+			observedComposed := map[resource.Name]resource.ObservedComposed{}
+			if tc.args.observedAt != "" {
+				observedComposed["bucket-usage"] = resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": ProtectionGroupVersion,
+					"kind":       "ClusterUsage",
+					"metadata": map[string]any{
+						"annotations": map[string]any{AnnotationProtectedAt: tc.args.observedAt},
+					},
+				}}}}
+			}
+
+			StampReasons(usages, observedComposed, now)
+			got, _, _ := unstructured.NestedString(usages["bucket-usage"].Resource.Object, "spec", "reason")
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("%s\nStampReasons(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.at, usages["bucket-usage"].Resource.GetAnnotations()[AnnotationProtectedAt]); diff != "" {
+				t.Errorf("%s\nStampReasons(...): -want annotation, +got annotation:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGroupByNamespace(t *testing.T) {
+	usage := func(kind, namespace string) *resource.DesiredComposed {
+		u := &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       kind,
+		}}}
+		u.SetNamespace(namespace)
+		return &resource.DesiredComposed{Resource: u}
+	}
+
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		n      int
+		labels map[resource.Name]map[string]string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NamespacedUsages": {
+			reason: "Usages should be labeled with their namespace",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"bucket-usage": usage("Usage", "team-a"),
+				"queue-usage":  usage("Usage", "team-b"),
+			}},
+			want: want{
+				n: 2,
+				labels: map[resource.Name]map[string]string{
+					"bucket-usage": {LabelNamespaceGroup: "team-a"},
+					"queue-usage":  {LabelNamespaceGroup: "team-b"},
+				},
+			},
+		},
+		"ClusterUsages": {
+			reason: "ClusterUsages should not be labeled",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"bucket-usage": usage("ClusterUsage", ""),
+				"queue-usage":  usage("Usage", "team-a"),
+			}},
+			want: want{
+				n: 1,
+				labels: map[resource.Name]map[string]string{
+					"bucket-usage": nil,
+					"queue-usage":  {LabelNamespaceGroup: "team-a"},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := GroupByNamespace(tc.args.usages)
+			if diff := cmp.Diff(tc.want.n, n); diff != "" {
+				t.Errorf("%s\nGroupByNamespace(...): -want count, +got count:\n%s", tc.reason, diff)
+			}
+			got := map[resource.Name]map[string]string{}
+			for name, u := range tc.args.usages {
+				got[name] = u.Resource.GetLabels()
+			}
+			if diff := cmp.Diff(tc.want.labels, got); diff != "" {
+				t.Errorf("%s\nGroupByNamespace(...): -want labels, +got labels:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// syntheticComposed returns n labeled desired and observed composed resources.
+func syntheticComposed(n int) (map[resource.Name]resource.ObservedComposed, map[resource.Name]*resource.DesiredComposed) {
+	observed := make(map[resource.Name]resource.ObservedComposed, n)
+	desired := make(map[resource.Name]*resource.DesiredComposed, n)
+	for i := range n {
+		name := resource.Name(fmt.Sprintf("bucket-%d", i))
+		obj := func() *composed.Unstructured {
+			return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "s3.aws.upbound.io/v1beta1",
+				"kind":       "Bucket",
+				"metadata": map[string]any{
+					"name":   string(name),
+					"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+				},
+			}}}
+		}
+		observed[name] = resource.ObservedComposed{Resource: obj()}
+		desired[name] = &resource.DesiredComposed{Resource: obj()}
+	}
+	return observed, desired
+}
+
+func TestComputeDesiredLarge(t *testing.T) {
+	const n = 2000
+	observed, desired := syntheticComposed(n)
+	f := &Function{log: logging.NewNopLogger()}
+	oxr := &resource.Composite{Resource: composite.New()}
+	oxr.Resource.Object = map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestXR",
+		"metadata":   map[string]any{"name": "my-xr"},
+	}
+
+	got, results, err := f.computeDesired(context.Background(), &v1beta1.Input{}, oxr, oxr, observed, desired, nil)
+	if err != nil {
+		t.Fatalf("f.computeDesired(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]ProtectionResult(nil), results); diff != "" {
+		t.Errorf("f.computeDesired(...): -want results, +got results:\n%s", diff)
+	}
+	// Every resource gets a Usage, plus one for the composite.
+	if diff := cmp.Diff(2*n+1, len(got)); diff != "" {
+		t.Errorf("f.computeDesired(...): -want count, +got count:\n%s", diff)
+	}
+	for i := range n {
+		name := resource.Name(fmt.Sprintf("bucket-%d", i))
+		u, ok := got[name+"-usage"]
+		if !ok {
+			t.Fatalf("f.computeDesired(...): missing usage for %q", name)
+		}
+		ref, _ := u.Resource.GetString("spec.of.resourceRef.name")
+		if diff := cmp.Diff(string(name), ref); diff != "" {
+			t.Errorf("f.computeDesired(...): -want resourceRef, +got resourceRef:\n%s", diff)
+		}
+	}
+}
+
+func BenchmarkComputeDesired(b *testing.B) {
+	observed, desired := syntheticComposed(5000)
+	f := &Function{log: logging.NewNopLogger()}
+	oxr := &resource.Composite{Resource: composite.New()}
+	oxr.Resource.Object = map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestXR",
+		"metadata":   map[string]any{"name": "my-xr"},
+	}
+	in := &v1beta1.Input{}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := f.computeDesired(context.Background(), in, oxr, oxr, observed, desired, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// convertViaJSON is the JSON round-trip generated objects were converted with
+// before asComposed. It is kept as a reference for equivalence tests.
+func convertViaJSON(to, from any) error {
+	bs, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, to)
+}
+
+func TestAsComposed(t *testing.T) {
+	type args struct {
+		obj map[string]any
+	}
+
+	bucket := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "s3.aws.upbound.io/v1beta1",
+		"kind":       "Bucket",
+		"metadata":   map[string]any{"name": "my-bucket"},
+	}}
+	namespaced := bucket.DeepCopy()
+	namespaced.SetNamespace("team-a")
+	usage := &unstructured.Unstructured{Object: GenerateUsage(bucket, ProtectionReasonLabel, &v1beta1.Input{}, nil)}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+	}{
+		"ClusterUsage": {
+			reason: "A generated ClusterUsage should equal its JSON round-trip",
+			args:   args{obj: GenerateUsage(bucket, ProtectionReasonLabel, &v1beta1.Input{}, nil)},
+		},
+		"Usage": {
+			reason: "A generated namespaced Usage should equal its JSON round-trip",
+			args:   args{obj: GenerateUsage(namespaced, ProtectionReasonLabel, &v1beta1.Input{}, nil)},
+		},
+		"V1Usage": {
+			reason: "A generated v1 Usage should equal its JSON round-trip",
+			args:   args{obj: GenerateUsage(bucket, ProtectionReasonLabel, &v1beta1.Input{EnableV1Mode: true}, nil)},
+		},
+		"UsageWithOptions": {
+			reason: "A generated Usage with release options should equal its JSON round-trip",
+			args: args{obj: GenerateUsage(bucket, ProtectionReasonLabel, &v1beta1.Input{
+				OnRelease:    v1beta1.OnReleaseReplay,
+				ReasonPrefix: "[PROD]",
+			}, nil)},
+		},
+		"TrackingConfigMap": {
+			reason: "A generated tracking ConfigMap should equal its JSON round-trip",
+			args:   args{obj: GenerateTrackingConfigMap(usage, "tracking")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			want := composed.New()
+			if err := convertViaJSON(want, tc.args.obj); err != nil {
+				t.Fatalf("convertViaJSON(...): %v", err)
+			}
+			got := asComposed(tc.args.obj)
+
+			if diff := cmp.Diff(want.Object, got.Object); diff != "" {
+				t.Errorf("%s\nasComposed(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func BenchmarkAsComposed(b *testing.B) {
+	bucket := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "s3.aws.upbound.io/v1beta1",
+		"kind":       "Bucket",
+		"metadata":   map[string]any{"name": "my-bucket"},
+	}}
+	usage := GenerateUsage(bucket, ProtectionReasonLabel, &v1beta1.Input{}, nil)
+
+	b.Run("JSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := convertViaJSON(composed.New(), usage); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = asComposed(usage)
+		}
+	})
+}
+
+func TestRunFunctionWarnUnprotectedKinds(t *testing.T) {
+	type args struct {
+		labels string
+	}
+	type want struct {
+		results []*fnv1.Result
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Unlabeled": {
+			reason: "An unlabeled resource of a critical kind should produce a warning",
+			args:   args{labels: `{}`},
+			want: want{results: []*fnv1.Result{{
+				Severity: fnv1.Severity_SEVERITY_WARNING,
+				Message:  `composed resource "db" is of a critical kind but is not labeled with ` + ProtectionLabelBlockDeletion,
+				Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+		"Labeled": {
+			reason: "A labeled resource of a critical kind should not produce a warning",
+			args:   args{labels: `{"protection.fn.crossplane.io/block-deletion": "true"}`},
+			want:   want{results: nil},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"warnUnprotectedKinds": [{"group": "rds.aws.upbound.io", "kind": "Instance"}]
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"db": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "rds.aws.upbound.io/v1beta1",
+							"kind": "Instance",
+							"metadata": {"name": "my-db", "labels": ` + tc.args.labels + `}
+						}`)},
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "s3.aws.upbound.io/v1beta1",
+							"kind": "Bucket",
+							"metadata": {"name": "my-bucket"}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"db":     {Resource: resource.MustStructJSON(`{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance"}`)},
+						"bucket": {Resource: resource.MustStructJSON(`{"apiVersion": "s3.aws.upbound.io/v1beta1", "kind": "Bucket"}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.results, rsp.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionConditionTarget(t *testing.T) {
+	type args struct {
+		target string
+	}
+	type want struct {
+		target fnv1.Target
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Default": {
+			reason: "Conditions should target the composite and claim by default",
+			want:   want{target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM},
+		},
+		"Both": {
+			reason: "Conditions should target the composite and claim when conditionTarget is both",
+			args:   args{target: `"both"`},
+			want:   want{target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM},
+		},
+		"Composite": {
+			reason: "Conditions should only target the composite when conditionTarget is composite",
+			args:   args{target: `"composite"`},
+			want:   want{target: fnv1.Target_TARGET_COMPOSITE},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			input := `{
+				"apiVersion": "protection.fn.crossplane.io/v1beta1",
+				"kind": "Input",
+				"protectionMode": "annotation"`
+			if tc.args.target != "" {
+				input += `, "conditionTarget": ` + tc.args.target
+			}
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(input + `}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			var got *fnv1.Condition
+			for _, c := range rsp.GetConditions() {
+				if c.GetType() == ConditionTypeProtectionDeferred {
+					got = c
+				}
+			}
+			if diff := cmp.Diff(tc.want.target, got.GetTarget()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want target, +got target:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionSuccessCondition(t *testing.T) {
+	type args struct {
+		fields string
+	}
+	type want struct {
+		conditions []*fnv1.Condition
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Default": {
+			reason: "The success condition should be ConfigValid with reason InputAccepted by default",
+			want: want{conditions: []*fnv1.Condition{{
+				Type:   ConditionTypeConfigValid,
+				Status: fnv1.Status_STATUS_CONDITION_TRUE,
+				Reason: ConditionReasonInputAccepted,
+				Target: fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+		"Customized": {
+			reason: "The success condition should use the configured type and reason",
+			args:   args{fields: `, "successConditionType": "protection.example.org/Ready", "successConditionReason": "Protected"`},
+			want: want{conditions: []*fnv1.Condition{{
+				Type:   "protection.example.org/Ready",
+				Status: fnv1.Status_STATUS_CONDITION_TRUE,
+				Reason: "Protected",
+				Target: fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+		"InvalidInputUsesCustomType": {
+			reason: "A rejected Input should set the configured condition type to false",
+			args:   args{fields: `, "successConditionType": "ProtectionReady", "redactReasonPatterns": ["("]`},
+			want: want{conditions: []*fnv1.Condition{{
+				Type:    "ProtectionReady",
+				Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+				Reason:  ConditionReasonInvalidInput,
+				Message: ptr.To("invalid Function input: invalid redactReasonPatterns entry \"(\": error parsing regexp: missing closing ): `(`"),
+				Target:  fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+		"InvalidType": {
+			reason: "An invalid condition type should be rejected and reported with the default type",
+			args:   args{fields: `, "successConditionType": "not a type"`},
+			want: want{conditions: []*fnv1.Condition{{
+				Type:    ConditionTypeConfigValid,
+				Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+				Reason:  ConditionReasonInvalidInput,
+				Message: ptr.To(`invalid Function input: invalid successConditionType "not a type": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`),
+				Target:  fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input"` + tc.args.fields + `}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.conditions, rsp.GetConditions(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionPendingTTL(t *testing.T) {
+	type args struct {
+		cacheTTL string
+		name     string
+	}
+	type want struct {
+		ttl time.Duration
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NothingPending": {
+			reason: "The default TTL should be used when no protection is pending",
+			args:   args{name: "my-bucket"},
+			want:   want{ttl: time.Minute},
+		},
+		"Pending": {
+			reason: "The TTL should be shortened when protection of a resource is pending",
+			want:   want{ttl: PendingTTL},
+		},
+		"PendingShorterCacheTTL": {
+			reason: "A configured TTL shorter than the pending TTL should be kept",
+			args:   args{cacheTTL: "5s"},
+			want:   want{ttl: 5 * time.Second},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"cacheTTL": "` + tc.args.cacheTTL + `"
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "` + tc.args.name + `"}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.ttl, rsp.GetMeta().GetTtl().AsDuration()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want ttl, +got ttl:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionOnlyForCompositions(t *testing.T) {
+	type args struct {
+		composition string
+	}
+	type want struct {
+		resources []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"MatchingComposition": {
+			reason: "Resources of a composite using a listed composition should be protected",
+			args:   args{composition: "databases"},
+			want:   want{resources: []string{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+		"OtherComposition": {
+			reason: "Resources of a composite using another composition should not be protected",
+			args:   args{composition: "networks"},
+			want:   want{resources: []string{"bucket"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"onlyForCompositions": ["databases"]
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"},
+						"spec": {"crossplane": {"compositionRef": {"name": "` + tc.args.composition + `"}}}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "my-bucket"}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			got := slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))
+			if diff := cmp.Diff(tc.want.resources, got); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want resources, +got resources:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionLintOnly(t *testing.T) {
+	type args struct {
+		labels string
+	}
+	type want struct {
+		results []*fnv1.Result
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Violation": {
+			reason: "An unlabeled resource of a critical kind should be reported as a violation",
+			args:   args{labels: `{}`},
+			want: want{results: []*fnv1.Result{{
+				Severity: fnv1.Severity_SEVERITY_WARNING,
+				Message:  `composed resource "db" is of a critical kind but is not labeled with ` + ProtectionLabelBlockDeletion,
+				Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+		"NoViolation": {
+			reason: "A labeled resource of a critical kind should not be reported, and still not be protected",
+			args:   args{labels: `{"protection.fn.crossplane.io/block-deletion": "true"}`},
+			want: want{results: []*fnv1.Result{{
+				Severity: fnv1.Severity_SEVERITY_NORMAL,
+				Message:  "all composed resources of a critical kind are labeled with " + ProtectionLabelBlockDeletion,
+				Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired := &fnv1.State{
+				Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestXR",
+					"metadata": {"name": "my-xr", "labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+				}`)},
+				Resources: map[string]*fnv1.Resource{
+					"db": {Resource: resource.MustStructJSON(`{
+						"apiVersion": "rds.aws.upbound.io/v1beta1",
+						"kind": "Instance",
+						"metadata": {"labels": ` + tc.args.labels + `}
+					}`)},
+				},
+			}
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"lintOnly": true,
+					"annotateProtected": true,
+					"warnUnprotectedKinds": [{"group": "rds.aws.upbound.io", "kind": "Instance"}]
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"db": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "rds.aws.upbound.io/v1beta1",
+							"kind": "Instance",
+							"metadata": {"name": "my-db"}
+						}`)},
+					},
+				},
+				Desired: desired,
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(desired, rsp.GetDesired(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff([]*fnv1.Condition(nil), rsp.GetConditions(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.results, rsp.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionRequiredSelectors(t *testing.T) {
+	type args struct {
+		required map[string]*fnv1.Resources
+	}
+	type want struct {
+		names []string
+	}
+
+	selected := &fnv1.Resources{Items: []*fnv1.Resource{
+		{Resource: resource.MustStructJSON(`{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind": "TestResource",
+			"metadata": {"name": "a", "namespace": "team-a", "labels": {"tier": "critical"}}
+		}`)},
+		{Resource: resource.MustStructJSON(`{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind": "TestResource",
+			"metadata": {"name": "b", "namespace": "team-a", "labels": {"tier": "critical"}}
+		}`)},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NotYetSupplied": {
+			reason: "Nothing should be protected until Crossplane supplies the selected resources",
+			args:   args{},
+			want:   want{names: []string{}},
+		},
+		"Supplied": {
+			reason: "Every selected resource should be protected by a Usage",
+			args:   args{required: map[string]*fnv1.Resources{"critical": selected}},
+			want: want{names: []string{
+				"TestResource-a-team-a-required-resource-fn-protection",
+				"TestResource-b-team-a-required-resource-fn-protection",
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"requiredSelectors": [{
+						"name": "critical",
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestResource",
+						"namespace": "team-a",
+						"matchLabels": {"tier": "critical"}
+					}]
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+				},
+				RequiredResources: tc.args.required,
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			ns := "team-a"
+			wantReq := &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+				"critical": {
+					ApiVersion: "test.crossplane.io/v1",
+					Kind:       "TestResource",
+					Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: map[string]string{"tier": "critical"}}},
+					Namespace:  &ns,
+				},
+			}}
+			if diff := cmp.Diff(wantReq, rsp.GetRequirements(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want requirements, +got requirements:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(rsp.GetDesired().GetResources())), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionContinueOnError(t *testing.T) {
+	type args struct {
+		fields string
+	}
+	type want struct {
+		severities []fnv1.Severity
+		conditions []*fnv1.Condition
+		desired    []string
+	}
+
+	exceeded := "refusing to protect 3 resources: exceeds maxProtectedPerRun limit of 1 by 2"
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Fatal": {
+			reason: "A failure to protect resources should be fatal by default",
+			args:   args{fields: `, "maxProtectedPerRun": 1`},
+			want: want{
+				severities: []fnv1.Severity{fnv1.Severity_SEVERITY_FATAL},
+				conditions: []*fnv1.Condition{
+					{Type: ConditionTypeConfigValid, Status: fnv1.Status_STATUS_CONDITION_TRUE, Reason: ConditionReasonInputAccepted, Target: fnv1.Target_TARGET_COMPOSITE.Enum()},
+				},
+				// Crossplane does not apply the desired state of a fatal run.
+				desired: []string{"bucket", "db"},
+			},
+		},
+		"ContinueOnError": {
+			reason: "A failure to protect resources should set a false condition and keep the previously generated Usages",
+			args:   args{fields: `, "maxProtectedPerRun": 1, "continueOnError": true`},
+			want: want{
+				severities: []fnv1.Severity{fnv1.Severity_SEVERITY_WARNING},
+				conditions: []*fnv1.Condition{
+					{Type: ConditionTypeConfigValid, Status: fnv1.Status_STATUS_CONDITION_TRUE, Reason: ConditionReasonInputAccepted, Target: fnv1.Target_TARGET_COMPOSITE.Enum()},
+					{Type: ConditionTypeDeletionProtection, Status: fnv1.Status_STATUS_CONDITION_FALSE, Reason: ConditionReasonProtectionFailed, Message: ptr.To(exceeded), Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum()},
+				},
+				desired: []string{"bucket", "bucket-usage", "db"},
+			},
+		},
+		"ContinueOnErrorSucceeded": {
+			reason: "Protection that succeeds should set a true condition",
+			args:   args{fields: `, "continueOnError": true`},
+			want: want{
+				conditions: []*fnv1.Condition{
+					{Type: ConditionTypeConfigValid, Status: fnv1.Status_STATUS_CONDITION_TRUE, Reason: ConditionReasonInputAccepted, Target: fnv1.Target_TARGET_COMPOSITE.Enum()},
+					{Type: ConditionTypeDeletionProtection, Status: fnv1.Status_STATUS_CONDITION_TRUE, Reason: ConditionReasonProtectionApplied, Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum()},
+				},
+				desired: []string{"bucket", "bucket-usage", "db", "db-usage", "xr-my-xr-usage"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input"` + tc.args.fields + `}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "my-bucket", "labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+						"db": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "my-db", "labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+						"bucket-usage": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "protection.crossplane.io/v1beta1",
+							"kind": "ClusterUsage",
+							"metadata": {
+								"name": "testcomposed-my-bucket-fn-protection",
+								"uid": "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+								"resourceVersion": "42",
+								"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
+							},
+							"spec": {
+								"of": {"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed", "resourceRef": {"name": "my-bucket"}},
+								"reason": "created by function-deletion-protection via label protection.fn.crossplane.io/block-deletion"
+							},
+							"status": {"conditions": [{"type": "Ready", "status": "True"}]}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"}`)},
+						"db":     {Resource: resource.MustStructJSON(`{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			var severities []fnv1.Severity
+			for _, r := range rsp.GetResults() {
+				severities = append(severities, r.GetSeverity())
+			}
+			if diff := cmp.Diff(tc.want.severities, severities); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want severities, +got severities:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.conditions, rsp.GetConditions(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
+			}
+			var desired []string
+			if rsp.GetDesired() != nil {
+				desired = slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))
+			}
+			if diff := cmp.Diff(tc.want.desired, desired); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionWaitForXRReady(t *testing.T) {
+	type args struct {
+		xrStatus string
+		usage    bool
+	}
+	type want struct {
+		conditions []*fnv1.Condition
+		desired    []string
+	}
+
+	accepted := &fnv1.Condition{Type: ConditionTypeConfigValid, Status: fnv1.Status_STATUS_CONDITION_TRUE, Reason: ConditionReasonInputAccepted, Target: fnv1.Target_TARGET_COMPOSITE.Enum()}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NotReady": {
+			reason: "Nothing should be protected while the composite is not ready",
+			args:   args{xrStatus: "False"},
+			want: want{
+				conditions: []*fnv1.Condition{
+					accepted,
+					{Type: ConditionTypeDeletionProtection, Status: fnv1.Status_STATUS_CONDITION_FALSE, Reason: ConditionReasonWaitingForReady, Message: ptr.To("protection is applied once the composite is ready"), Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum()},
+				},
+				desired: []string{"bucket"},
+			},
+		},
+		"Ready": {
+			reason: "Resources should be protected once the composite is ready",
+			args:   args{xrStatus: "True"},
+			want: want{
+				conditions: []*fnv1.Condition{accepted},
+				desired:    []string{"bucket", "bucket-usage", "xr-my-xr-usage"},
+			},
+		},
+		"NoLongerReady": {
+			reason: "Protection that was already applied should be kept when the composite stops being ready",
+			args:   args{xrStatus: "False", usage: true},
+			want: want{
+				conditions: []*fnv1.Condition{accepted},
+				desired:    []string{"bucket", "bucket-usage", "xr-my-xr-usage"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			observed := map[string]*fnv1.Resource{
+				"bucket": {Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestComposed",
+					"metadata": {"name": "my-bucket", "labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+				}`)},
+			}
+			if tc.args.usage {
+				observed["bucket-usage"] = &fnv1.Resource{Resource: resource.MustStructJSON(`{
+					"apiVersion": "protection.crossplane.io/v1beta1",
+					"kind": "ClusterUsage",
+					"metadata": {
+						"name": "testcomposed-my-bucket-fn-protection",
+						"labels": {"app.kubernetes.io/managed-by": "function-deletion-protection"}
+					},
+					"spec": {
+						"of": {"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed", "resourceRef": {"name": "my-bucket"}},
+						"reason": "created by function-deletion-protection via label protection.fn.crossplane.io/block-deletion"
+					}
+				}`)}
+			}
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"waitForXRReady": true
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"},
+						"status": {"conditions": [{"type": "Ready", "status": "` + tc.args.xrStatus + `", "reason": "Creating", "lastTransitionTime": "2026-10-15T12:00:00Z"}]}
+					}`)},
+					Resources: observed,
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.conditions, rsp.GetConditions(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
+			}
+			desired := slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))
+			if diff := cmp.Diff(tc.want.desired, desired); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionOnMissingXR(t *testing.T) {
+	type args struct {
+		input string
+	}
+	type want struct {
+		results []*fnv1.Result
+	}
+
+	skipped := &fnv1.Result{
+		Severity: fnv1.Severity_SEVERITY_NORMAL,
+		Message:  "protection skipped because the observed composite does not exist yet",
+		Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Default": {
+			reason: "A request without an observed composite should be skipped by default",
+			args:   args{input: `{"apiVersion": "protection.fn.crossplane.io/v1beta1", "kind": "Input"}`},
+			want:   want{results: []*fnv1.Result{skipped}},
+		},
+		"Skip": {
+			reason: "A request without an observed composite should be skipped if onMissingXR is skip",
+			args:   args{input: `{"apiVersion": "protection.fn.crossplane.io/v1beta1", "kind": "Input", "onMissingXR": "skip"}`},
+			want:   want{results: []*fnv1.Result{skipped}},
+		},
+		"Fail": {
+			reason: "A request without an observed composite should fail if onMissingXR is fail",
+			args:   args{input: `{"apiVersion": "protection.fn.crossplane.io/v1beta1", "kind": "Input", "onMissingXR": "fail"}`},
+			want: want{results: []*fnv1.Result{{
+				Severity: fnv1.Severity_SEVERITY_FATAL,
+				Message:  "observed composite resource is missing",
+				Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(tc.args.input),
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"labels": {"protection.fn.crossplane.io/block-deletion": "true"}}
+						}`)},
+					},
+				},
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.results, rsp.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+			desired := slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))
+			if diff := cmp.Diff([]string{"bucket"}, desired); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetainManaged(t *testing.T) {
+	cd := func(obj map[string]any) *composed.Unstructured {
+		return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}
+	}
+	desired := map[resource.Name]*resource.DesiredComposed{
+		"bucket": {Resource: cd(map[string]any{"apiVersion": "test.crossplane.io/v1", "kind": "TestComposed"})},
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"bucket": {Resource: cd(map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-bucket"},
+		})},
+		"bucket-usage": {Resource: cd(map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata": map[string]any{
+				"name":              "testcomposed-my-bucket-fn-protection",
+				"uid":               "0c9b0cb4-7f6e-4d2a-9a3c-6c1f2e3d4b5a",
+				"resourceVersion":   "42",
+				"generation":        int64(1),
+				"creationTimestamp": "2026-10-15T12:00:00Z",
+				"labels":            map[string]any{LabelManagedBy: ManagedByValue},
+			},
+			"spec":   map[string]any{"reason": ProtectionReasonLabel},
+			"status": map[string]any{"conditions": []any{}},
+		})},
+		"user-usage": {Resource: cd(map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata":   map[string]any{"name": "user-usage"},
+		})},
+	}
+
+	want := map[resource.Name]*resource.DesiredComposed{
+		"bucket": desired["bucket"],
+		"bucket-usage": {Resource: cd(map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata": map[string]any{
+				"name":   "testcomposed-my-bucket-fn-protection",
+				"labels": map[string]any{LabelManagedBy: ManagedByValue},
+			},
+			"spec": map[string]any{"reason": ProtectionReasonLabel},
+		})},
+	}
+
+	got := RetainManaged(desired, observed)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RetainManaged(...): -want, +got:\n%s", diff)
+	}
+}
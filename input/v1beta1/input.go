@@ -35,4 +35,969 @@ type Input struct {
 	// +optional
 	// +kubebuilder:default:=false
 	EnableV1Mode bool `json:"enableV1Mode,omitempty"`
+
+	// UsageGroupVersion overrides the apiVersion of generated Usages, e.g.
+	// protection.example.org/v1beta1 for a forked or renamed protection API.
+	// The kind is still Usage or ClusterUsage.
+	// +optional
+	UsageGroupVersion string `json:"usageGroupVersion,omitempty"`
+
+	// StrictTrueOnly requires the protection label value to be exactly "true".
+	// By default the value is compared case-insensitively, so "True" and
+	// "TRUE" also enable protection. With StrictTrueOnly any other value
+	// explicitly disables protection, like "false".
+	// +optional
+	// +kubebuilder:default:=false
+	StrictTrueOnly bool `json:"strictTrueOnly,omitempty"`
+
+	// PresenceOnlyLabels is a list of label keys whose presence on a resource
+	// enables protection, regardless of the label value.
+	// +optional
+	PresenceOnlyLabels []string `json:"presenceOnlyLabels,omitempty"`
+
+	// FailClosed protects resources whose labels cannot be read. By default
+	// the function fails open and does not protect these resources.
+	// +optional
+	// +kubebuilder:default:=false
+	FailClosed bool `json:"failClosed,omitempty"`
+
+	// FailClosedKinds limits FailClosed to resources of the listed kinds. If
+	// empty, FailClosed applies to all resources.
+	// +optional
+	FailClosedKinds []GroupKind `json:"failClosedKinds,omitempty"`
+
+	// ProtectByOwnerKinds protects composed resources that have an owner
+	// reference to any of the listed kinds.
+	// +optional
+	ProtectByOwnerKinds []GroupKind `json:"protectByOwnerKinds,omitempty"`
+
+	// ProtectProviders protects composed resources of the listed provider API
+	// group domains, e.g. aws.upbound.io. A domain matches its own API group
+	// and every API group that ends with it, e.g. s3.aws.upbound.io.
+	// +optional
+	ProtectProviders []string `json:"protectProviders,omitempty"`
+
+	// AnnotateProtected adds a protection.fn.crossplane.io/protected-by
+	// annotation with the name of the Usage to each protected desired composed
+	// resource.
+	// +optional
+	// +kubebuilder:default:=false
+	AnnotateProtected bool `json:"annotateProtected,omitempty"`
+
+	// PauseOnProtect sets the crossplane.io/paused annotation on each
+	// protected desired composed resource, so Crossplane stops reconciling it
+	// and its fields cannot drift while it is protected. Only resources
+	// protected by a generated Usage are paused. Resources are resumed once
+	// they are no longer protected, including when protection is released
+	// from a deleting composite.
+	// +optional
+	// +kubebuilder:default:=false
+	PauseOnProtect bool `json:"pauseOnProtect,omitempty"`
+
+	// AnnotateUsageOrder annotates each generated Usage with
+	// protection.fn.crossplane.io/order, its position in the order in which the
+	// function generates Usages: the Usages of all other resources ordered by
+	// name, followed by the Usage of the composite. Crossplane does not apply
+	// desired resources in any particular order, so this is only a hint for
+	// tools that process the desired resources.
+	// +optional
+	// +kubebuilder:default:=false
+	AnnotateUsageOrder bool `json:"annotateUsageOrder,omitempty"`
+
+	// ClearLabelAfterProtect removes the block-deletion label from a desired
+	// composed resource once its Usage exists. The existing Usage then keeps
+	// the resource protected until the label is explicitly set to "false".
+	// +optional
+	// +kubebuilder:default:=false
+	ClearLabelAfterProtect bool `json:"clearLabelAfterProtect,omitempty"`
+
+	// ProtectWhen protects composed resources that match all of the listed
+	// expressions.
+	// +optional
+	ProtectWhen []MatchExpression `json:"protectWhen,omitempty"`
+
+	// OnRelease documents the intended behavior when a generated Usage is
+	// released using the protection.fn.crossplane.io/on-release annotation.
+	// "replay" also sets spec.replayDeletion so that a blocked deletion is
+	// replayed once the Usage is removed. "block" leaves replayDeletion unset.
+	// +optional
+	// +kubebuilder:validation:Enum=replay;block
+	OnRelease OnRelease `json:"onRelease,omitempty"`
+
+	// ProtectRevisionLabel only protects composed resources whose
+	// crossplane.io/composition-revision label matches ProtectRevision.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectRevisionLabel bool `json:"protectRevisionLabel,omitempty"`
+
+	// ProtectRevision is the composition revision to protect when
+	// ProtectRevisionLabel is enabled. Defaults to the composite's current
+	// composition revision.
+	// +optional
+	ProtectRevision string `json:"protectRevision,omitempty"`
+
+	// EnvironmentEnabledPath is a field path in the EnvironmentConfig data
+	// passed in the pipeline context, e.g. protectionEnabled. If the value is
+	// false no resources are protected. A missing environment or value leaves
+	// protection enabled.
+	// +optional
+	EnvironmentEnabledPath string `json:"environmentEnabledPath,omitempty"`
+
+	// ProtectEnvironments are the environments in which resources are
+	// protected, e.g. production. The environment is read from
+	// ProtectEnvironmentPath of the EnvironmentConfig data passed in the
+	// pipeline context. If the environment is not one of these no resources
+	// are protected. A missing environment or value leaves protection enabled.
+	// +optional
+	ProtectEnvironments []string `json:"protectEnvironments,omitempty"`
+
+	// ProtectEnvironmentPath is the field path of the environment name in the
+	// EnvironmentConfig data. Defaults to env.
+	// +optional
+	// +kubebuilder:default:=env
+	ProtectEnvironmentPath string `json:"protectEnvironmentPath,omitempty"`
+
+	// DefaultProtect protects the composite and all composed resources unless
+	// they opt out by setting the protection.fn.crossplane.io/block-deletion
+	// label to "false".
+	// +optional
+	// +kubebuilder:default:=false
+	DefaultProtect bool `json:"defaultProtect,omitempty"`
+
+	// OptOutAnnotation is an annotation that lets a resource opt out of
+	// DefaultProtect. A resource whose annotation is set to a true value, such
+	// as "true" or "1", is not protected by default. It does not affect
+	// resources that are protected for any other reason.
+	// +optional
+	OptOutAnnotation string `json:"optOutAnnotation,omitempty"`
+
+	// SharedProtectionGroupLabel is a label that places resources in a shared
+	// protection group. Any composite, composed or required resource that sets
+	// the label to a non-empty value is protected, regardless of which
+	// composite owns it.
+	// +optional
+	SharedProtectionGroupLabel string `json:"sharedProtectionGroupLabel,omitempty"`
+
+	// RedactReasonPatterns is a list of regular expressions. Any part of a
+	// generated Usage's reason that matches a pattern is replaced with
+	// [REDACTED].
+	// +optional
+	RedactReasonPatterns []string `json:"redactReasonPatterns,omitempty"`
+
+	// MaxProtectedPerRun is the maximum number of Usages the function may
+	// generate in a single run. If more resources would be protected the
+	// function returns a fatal result and no changes are applied. Zero means
+	// no limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxProtectedPerRun int `json:"maxProtectedPerRun,omitempty"`
+
+	// ProtectIfStatusPath is a field path in the status of observed composed
+	// resources, e.g. status.atProvider.allocatedStorage. Resources where the
+	// field matches ProtectIfStatusEquals are protected.
+	// +optional
+	ProtectIfStatusPath string `json:"protectIfStatusPath,omitempty"`
+
+	// ProtectIfStatusEquals is the value ProtectIfStatusPath must have for the
+	// resource to be protected. If empty, the field only needs to be present.
+	// +optional
+	ProtectIfStatusEquals string `json:"protectIfStatusEquals,omitempty"`
+
+	// ProtectIfConditionTrue is a status condition type, e.g. Critical.
+	// Observed composed resources that report this condition with status True
+	// are protected.
+	// +optional
+	ProtectIfConditionTrue string `json:"protectIfConditionTrue,omitempty"`
+
+	// TrackingNamespace is a namespace in which a tracking ConfigMap is created
+	// for every cluster-scoped resource protected by a ClusterUsage, giving a
+	// namespaced inventory of protected resources. Disabled if empty.
+	// +optional
+	TrackingNamespace string `json:"trackingNamespace,omitempty"`
+
+	// XRProtectionTriggerKinds limits composite protection to composites with
+	// a protected composed resource of one of the listed kinds. If empty, any
+	// protected composed resource protects the composite.
+	// +optional
+	XRProtectionTriggerKinds []GroupKind `json:"xrProtectionTriggerKinds,omitempty"`
+
+	// XRUseResourceSelector references the composite in its Usage by a
+	// resourceSelector matching the composite's XRSelectorLabels instead of
+	// by name, e.g. when composite names are generated. A composite that lacks
+	// one of the labels is referenced by name.
+	// +optional
+	// +kubebuilder:default:=false
+	XRUseResourceSelector bool `json:"xrUseResourceSelector,omitempty"`
+
+	// XRSelectorLabels lists the labels of the composite the resourceSelector
+	// of its Usage matches if XRUseResourceSelector is set.
+	// +optional
+	XRSelectorLabels []string `json:"xrSelectorLabels,omitempty"`
+
+	// ReasonPrefix is prepended to the reason of every generated Usage,
+	// separated by a single space, e.g. [PROD-PROTECTION].
+	// +optional
+	ReasonPrefix string `json:"reasonPrefix,omitempty"`
+
+	// ReasonSuffix is appended to the reason of every generated Usage,
+	// separated by a single space.
+	// +optional
+	ReasonSuffix string `json:"reasonSuffix,omitempty"`
+
+	// ProtectExternalNameRegex protects composed resources whose
+	// crossplane.io/external-name annotation matches the regular expression.
+	// Resources without an external name are not protected.
+	// +optional
+	ProtectExternalNameRegex string `json:"protectExternalNameRegex,omitempty"`
+
+	// ProtectNewestN protects the N most recently created observed composed
+	// resources of ProtectNewestKind. Zero disables this behavior.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ProtectNewestN int `json:"protectNewestN,omitempty"`
+
+	// ProtectNewestKind is the kind of resource ProtectNewestN applies to.
+	// +optional
+	ProtectNewestKind *GroupKind `json:"protectNewestKind,omitempty"`
+
+	// SampleProtectPercent protects this percentage of composed resources,
+	// e.g. to test partial protection. Resources are selected by a hash of
+	// their kind, namespace and name, so the same resources are selected on
+	// every run. Zero disables this behavior.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SampleProtectPercent int `json:"sampleProtectPercent,omitempty"`
+
+	// ProtectionMode selects how resources are protected. "usage" creates
+	// Usage objects. "annotation" only sets the
+	// protection.fn.crossplane.io/protected annotation on protected composed
+	// and composite resources and defers enforcement to an admission webhook.
+	// Required resources of an Operation are always protected by Usages.
+	// +optional
+	// +kubebuilder:validation:Enum=usage;annotation
+	// +kubebuilder:default:=usage
+	ProtectionMode ProtectionMode `json:"protectionMode,omitempty"`
+
+	// NamingScheme selects how generated Usages are named. "suffix" names a
+	// Usage after the kind and name of the resource it protects, followed by a
+	// hash of both. "hash" uses the same base name, followed by a hash of the
+	// resource's apiVersion, kind, namespace and name, so that Usages of
+	// resources that only differ in API group or namespace never share a name.
+	// Names are at most 63 characters with either scheme.
+	// +optional
+	// +kubebuilder:validation:Enum=suffix;hash
+	// +kubebuilder:default:=suffix
+	NamingScheme NamingScheme `json:"namingScheme,omitempty"`
+
+	// ProtectByControllerRef protects composed resources whose controller
+	// owner reference is the composite resource.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectByControllerRef bool `json:"protectByControllerRef,omitempty"`
+
+	// ExportDecisions writes the protection decisions of each run to the
+	// protection.fn.crossplane.io/decisions context key, so that later
+	// pipeline steps can consume them.
+	// +optional
+	// +kubebuilder:default:=false
+	ExportDecisions bool `json:"exportDecisions,omitempty"`
+
+	// NotifyOnProtect writes a notification describing the protections added
+	// by a run to the protection.fn.crossplane.io/notification context key, so
+	// that a downstream function can send notifications. Nothing is written
+	// if no protection was added.
+	// +optional
+	// +kubebuilder:default:=false
+	NotifyOnProtect bool `json:"notifyOnProtect,omitempty"`
+
+	// PolicyConfigMapRef references a ConfigMap whose policy key contains
+	// additional Input fields in YAML. The ConfigMap is requested as a required
+	// resource. Fields set inline take precedence over the policy.
+	// +optional
+	PolicyConfigMapRef *ConfigMapReference `json:"policyConfigMapRef,omitempty"`
+
+	// SkipObserveOnly skips protecting composed resources whose management
+	// policies only allow Crossplane to observe them, because Crossplane never
+	// deletes these resources. Defaults to true.
+	// +optional
+	// +kubebuilder:default:=true
+	SkipObserveOnly *bool `json:"skipObserveOnly,omitempty"`
+
+	// OnlyOwnedResources skips protecting observed composed resources whose
+	// controller owner reference refers to another resource than the
+	// composite, e.g. resources of another composition. Resources without a
+	// controller reference are not skipped. Defaults to true.
+	// +optional
+	// +kubebuilder:default:=true
+	OnlyOwnedResources *bool `json:"onlyOwnedResources,omitempty"`
+
+	// MinReadyDurationSeconds requires composed resources to have been Ready
+	// for at least this many seconds before they are protected. Resources that
+	// have not been Ready long enough are skipped. Zero disables the check.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinReadyDurationSeconds int `json:"minReadyDurationSeconds,omitempty"`
+
+	// ProtectIfPriorityAtLeast protects composed resources whose
+	// PriorityAnnotation is an integer at or above this threshold, e.g. 90.
+	// Resources without the annotation, or with a value that is not an
+	// integer, are not protected by their priority.
+	// +optional
+	ProtectIfPriorityAtLeast *int `json:"protectIfPriorityAtLeast,omitempty"`
+
+	// PriorityAnnotation is the annotation holding the priority of a resource
+	// for ProtectIfPriorityAtLeast.
+	// +optional
+	// +kubebuilder:default:="protection.fn.crossplane.io/priority"
+	PriorityAnnotation string `json:"priorityAnnotation,omitempty"`
+
+	// IncludeLabelInReason includes the protection label key in the reason of
+	// Usages created because of the label. Defaults to true.
+	// +optional
+	// +kubebuilder:default:=true
+	IncludeLabelInReason *bool `json:"includeLabelInReason,omitempty"`
+
+	// ProtectIfConnectionSecret protects composed resources that write a
+	// connection secret using spec.writeConnectionSecretToRef or
+	// spec.publishConnectionDetailsTo.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectIfConnectionSecret bool `json:"protectIfConnectionSecret,omitempty"`
+
+	// ProtectConnectionSources protects composed resources labeled with
+	// protection.fn.crossplane.io/connection-source: "true" whenever the
+	// composite is protected, since the composite publishes their connection
+	// details.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectConnectionSources bool `json:"protectConnectionSources,omitempty"`
+
+	// ReleasePolicy controls protection while the composite is being deleted.
+	// "always-protect" keeps all Usages. "release-on-xr-delete" removes all
+	// Usages generated by the function once the composite has a deletion
+	// timestamp, allowing it to be torn down.
+	// +optional
+	// +kubebuilder:validation:Enum=always-protect;release-on-xr-delete
+	// +kubebuilder:default:=always-protect
+	ReleasePolicy ReleasePolicy `json:"releasePolicy,omitempty"`
+
+	// PreserveExistingReason keeps the reason of an observed Usage when it was
+	// set by something other than this function, for example another function
+	// in the pipeline.
+	// +optional
+	// +kubebuilder:default:=false
+	PreserveExistingReason bool `json:"preserveExistingReason,omitempty"`
+
+	// OnlyProtectDeletePolicy only protects composed resources whose
+	// spec.deletionPolicy is Delete or unset. Resources with an Orphan
+	// deletion policy keep their external resource when deleted, so
+	// protecting them is redundant.
+	// +optional
+	// +kubebuilder:default:=false
+	OnlyProtectDeletePolicy bool `json:"onlyProtectDeletePolicy,omitempty"`
+
+	// WarnUnprotectedKinds lists critical kinds of composed resources. A
+	// warning is returned for each resource of these kinds that does not have
+	// the block-deletion label. The resources are not protected.
+	// +optional
+	WarnUnprotectedKinds []GroupKind `json:"warnUnprotectedKinds,omitempty"`
+
+	// ConditionTarget controls where the function's protection conditions are
+	// set. "both" sets them on the composite and its claim, "composite" only
+	// on the composite, which avoids noise for composites without a claim.
+	// Function conditions cannot target only the claim.
+	// +optional
+	// +kubebuilder:validation:Enum=both;composite
+	// +kubebuilder:default:=both
+	ConditionTarget ConditionTarget `json:"conditionTarget,omitempty"`
+
+	// ContinueOnError reports a failure to protect resources as a warning and
+	// a DeletionProtection condition with status False, instead of a fatal
+	// result, so that the rest of the pipeline is still applied. The Usages
+	// generated by previous runs are kept until protection succeeds again, at
+	// which point the condition is set to True. Invalid Input is always fatal.
+	// +optional
+	// +kubebuilder:default:=false
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+
+	// MatchLabelEquals protects composed and composite resources with a label
+	// that equals one of the configured values, e.g. tier: critical.
+	// +optional
+	MatchLabelEquals []LabelMatch `json:"matchLabelEquals,omitempty"`
+
+	// MatchAnnotationRegex protects composed resources with an annotation
+	// whose value matches one of the configured regular expressions, e.g.
+	// environment: ^prod.
+	// +optional
+	MatchAnnotationRegex []AnnotationRegex `json:"matchAnnotationRegex,omitempty"`
+
+	// RequireAllSelectors protects composed and composite resources whose
+	// labels match every one of the selectors, e.g. tier: critical and
+	// env: prod. Each selector must set matchLabels or matchExpressions.
+	// +optional
+	RequireAllSelectors []metav1.LabelSelector `json:"requireAllSelectors,omitempty"`
+
+	// RefPaths lists field paths on the composite whose values reference
+	// composed resources to protect, e.g. spec.parameters.vpcRef. A value may
+	// either be a resource name or an object with a name and an optional
+	// namespace.
+	// +optional
+	RefPaths []string `json:"refPaths,omitempty"`
+
+	// CrossCompositionBy protects resources of other compositions that
+	// protected composed resources depend on. For every protected composed
+	// resource that references a resource listed in CrossCompositionRefs, a
+	// Usage of the referenced resource is generated with spec.by pointing to
+	// the protected composed resource.
+	// +optional
+	// +kubebuilder:default:=false
+	CrossCompositionBy bool `json:"crossCompositionBy,omitempty"`
+
+	// CrossCompositionRefs identify the resources of other compositions that
+	// composed resources depend on. Only used if CrossCompositionBy is set.
+	// +optional
+	CrossCompositionRefs []CrossCompositionRef `json:"crossCompositionRefs,omitempty"`
+
+	// OnlyForCompositions limits protection to composites that use one of the
+	// listed compositions. Protection is not limited if the list is empty.
+	// +optional
+	OnlyForCompositions []string `json:"onlyForCompositions,omitempty"`
+
+	// AuditTrail adds a result to every run summarizing the protected
+	// resources, why they are protected and what triggered their protection.
+	// +optional
+	// +kubebuilder:default:=false
+	AuditTrail bool `json:"auditTrail,omitempty"`
+
+	// EmitKubeEvent adds a core/v1 Event to the desired resources that
+	// summarizes the protection applied by each run. Unlike results, the Event
+	// is stored in the cluster and shown by kubectl get events. Events of
+	// cluster scoped composites are created in the default namespace.
+	// +optional
+	// +kubebuilder:default:=false
+	EmitKubeEvent bool `json:"emitKubeEvent,omitempty"`
+
+	// ProtectReferencedSecrets protects the Secrets and ConfigMaps referenced
+	// by the composite at the SecretRefPaths.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectReferencedSecrets bool `json:"protectReferencedSecrets,omitempty"`
+
+	// SecretRefPaths lists field paths on the composite whose values
+	// reference Secrets or ConfigMaps to protect when ProtectReferencedSecrets
+	// is enabled. A value may either be a name or an object with a name and an
+	// optional namespace. The composite's namespace is used if no namespace is
+	// referenced.
+	// +optional
+	SecretRefPaths []ObjectRefPath `json:"secretRefPaths,omitempty"`
+
+	// InheritXRLabels lists labels of the composite that are copied to the
+	// generated Usages, so Usages can be queried by composite attributes.
+	// +optional
+	InheritXRLabels []string `json:"inheritXRLabels,omitempty"`
+
+	// ProtectNamespaceAsGroup protects every namespaced resource of the
+	// composite, and the composite itself if it is namespaced. The Usage API
+	// cannot select all resources of a namespace, so a Usage is generated per
+	// resource and labeled with its namespace, allowing the Usages of a
+	// namespace to be listed and removed as a group.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectNamespaceAsGroup bool `json:"protectNamespaceAsGroup,omitempty"`
+
+	// ProtectionSpecPath is the field path of an object in the composite that
+	// declares protection, e.g. spec.protection. The object may set enabled to
+	// protect the composite, reason to replace the reason of the generated
+	// Usages, and kinds to limit protection of composed resources to these
+	// kinds. All composed resources are protected if kinds is empty.
+	// +optional
+	ProtectionSpecPath string `json:"protectionSpecPath,omitempty"`
+
+	// LintOnly only checks that composed resources of the
+	// WarnUnprotectedKinds are labeled for protection and reports violations
+	// as results. The desired state is never changed and no conditions are
+	// set, which is useful in CI.
+	// +optional
+	// +kubebuilder:default:=false
+	LintOnly bool `json:"lintOnly,omitempty"`
+
+	// TriggerReasons replaces the reason of generated Usages with one naming
+	// the trigger that caused protection, e.g.
+	// "Protected due to: policy(protectWhen)".
+	// +optional
+	// +kubebuilder:default:=false
+	TriggerReasons bool `json:"triggerReasons,omitempty"`
+
+	// ProtectDesiredOnly protects composed resources before they are observed,
+	// using the desired resource. A Usage references its resource by name, so
+	// only desired resources with a metadata.name can be protected before they
+	// are observed. Others are protected once they are observed.
+	// +optional
+	// +kubebuilder:default:=false
+	ProtectDesiredOnly bool `json:"protectDesiredOnly,omitempty"`
+
+	// LabelSource controls which state of a resource the protection label is
+	// read from. "either" protects if the desired or the observed resource is
+	// labeled, "both" only if both are, and "desired" or "observed" only
+	// consider that state.
+	// +optional
+	// +kubebuilder:validation:Enum=either;desired;observed;both
+	// +kubebuilder:default:=either
+	LabelSource LabelSource `json:"labelSource,omitempty"`
+
+	// SnapshotOnDeletion records the protected resources in a snapshot
+	// annotation on every generated Usage once the composite is being deleted.
+	// With the release-on-xr-delete ReleasePolicy, protection is only released
+	// once the snapshot has been recorded, leaving an audit record of what was
+	// protected at teardown.
+	// +optional
+	// +kubebuilder:default:=false
+	SnapshotOnDeletion bool `json:"snapshotOnDeletion,omitempty"`
+
+	// ReasonPrecedence lists the sources of a generated Usage's reason in
+	// priority order. The first source that is present wins. "annotation" is
+	// the protection.fn.crossplane.io/reason annotation of the protected
+	// resource, "trigger" the reason naming the trigger when TriggerReasons is
+	// enabled, and "default" the reason generated by the function. Defaults to
+	// annotation, trigger, default.
+	// +optional
+	ReasonPrecedence []ReasonSource `json:"reasonPrecedence,omitempty"`
+
+	// RequiredSelectors requests resources matching a label selector as
+	// required resources and protects every resource that is returned. Unlike
+	// other required resources, selected resources don't need to be labeled.
+	// +optional
+	RequiredSelectors []RequiredSelector `json:"requiredSelectors,omitempty"`
+
+	// SuccessConditionType is the type of the condition the function sets
+	// when its Input is accepted. Customize it if the default collides with a
+	// condition of another function in the pipeline. Must be a valid
+	// Kubernetes condition type.
+	// +optional
+	// +kubebuilder:default:=ConfigValid
+	SuccessConditionType string `json:"successConditionType,omitempty"`
+
+	// SuccessConditionReason is the reason of the condition the function sets
+	// when its Input is accepted. Must be a valid Kubernetes condition reason.
+	// +optional
+	// +kubebuilder:default:=InputAccepted
+	SuccessConditionReason string `json:"successConditionReason,omitempty"`
+
+	// ProtectionTTL limits how long a resource is protected, e.g. 72h for
+	// temporary protection during a migration. Protection expires
+	// ProtectionTTL after it started, i.e. after the Usage protecting the
+	// resource was created. Generated Usages and the composite or composed
+	// resources they protect record the expiry in the
+	// protection.fn.crossplane.io/expires-at annotation. Usages are dropped
+	// once it has passed. Resources outside of the composition cannot record
+	// the expiry, so their protection expires ProtectionTTL after they were
+	// created.
+	// +optional
+	ProtectionTTL string `json:"protectionTTL,omitempty"`
+
+	// CreationTimeAnnotation is an annotation holding the RFC 3339 creation
+	// time of a resource. It is used by ProtectNewestN and ProtectionTTL if the
+	// resource has no metadata.creationTimestamp, e.g. when rendering outside
+	// of a cluster.
+	// +optional
+	CreationTimeAnnotation string `json:"creationTimeAnnotation,omitempty"`
+
+	// IncludeTimestampInReason appends the time protection started to the
+	// reason of generated Usages, e.g. "... at 2026-10-15T12:00:00Z". The time
+	// is recorded in the protection.fn.crossplane.io/protected-at annotation
+	// and reused on later runs, so the Usage does not change on every run.
+	// +optional
+	// +kubebuilder:default:=false
+	IncludeTimestampInReason bool `json:"includeTimestampInReason,omitempty"`
+
+	// WaitForXRReady skips protection until the observed composite reports a
+	// Ready condition with status True, so resources are not protected while
+	// the composite is still being provisioned. Once protection has been
+	// applied it is kept, even if the composite stops being ready.
+	// +optional
+	// +kubebuilder:default:=false
+	WaitForXRReady bool `json:"waitForXRReady,omitempty"`
+
+	// OnMissingXR is what happens if the request has no observed composite,
+	// e.g. while a claim is being created. skip returns without protecting
+	// anything, fail returns a fatal result. Requests of Operations, which
+	// supply the resources to protect as required resources, are not
+	// affected.
+	// +optional
+	// +kubebuilder:validation:Enum=skip;fail
+	// +kubebuilder:default:=skip
+	OnMissingXR MissingXRPolicy `json:"onMissingXR,omitempty"`
+
+	// DecisionWebhookURL is an http or https URL the function POSTs each
+	// composed resource that is not otherwise protected to. The webhook
+	// responds with {"protect": true|false, "reason": "..."}.
+	// +optional
+	DecisionWebhookURL string `json:"decisionWebhookURL,omitempty"`
+
+	// DecisionWebhookTimeout is how long the function waits for all decision
+	// webhook calls of a run together, e.g. 2s.
+	// +optional
+	// +kubebuilder:default:="5s"
+	DecisionWebhookTimeout string `json:"decisionWebhookTimeout,omitempty"`
+
+	// DecisionWebhookFailurePolicy is what happens to a resource if the
+	// decision webhook cannot be reached or returns an invalid response.
+	// protect fails closed and protects the resource, skip fails open and
+	// reports the resource as not protected.
+	// +optional
+	// +kubebuilder:validation:Enum=protect;skip
+	// +kubebuilder:default:=protect
+	DecisionWebhookFailurePolicy WebhookFailurePolicy `json:"decisionWebhookFailurePolicy,omitempty"`
+
+	// RequireComposedForXRProtection only protects the composite once at least
+	// one of its composed resources exists. Usages and other resources
+	// generated by this function are not counted.
+	// +optional
+	// +kubebuilder:default:=false
+	RequireComposedForXRProtection bool `json:"requireComposedForXRProtection,omitempty"`
+
+	// SubresourceReasons documents protection of critical parts of composed
+	// resources, e.g. storage or backups. For every protected composed
+	// resource an additional Usage is generated per entry, with the entry's
+	// value as its reason. Keys must be valid DNS labels. Ignored if
+	// ProtectionMode is annotation.
+	// +optional
+	SubresourceReasons map[string]string `json:"subresourceReasons,omitempty"`
+
+	// RespectUserUsages skips generating a Usage for a resource that is
+	// already protected by a Usage in the desired composed resources that was
+	// not generated by this function. Defaults to true.
+	// +optional
+	// +kubebuilder:default:=true
+	RespectUserUsages *bool `json:"respectUserUsages,omitempty"`
+
+	// ProtectIfFieldMatches protects composed resources with a field that
+	// matches a regular expression, e.g. resources referencing a protected AMI
+	// id or image digest. A resource is protected if any entry matches.
+	// Resources without the field are not protected by an entry.
+	// +optional
+	ProtectIfFieldMatches []FieldMatch `json:"protectIfFieldMatches,omitempty"`
+
+	// ProtectionRules protect composed resources that match any of the rules.
+	// Rules of the ProtectionPolicies selected by ProtectionPolicySelector are
+	// added to these.
+	// +optional
+	ProtectionRules []ProtectionRule `json:"protectionRules,omitempty"`
+
+	// ExcludeKinds are never protected by ProtectionRules. Kinds excluded by
+	// the selected ProtectionPolicies are added to these.
+	// +optional
+	ExcludeKinds []GroupKind `json:"excludeKinds,omitempty"`
+
+	// ProtectionPolicySelector selects ProtectionPolicy objects, which are
+	// requested as required resources. The rules and exclusions of all
+	// selected policies are merged with the Input.
+	// +optional
+	ProtectionPolicySelector *ProtectionPolicySelector `json:"protectionPolicySelector,omitempty"`
+}
+
+// OnRelease is the intended behavior when a Usage is released.
+type OnRelease string
+
+// Supported OnRelease values.
+const (
+	// OnReleaseReplay replays a blocked deletion once the Usage is removed.
+	OnReleaseReplay OnRelease = "replay"
+	// OnReleaseBlock does not replay a blocked deletion.
+	OnReleaseBlock OnRelease = "block"
+)
+
+// ProtectionMode is how resources are protected.
+type ProtectionMode string
+
+// Supported ProtectionMode values.
+const (
+	// ProtectionModeUsage protects resources by creating Usages.
+	ProtectionModeUsage ProtectionMode = "usage"
+	// ProtectionModeAnnotation protects resources by annotating them.
+	ProtectionModeAnnotation ProtectionMode = "annotation"
+)
+
+// NamingScheme is how generated Usages are named.
+type NamingScheme string
+
+// Supported NamingScheme values.
+const (
+	// NamingSchemeSuffix names Usages after the kind and name of the protected
+	// resource.
+	NamingSchemeSuffix NamingScheme = "suffix"
+	// NamingSchemeHash names Usages after the kind and name of the protected
+	// resource, with a hash of its full reference.
+	NamingSchemeHash NamingScheme = "hash"
+)
+
+// WebhookFailurePolicy is what happens if the decision webhook fails.
+type WebhookFailurePolicy string
+
+// Supported WebhookFailurePolicy values.
+const (
+	// WebhookFailurePolicyProtect protects the resource if the webhook fails.
+	WebhookFailurePolicyProtect WebhookFailurePolicy = "protect"
+	// WebhookFailurePolicySkip does not protect the resource if the webhook
+	// fails.
+	WebhookFailurePolicySkip WebhookFailurePolicy = "skip"
+)
+
+// MissingXRPolicy is what happens if the request has no observed composite.
+type MissingXRPolicy string
+
+// Supported MissingXRPolicy values.
+const (
+	// MissingXRPolicySkip returns without protecting anything.
+	MissingXRPolicySkip MissingXRPolicy = "skip"
+	// MissingXRPolicyFail returns a fatal result.
+	MissingXRPolicyFail MissingXRPolicy = "fail"
+)
+
+// CrossCompositionIdentity is how a CrossCompositionRef identifies the
+// referenced resource.
+type CrossCompositionIdentity string
+
+// Supported CrossCompositionIdentity values.
+const (
+	// CrossCompositionIdentityName identifies the referenced resource by its
+	// name.
+	CrossCompositionIdentityName CrossCompositionIdentity = "name"
+	// CrossCompositionIdentityExternalName identifies the referenced resource
+	// by its crossplane.io/external-name annotation.
+	CrossCompositionIdentityExternalName CrossCompositionIdentity = "externalName"
+)
+
+// CrossCompositionRef identifies a resource of another composition that a
+// composed resource depends on.
+type CrossCompositionRef struct {
+	// FieldPath is the path of the reference on the composed resource, e.g.
+	// spec.forProvider.vpcIdRef.name or spec.forProvider.vpcId. Composed
+	// resources without the field are ignored.
+	FieldPath string `json:"fieldPath"`
+
+	// APIVersion of the referenced resource.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the referenced resource.
+	Kind string `json:"kind"`
+
+	// IdentifyBy is how the value at FieldPath identifies the referenced
+	// resource. name is its name. externalName is its external name, and
+	// requires MatchLabels to look the resource up.
+	// +optional
+	// +kubebuilder:validation:Enum=name;externalName
+	// +kubebuilder:default:=name
+	IdentifyBy CrossCompositionIdentity `json:"identifyBy,omitempty"`
+
+	// MatchLabels selects the resources that are looked up by external name.
+	// Only used if IdentifyBy is externalName.
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// ObjectRefPath is a field path on the composite that references a native
+// Kubernetes object.
+type ObjectRefPath struct {
+	// FieldPath is the path of the reference, e.g.
+	// spec.writeConnectionSecretToRef.
+	FieldPath string `json:"fieldPath"`
+
+	// Kind is the kind of the referenced object.
+	// +optional
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	// +kubebuilder:default:=Secret
+	Kind string `json:"kind,omitempty"`
+}
+
+// RequiredSelector selects required resources to protect.
+type RequiredSelector struct {
+	// Name of the requirement. Must be unique within the Input.
+	Name string `json:"name"`
+
+	// APIVersion of the resources to select.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the resources to select.
+	Kind string `json:"kind"`
+
+	// Namespace to select resources in. Cluster scoped resources are selected
+	// if omitted.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// MatchLabels the selected resources must have.
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+// ProtectionRule protects composed resources that match it.
+type ProtectionRule struct {
+	// Kinds the resource must be one of. Any kind matches if omitted.
+	// +optional
+	Kinds []GroupKind `json:"kinds,omitempty"`
+
+	// MatchLabels the resource must have. Any labels match if omitted. At
+	// least one of Kinds and MatchLabels must be set.
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// Reason of the Usages generated because of the rule.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// ProtectionPolicySelector selects ProtectionPolicy objects.
+type ProtectionPolicySelector struct {
+	// MatchLabels the selected policies must have. All policies are selected
+	// if omitted.
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// ConfigMapReference references a ConfigMap.
+type ConfigMapReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+}
+
+// ReleasePolicy controls protection while the composite is being deleted.
+type ReleasePolicy string
+
+// Supported ReleasePolicy values.
+const (
+	// ReleasePolicyAlwaysProtect keeps protection while the composite is
+	// being deleted.
+	ReleasePolicyAlwaysProtect ReleasePolicy = "always-protect"
+	// ReleasePolicyReleaseOnXRDelete removes protection once the composite is
+	// being deleted.
+	ReleasePolicyReleaseOnXRDelete ReleasePolicy = "release-on-xr-delete"
+)
+
+// ConditionTarget controls where the function's conditions are set.
+type ConditionTarget string
+
+// Supported ConditionTarget values.
+const (
+	// ConditionTargetBoth sets conditions on the composite and its claim.
+	ConditionTargetBoth ConditionTarget = "both"
+	// ConditionTargetComposite sets conditions only on the composite.
+	ConditionTargetComposite ConditionTarget = "composite"
+)
+
+// LabelSource controls which state of a resource the protection label is read
+// from.
+type LabelSource string
+
+// Supported LabelSource values.
+const (
+	// LabelSourceEither reads the label from the desired or observed state.
+	LabelSourceEither LabelSource = "either"
+	// LabelSourceDesired only reads the label from the desired state.
+	LabelSourceDesired LabelSource = "desired"
+	// LabelSourceObserved only reads the label from the observed state.
+	LabelSourceObserved LabelSource = "observed"
+	// LabelSourceBoth requires the label in both the desired and observed
+	// state.
+	LabelSourceBoth LabelSource = "both"
+)
+
+// ReasonSource is a source of a generated Usage's reason.
+// +kubebuilder:validation:Enum=annotation;trigger;default
+type ReasonSource string
+
+// Supported ReasonSource values.
+const (
+	// ReasonSourceAnnotation is the reason annotation of the protected
+	// resource.
+	ReasonSourceAnnotation ReasonSource = "annotation"
+	// ReasonSourceTrigger is the reason naming the trigger that caused
+	// protection.
+	ReasonSourceTrigger ReasonSource = "trigger"
+	// ReasonSourceDefault is the reason generated by the function.
+	ReasonSourceDefault ReasonSource = "default"
+)
+
+// GroupKind identifies a kind of resource by API group and kind. Both fields
+// support glob patterns, e.g. a group of *.rds.aws.upbound.io and a kind of *
+// match every kind in the RDS API groups.
+type GroupKind struct {
+	// Group is the API group of the resource, e.g. s3.aws.upbound.io. An empty
+	// group matches the core API group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind is the kind of the resource, e.g. Bucket or *.
+	Kind string `json:"kind"`
+}
+
+// MatchOperator is an operator used by a MatchExpression.
+type MatchOperator string
+
+// Supported MatchExpression operators.
+const (
+	// MatchOperatorIn matches if the field value is one of the values.
+	MatchOperatorIn MatchOperator = "In"
+	// MatchOperatorNotIn matches if the field is absent or its value is not one
+	// of the values.
+	MatchOperatorNotIn MatchOperator = "NotIn"
+	// MatchOperatorExists matches if the field is present.
+	MatchOperatorExists MatchOperator = "Exists"
+	// MatchOperatorEquals matches if the field value equals the first value.
+	MatchOperatorEquals MatchOperator = "Equals"
+)
+
+// LabelMatch matches a label of a resource by value.
+type LabelMatch struct {
+	// Key is the key of the label, e.g. tier.
+	Key string `json:"key"`
+
+	// Value is the value the label must equal, e.g. critical.
+	Value string `json:"value"`
+
+	// IgnoreCase compares the label value case-insensitively.
+	// +optional
+	// +kubebuilder:default:=false
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+}
+
+// AnnotationRegex matches an annotation of a resource against a regular
+// expression.
+type AnnotationRegex struct {
+	// Key is the key of the annotation, e.g. environment.
+	Key string `json:"key"`
+
+	// Pattern is the regular expression the annotation value must match, e.g.
+	// ^prod.
+	Pattern string `json:"pattern"`
+}
+
+// FieldMatch matches a field of a resource against a regular expression.
+type FieldMatch struct {
+	// FieldPath is the path of the field to match, e.g.
+	// spec.forProvider.imageId. If the field is a list it matches if any
+	// element matches.
+	FieldPath string `json:"fieldPath"`
+
+	// Regex is the regular expression the field must match.
+	Regex string `json:"regex"`
+}
+
+// MatchExpression matches a field of a resource.
+type MatchExpression struct {
+	// FieldPath is the path of the field to match, e.g.
+	// spec.forProvider.engine.
+	FieldPath string `json:"fieldPath"`
+
+	// Operator is the operator used to match the field.
+	// +kubebuilder:validation:Enum=In;NotIn;Exists;Equals
+	Operator MatchOperator `json:"operator"`
+
+	// Values are the values to compare the field against. Exists does not
+	// use any values and Equals uses the first value.
+	// +optional
+	Values []string `json:"values,omitempty"`
 }
@@ -0,0 +1,35 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProtectionPolicy type metadata.
+const (
+	ProtectionPolicyAPIVersion = "protection.fn.crossplane.io/v1beta1"
+	ProtectionPolicyKind       = "ProtectionPolicy"
+)
+
+// A ProtectionPolicy declares protection rules centrally, so they can be
+// applied uniformly by every Function that selects it. Unlike the Input its
+// CRD must be installed for policies to be created.
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:scope=Cluster,categories=crossplane
+type ProtectionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProtectionPolicySpec `json:"spec"`
+}
+
+// ProtectionPolicySpec is the specification of a ProtectionPolicy.
+type ProtectionPolicySpec struct {
+	// Rules protect composed resources that match any of them.
+	// +optional
+	Rules []ProtectionRule `json:"rules,omitempty"`
+
+	// ExcludeKinds are never protected by rules.
+	// +optional
+	ExcludeKinds []GroupKind `json:"excludeKinds,omitempty"`
+}
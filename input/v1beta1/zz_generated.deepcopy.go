@@ -5,14 +5,269 @@
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnnotationRegex) DeepCopyInto(out *AnnotationRegex) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnnotationRegex.
+func (in *AnnotationRegex) DeepCopy() *AnnotationRegex {
+	if in == nil {
+		return nil
+	}
+	out := new(AnnotationRegex)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
+func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrossCompositionRef) DeepCopyInto(out *CrossCompositionRef) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossCompositionRef.
+func (in *CrossCompositionRef) DeepCopy() *CrossCompositionRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CrossCompositionRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FieldMatch) DeepCopyInto(out *FieldMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FieldMatch.
+func (in *FieldMatch) DeepCopy() *FieldMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupKind) DeepCopyInto(out *GroupKind) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupKind.
+func (in *GroupKind) DeepCopy() *GroupKind {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Input) DeepCopyInto(out *Input) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.PresenceOnlyLabels != nil {
+		in, out := &in.PresenceOnlyLabels, &out.PresenceOnlyLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailClosedKinds != nil {
+		in, out := &in.FailClosedKinds, &out.FailClosedKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectByOwnerKinds != nil {
+		in, out := &in.ProtectByOwnerKinds, &out.ProtectByOwnerKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectProviders != nil {
+		in, out := &in.ProtectProviders, &out.ProtectProviders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectWhen != nil {
+		in, out := &in.ProtectWhen, &out.ProtectWhen
+		*out = make([]MatchExpression, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProtectEnvironments != nil {
+		in, out := &in.ProtectEnvironments, &out.ProtectEnvironments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RedactReasonPatterns != nil {
+		in, out := &in.RedactReasonPatterns, &out.RedactReasonPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.XRProtectionTriggerKinds != nil {
+		in, out := &in.XRProtectionTriggerKinds, &out.XRProtectionTriggerKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.XRSelectorLabels != nil {
+		in, out := &in.XRSelectorLabels, &out.XRSelectorLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectNewestKind != nil {
+		in, out := &in.ProtectNewestKind, &out.ProtectNewestKind
+		*out = new(GroupKind)
+		**out = **in
+	}
+	if in.PolicyConfigMapRef != nil {
+		in, out := &in.PolicyConfigMapRef, &out.PolicyConfigMapRef
+		*out = new(ConfigMapReference)
+		**out = **in
+	}
+	if in.SkipObserveOnly != nil {
+		in, out := &in.SkipObserveOnly, &out.SkipObserveOnly
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OnlyOwnedResources != nil {
+		in, out := &in.OnlyOwnedResources, &out.OnlyOwnedResources
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProtectIfPriorityAtLeast != nil {
+		in, out := &in.ProtectIfPriorityAtLeast, &out.ProtectIfPriorityAtLeast
+		*out = new(int)
+		**out = **in
+	}
+	if in.IncludeLabelInReason != nil {
+		in, out := &in.IncludeLabelInReason, &out.IncludeLabelInReason
+		*out = new(bool)
+		**out = **in
+	}
+	if in.WarnUnprotectedKinds != nil {
+		in, out := &in.WarnUnprotectedKinds, &out.WarnUnprotectedKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchLabelEquals != nil {
+		in, out := &in.MatchLabelEquals, &out.MatchLabelEquals
+		*out = make([]LabelMatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchAnnotationRegex != nil {
+		in, out := &in.MatchAnnotationRegex, &out.MatchAnnotationRegex
+		*out = make([]AnnotationRegex, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequireAllSelectors != nil {
+		in, out := &in.RequireAllSelectors, &out.RequireAllSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RefPaths != nil {
+		in, out := &in.RefPaths, &out.RefPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CrossCompositionRefs != nil {
+		in, out := &in.CrossCompositionRefs, &out.CrossCompositionRefs
+		*out = make([]CrossCompositionRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OnlyForCompositions != nil {
+		in, out := &in.OnlyForCompositions, &out.OnlyForCompositions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretRefPaths != nil {
+		in, out := &in.SecretRefPaths, &out.SecretRefPaths
+		*out = make([]ObjectRefPath, len(*in))
+		copy(*out, *in)
+	}
+	if in.InheritXRLabels != nil {
+		in, out := &in.InheritXRLabels, &out.InheritXRLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReasonPrecedence != nil {
+		in, out := &in.ReasonPrecedence, &out.ReasonPrecedence
+		*out = make([]ReasonSource, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredSelectors != nil {
+		in, out := &in.RequiredSelectors, &out.RequiredSelectors
+		*out = make([]RequiredSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SubresourceReasons != nil {
+		in, out := &in.SubresourceReasons, &out.SubresourceReasons
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RespectUserUsages != nil {
+		in, out := &in.RespectUserUsages, &out.RespectUserUsages
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProtectIfFieldMatches != nil {
+		in, out := &in.ProtectIfFieldMatches, &out.ProtectIfFieldMatches
+		*out = make([]FieldMatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectionRules != nil {
+		in, out := &in.ProtectionRules, &out.ProtectionRules
+		*out = make([]ProtectionRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExcludeKinds != nil {
+		in, out := &in.ExcludeKinds, &out.ExcludeKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectionPolicySelector != nil {
+		in, out := &in.ProtectionPolicySelector, &out.ProtectionPolicySelector
+		*out = new(ProtectionPolicySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Input.
@@ -32,3 +287,177 @@ func (in *Input) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabelMatch) DeepCopyInto(out *LabelMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabelMatch.
+func (in *LabelMatch) DeepCopy() *LabelMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(LabelMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatchExpression) DeepCopyInto(out *MatchExpression) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchExpression.
+func (in *MatchExpression) DeepCopy() *MatchExpression {
+	if in == nil {
+		return nil
+	}
+	out := new(MatchExpression)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectRefPath) DeepCopyInto(out *ObjectRefPath) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectRefPath.
+func (in *ObjectRefPath) DeepCopy() *ObjectRefPath {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectRefPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectionPolicy) DeepCopyInto(out *ProtectionPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectionPolicy.
+func (in *ProtectionPolicy) DeepCopy() *ProtectionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProtectionPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectionPolicySelector) DeepCopyInto(out *ProtectionPolicySelector) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectionPolicySelector.
+func (in *ProtectionPolicySelector) DeepCopy() *ProtectionPolicySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectionPolicySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectionPolicySpec) DeepCopyInto(out *ProtectionPolicySpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]ProtectionRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExcludeKinds != nil {
+		in, out := &in.ExcludeKinds, &out.ExcludeKinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectionPolicySpec.
+func (in *ProtectionPolicySpec) DeepCopy() *ProtectionPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectionPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectionRule) DeepCopyInto(out *ProtectionRule) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]GroupKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectionRule.
+func (in *ProtectionRule) DeepCopy() *ProtectionRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectionRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequiredSelector) DeepCopyInto(out *RequiredSelector) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequiredSelector.
+func (in *RequiredSelector) DeepCopy() *RequiredSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(RequiredSelector)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// matchesGlob returns true if the value matches the supplied glob pattern. A
+// malformed pattern only matches itself.
+func matchesGlob(pattern, value string) bool {
+	ok, err := path.Match(pattern, value)
+	if err != nil {
+		return pattern == value
+	}
+	return ok
+}
+
+// matchesGroupKind returns true if the group and kind match the supplied
+// GroupKind, which may use glob patterns such as *.rds.aws.upbound.io or *.
+func matchesGroupKind(gk v1beta1.GroupKind, group, kind string) bool {
+	return matchesGlob(gk.Group, group) && matchesGlob(gk.Kind, kind)
+}
+
+// MatchesGroupKind returns true if the resource matches any of the supplied
+// GroupKinds.
+func MatchesGroupKind(u *unstructured.Unstructured, gks []v1beta1.GroupKind) bool {
+	gvk := u.GroupVersionKind()
+	for _, gk := range gks {
+		if matchesGroupKind(gk, gvk.Group, gvk.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesProvider returns true if the resource's API group is one of the
+// supplied provider domains, or a subdomain of one.
+func MatchesProvider(u *unstructured.Unstructured, domains []string) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	group := u.GroupVersionKind().Group
+	if group == "" {
+		return false
+	}
+	for _, d := range domains {
+		if group == d || strings.HasSuffix(group, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// PriorityAtLeast returns true if the resource's priority annotation is an
+// integer at or above the supplied threshold. Missing and non-numeric
+// priorities never match.
+func PriorityAtLeast(u *unstructured.Unstructured, annotation string, threshold *int) bool {
+	if u == nil || u.Object == nil || threshold == nil {
+		return false
+	}
+	if annotation == "" {
+		annotation = DefaultPriorityAnnotation
+	}
+	v, ok := u.GetAnnotations()[annotation]
+	if !ok {
+		return false
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return false
+	}
+	return p >= *threshold
+}
+
+// MatchesOwnerKind returns true if any of the resource's owners matches one of
+// the supplied GroupKinds.
+func MatchesOwnerKind(u *unstructured.Unstructured, gks []v1beta1.GroupKind) bool {
+	if u == nil || u.Object == nil || len(gks) == 0 {
+		return false
+	}
+	for _, ref := range u.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+		for _, gk := range gks {
+			if matchesGroupKind(gk, gv.Group, ref.Kind) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchesLabelEquals returns true if any of the resource's labels equals the
+// value of one of the supplied matches.
+func MatchesLabelEquals(u *unstructured.Unstructured, matches []v1beta1.LabelMatch) bool {
+	if u == nil || u.Object == nil || len(matches) == 0 {
+		return false
+	}
+	labels := u.GetLabels()
+	for _, m := range matches {
+		v, ok := labels[m.Key]
+		if !ok {
+			continue
+		}
+		if v == m.Value || (m.IgnoreCase && strings.EqualFold(v, m.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotationRegexps are compiled AnnotationRegex matches.
+type AnnotationRegexps []annotationRegexp
+
+type annotationRegexp struct {
+	key string
+	re  *regexp.Regexp
+}
+
+// CompileAnnotationRegex compiles the supplied matches, so they can be
+// evaluated against many resources.
+func CompileAnnotationRegex(matches []v1beta1.AnnotationRegex) (AnnotationRegexps, error) {
+	compiled := make(AnnotationRegexps, 0, len(matches))
+	for _, m := range matches {
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid matchAnnotationRegex pattern for %q", m.Key)
+		}
+		compiled = append(compiled, annotationRegexp{key: m.Key, re: re})
+	}
+	return compiled, nil
+}
+
+// Matchers are the regular expressions and label selectors of an Input,
+// compiled once per run so they can be evaluated against many resources.
+type Matchers struct {
+	// ExternalName is the compiled ProtectExternalNameRegex. It is nil if the
+	// Input does not set one.
+	ExternalName *regexp.Regexp
+
+	// Fields are the compiled ProtectIfFieldMatches.
+	Fields []FieldRegexp
+
+	// AllSelectors are the compiled RequireAllSelectors.
+	AllSelectors []labels.Selector
+}
+
+// FieldRegexp is a compiled FieldMatch.
+type FieldRegexp struct {
+	FieldPath string
+	Regex     *regexp.Regexp
+}
+
+// CompileMatchers compiles the regular expressions and label selectors of the
+// supplied Input.
+func CompileMatchers(in *v1beta1.Input) (Matchers, error) {
+	m := Matchers{}
+	if in.ProtectExternalNameRegex != "" {
+		re, err := regexp.Compile(in.ProtectExternalNameRegex)
+		if err != nil {
+			return m, errors.Wrap(err, "invalid protectExternalNameRegex")
+		}
+		m.ExternalName = re
+	}
+	for _, f := range in.ProtectIfFieldMatches {
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return m, errors.Wrapf(err, "invalid protectIfFieldMatches regex for %q", f.FieldPath)
+		}
+		m.Fields = append(m.Fields, FieldRegexp{FieldPath: f.FieldPath, Regex: re})
+	}
+	for i := range in.RequireAllSelectors {
+		s, err := metav1.LabelSelectorAsSelector(&in.RequireAllSelectors[i])
+		if err != nil {
+			return m, errors.Wrapf(err, "invalid requireAllSelectors entry %d", i)
+		}
+		m.AllSelectors = append(m.AllSelectors, s)
+	}
+	return m, nil
+}
+
+// Matches returns true if any annotation of the resource matches. Resources
+// without the annotation do not match.
+func (a AnnotationRegexps) Matches(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	annotations := u.GetAnnotations()
+	for _, m := range a {
+		if v, ok := annotations[m.key]; ok && m.re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// InSharedGroup returns true if the resource sets the supplied shared
+// protection group label to a non-empty value.
+func InSharedGroup(u *unstructured.Unstructured, label string) bool {
+	if u == nil || u.Object == nil || label == "" {
+		return false
+	}
+	return u.GetLabels()[label] != ""
+}
+
+// MatchesAllSelectors returns true if the resource's labels match every one of
+// the supplied selectors. An empty list of selectors never matches.
+func MatchesAllSelectors(u *unstructured.Unstructured, selectors []labels.Selector) bool {
+	if u == nil || u.Object == nil || len(selectors) == 0 {
+		return false
+	}
+	set := labels.Set(u.GetLabels())
+	for _, s := range selectors {
+		if !s.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesExpressions returns true if the resource matches all of the supplied
+// expressions. An empty list of expressions never matches.
+func MatchesExpressions(u *unstructured.Unstructured, exprs []v1beta1.MatchExpression) bool {
+	if u == nil || u.Object == nil || len(exprs) == 0 {
+		return false
+	}
+	p := fieldpath.Pave(u.Object)
+	for _, e := range exprs {
+		if !matchesExpression(p, e) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesExpression(p *fieldpath.Paved, e v1beta1.MatchExpression) bool {
+	v, err := p.GetValue(e.FieldPath)
+	found := err == nil
+	switch e.Operator {
+	case v1beta1.MatchOperatorExists:
+		return found
+	case v1beta1.MatchOperatorEquals:
+		return found && len(e.Values) > 0 && fmt.Sprint(v) == e.Values[0]
+	case v1beta1.MatchOperatorIn:
+		return found && slices.Contains(e.Values, fmt.Sprint(v))
+	case v1beta1.MatchOperatorNotIn:
+		return !found || !slices.Contains(e.Values, fmt.Sprint(v))
+	default:
+		return false
+	}
+}
+
+// MatchesFieldValue returns true if the field at the supplied path equals the
+// value. If the value is empty the field only needs to be present.
+func MatchesFieldValue(u *unstructured.Unstructured, path, value string) bool {
+	if u == nil || u.Object == nil || path == "" {
+		return false
+	}
+	v, err := fieldpath.Pave(u.Object).GetValue(path)
+	if err != nil || v == nil {
+		return false
+	}
+	return value == "" || fmt.Sprint(v) == value
+}
+
+// MatchesExternalName returns true if the resource's external name matches the
+// supplied regular expression. Resources without an external name and a nil
+// regular expression never match.
+func MatchesExternalName(u *unstructured.Unstructured, re *regexp.Regexp) bool {
+	if u == nil || u.Object == nil || re == nil {
+		return false
+	}
+	name := meta.GetExternalName(u)
+	if name == "" {
+		return false
+	}
+	return re.MatchString(name)
+}
+
+// MatchesFieldRegex returns true if the field of the resource at the path of
+// any of the supplied matches matches its regular expression. Lists match if
+// any element matches. Missing fields never match.
+func MatchesFieldRegex(u *unstructured.Unstructured, matches []FieldRegexp) bool {
+	if u == nil || u.Object == nil || len(matches) == 0 {
+		return false
+	}
+	p := fieldpath.Pave(u.Object)
+	for _, m := range matches {
+		v, err := p.GetValue(m.FieldPath)
+		if err != nil || v == nil {
+			continue
+		}
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, e := range values {
+			if e != nil && m.Regex.MatchString(fmt.Sprint(e)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchingRule returns the first of the supplied rules the resource matches. A
+// rule matches if the resource is of one of its kinds and has all of its
+// labels.
+func MatchingRule(u *unstructured.Unstructured, rules []v1beta1.ProtectionRule) (v1beta1.ProtectionRule, bool) {
+	if u == nil || u.Object == nil {
+		return v1beta1.ProtectionRule{}, false
+	}
+	labels := u.GetLabels()
+	for _, r := range rules {
+		if len(r.Kinds) > 0 && !MatchesGroupKind(u, r.Kinds) {
+			continue
+		}
+		matches := true
+		for k, v := range r.MatchLabels {
+			if got, ok := labels[k]; !ok || got != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return r, true
+		}
+	}
+	return v1beta1.ProtectionRule{}, false
+}
+
+// NewestOfKind returns the names of the n most recently created observed
+// resources of the supplied kind. The creation time is read from the supplied
+// annotation if a resource has no creation timestamp. Resources without a
+// creation time are considered the oldest, and resources created at the same
+// time are ordered by name.
+func NewestOfKind(observed map[resource.Name]resource.ObservedComposed, gk v1beta1.GroupKind, n int, annotation string) map[resource.Name]bool {
+	newest := map[resource.Name]bool{}
+	if n <= 0 {
+		return newest
+	}
+	names := make([]resource.Name, 0, len(observed))
+	for name, o := range observed {
+		if o.Resource == nil || !MatchesGroupKind(&o.Resource.Unstructured, []v1beta1.GroupKind{gk}) {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b resource.Name) int {
+		ta, tb := CreationTime(&observed[a].Resource.Unstructured, annotation), CreationTime(&observed[b].Resource.Unstructured, annotation)
+		if c := tb.Compare(ta); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	for _, name := range names[:min(n, len(names))] {
+		newest[name] = true
+	}
+	return newest
+}
+
+// ControlledBy returns true if the resource's controller owner reference
+// refers to the supplied owner. References are compared by UID if both are
+// known, otherwise by API version, kind and name.
+func ControlledBy(u, owner *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil || owner == nil || owner.Object == nil {
+		return false
+	}
+	ref := metav1.GetControllerOf(u)
+	if ref == nil {
+		return false
+	}
+	if ref.UID != "" && owner.GetUID() != "" {
+		return ref.UID == owner.GetUID()
+	}
+	return ref.APIVersion == owner.GetAPIVersion() && ref.Kind == owner.GetKind() && ref.Name == owner.GetName()
+}
+
+// ControlledByOther returns true if the resource has a controller owner
+// reference that does not refer to the supplied owner.
+func ControlledByOther(u, owner *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil || owner == nil || owner.Object == nil {
+		return false
+	}
+	return metav1.GetControllerOf(u) != nil && !ControlledBy(u, owner)
+}
+
+// ObserveOnly returns true if the resource's management policies only allow
+// Crossplane to observe it. Both spec.managementPolicies and the legacy
+// spec.managementPolicy field are supported.
+func ObserveOnly(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	if p, found, _ := unstructured.NestedString(u.Object, "spec", "managementPolicy"); found && p == "ObserveOnly" {
+		return true
+	}
+	policies, found, _ := unstructured.NestedStringSlice(u.Object, "spec", "managementPolicies")
+	if !found || len(policies) == 0 {
+		return false
+	}
+	for _, p := range policies {
+		if p != "Observe" {
+			return false
+		}
+	}
+	return true
+}
+
+// DeletesExternal returns true if deleting the resource also deletes its
+// external resource, i.e. its spec.deletionPolicy is Delete or unset.
+func DeletesExternal(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return true
+	}
+	v, err := fieldpath.Pave(u.Object).GetString("spec.deletionPolicy")
+	if err != nil {
+		return true
+	}
+	return v == "" || v == "Delete"
+}
+
+// Sampled returns true if the resource is among the supplied percentage of
+// resources selected for protection. The selection is derived from a hash of
+// the resource's kind, namespace and name, so it is stable across runs.
+func Sampled(u *unstructured.Unstructured, percent int) bool {
+	if u == nil || u.Object == nil || percent <= 0 || u.GetName() == "" {
+		return false
+	}
+	h := sha256.Sum256([]byte(strings.Join([]string{u.GetKind(), u.GetNamespace(), u.GetName()}, "/")))
+	return binary.BigEndian.Uint64(h[:8])%100 < uint64(percent)
+}
+
+// ConditionTrue returns true if the resource reports a status condition of the
+// supplied type with status True.
+func ConditionTrue(u *unstructured.Unstructured, conditionType string) bool {
+	if u == nil || u.Object == nil || conditionType == "" {
+		return false
+	}
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]any); ok && cond["type"] == conditionType {
+			return cond["status"] == "True"
+		}
+	}
+	return false
+}
+
+// HasConnectionSecret returns true if the resource writes a connection secret.
+func HasConnectionSecret(u *unstructured.Unstructured) bool {
+	if u == nil || u.Object == nil {
+		return false
+	}
+	p := fieldpath.Pave(u.Object)
+	for _, path := range []string{"spec.writeConnectionSecretToRef.name", "spec.publishConnectionDetailsTo.name"} {
+		if v, err := p.GetString(path); err == nil && v != "" {
+			return true
+		}
+	}
+	return false
+}
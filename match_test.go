@@ -0,0 +1,1523 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestMatchesGroupKind(t *testing.T) {
+	type args struct {
+		u   *unstructured.Unstructured
+		gks []v1beta1.GroupKind
+	}
+	type want struct {
+		match bool
+	}
+
+	bucket := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "s3.aws.upbound.io/v1beta1",
+		"kind":       "Bucket",
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoGroupKinds": {
+			reason: "A resource should not match an empty list",
+			args:   args{u: bucket},
+			want:   want{match: false},
+		},
+		"ExactMatch": {
+			reason: "A resource should match its group and kind",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "s3.aws.upbound.io", Kind: "Bucket"}}},
+			want:   want{match: true},
+		},
+		"OtherGroup": {
+			reason: "A resource should not match the same kind in another group",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "s3.gcp.upbound.io", Kind: "Bucket"}}},
+			want:   want{match: false},
+		},
+		"CoreGroup": {
+			reason: "A core resource should match an empty group",
+			args: args{
+				u:   &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "Secret"}},
+				gks: []v1beta1.GroupKind{{Kind: "Secret"}},
+			},
+			want: want{match: true},
+		},
+		"GroupWildcard": {
+			reason: "A resource should match a group wildcard",
+			args: args{
+				u:   &unstructured.Unstructured{Object: map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance"}},
+				gks: []v1beta1.GroupKind{{Group: "*.aws.upbound.io", Kind: "Instance"}},
+			},
+			want: want{match: true},
+		},
+		"GroupAndKindWildcard": {
+			reason: "A resource should match wildcards for both group and kind",
+			args: args{
+				u:   &unstructured.Unstructured{Object: map[string]any{"apiVersion": "cluster.rds.aws.upbound.io/v1beta1", "kind": "Cluster"}},
+				gks: []v1beta1.GroupKind{{Group: "*.rds.aws.upbound.io", Kind: "*"}},
+			},
+			want: want{match: true},
+		},
+		"GroupWildcardNoMatch": {
+			reason: "A resource should not match a group wildcard for another group",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "*.rds.aws.upbound.io", Kind: "*"}}},
+			want:   want{match: false},
+		},
+		"KindWildcard": {
+			reason: "A resource should match a kind wildcard in any group",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "*", Kind: "Bucket"}}},
+			want:   want{match: true},
+		},
+		"KindPrefixWildcard": {
+			reason: "A resource should not match a kind wildcard for other kinds",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "s3.aws.upbound.io", Kind: "BucketPolicy*"}}},
+			want:   want{match: false},
+		},
+		"MalformedPattern": {
+			reason: "A malformed pattern should only match itself",
+			args:   args{u: bucket, gks: []v1beta1.GroupKind{{Group: "[", Kind: "Bucket"}}},
+			want:   want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesGroupKind(tc.args.u, tc.args.gks)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesGroupKind(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesProvider(t *testing.T) {
+	type args struct {
+		u       *unstructured.Unstructured
+		domains []string
+	}
+	type want struct {
+		match bool
+	}
+
+	of := func(apiVersion string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{"apiVersion": apiVersion, "kind": "Bucket"}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ProviderSubdomain": {
+			reason: "A resource should match when its group is a subdomain of a listed provider",
+			args:   args{u: of("s3.aws.upbound.io/v1beta1"), domains: []string{"gcp.upbound.io", "aws.upbound.io"}},
+			want:   want{match: true},
+		},
+		"ProviderDomain": {
+			reason: "A resource should match when its group equals a listed provider",
+			args:   args{u: of("aws.upbound.io/v1beta1"), domains: []string{"aws.upbound.io"}},
+			want:   want{match: true},
+		},
+		"OtherProvider": {
+			reason: "A resource should not match when its group belongs to another provider",
+			args:   args{u: of("storage.gcp.upbound.io/v1beta1"), domains: []string{"aws.upbound.io"}},
+			want:   want{match: false},
+		},
+		"PartialLabel": {
+			reason: "A resource should not match a provider that only matches part of a DNS label",
+			args:   args{u: of("s3.myaws.upbound.io/v1beta1"), domains: []string{"aws.upbound.io"}},
+			want:   want{match: false},
+		},
+		"CoreGroup": {
+			reason: "A resource of the core group should never match",
+			args:   args{u: of("v1"), domains: []string{"aws.upbound.io"}},
+			want:   want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesProvider(tc.args.u, tc.args.domains)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesProvider(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPriorityAtLeast(t *testing.T) {
+	type args struct {
+		u          *unstructured.Unstructured
+		annotation string
+		threshold  *int
+	}
+	type want struct {
+		match bool
+	}
+
+	withPriority := func(annotation, priority string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "s3.aws.upbound.io/v1beta1", "kind": "Bucket"}}
+		if priority != "" {
+			u.SetAnnotations(map[string]string{annotation: priority})
+		}
+		return u
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Above": {
+			reason: "A priority above the threshold should match",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, "95"), threshold: ptr.To(90)},
+			want:   want{match: true},
+		},
+		"At": {
+			reason: "A priority at the threshold should match",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, "90"), threshold: ptr.To(90)},
+			want:   want{match: true},
+		},
+		"Below": {
+			reason: "A priority below the threshold should not match",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, "89"), threshold: ptr.To(90)},
+			want:   want{match: false},
+		},
+		"Missing": {
+			reason: "A resource without a priority should not match",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, ""), threshold: ptr.To(90)},
+			want:   want{match: false},
+		},
+		"NonNumeric": {
+			reason: "A priority that is not an integer should not match",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, "high"), threshold: ptr.To(90)},
+			want:   want{match: false},
+		},
+		"CustomAnnotation": {
+			reason: "The priority should be read from the configured annotation",
+			args:   args{u: withPriority("example.org/priority", " 90 "), annotation: "example.org/priority", threshold: ptr.To(90)},
+			want:   want{match: true},
+		},
+		"NoThreshold": {
+			reason: "A resource should not match if no threshold is configured",
+			args:   args{u: withPriority(DefaultPriorityAnnotation, "95")},
+			want:   want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := PriorityAtLeast(tc.args.u, tc.args.annotation, tc.args.threshold)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nPriorityAtLeast(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAnnotationRegexps(t *testing.T) {
+	type args struct {
+		u       *unstructured.Unstructured
+		matches []v1beta1.AnnotationRegex
+	}
+	type want struct {
+		match bool
+		err   string
+	}
+
+	annotated := func(annotations map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance"}}
+		u.SetAnnotations(annotations)
+		return u
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Matching": {
+			reason: "A resource should match when an annotation value matches the pattern",
+			args: args{
+				u:       annotated(map[string]string{"environment": "production-eu"}),
+				matches: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "^prod"}},
+			},
+			want: want{match: true},
+		},
+		"NonMatching": {
+			reason: "A resource should not match when the annotation value does not match the pattern",
+			args: args{
+				u:       annotated(map[string]string{"environment": "staging"}),
+				matches: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "^prod"}},
+			},
+			want: want{match: false},
+		},
+		"MissingAnnotation": {
+			reason: "A resource without the annotation should not match, even if the pattern matches an empty value",
+			args: args{
+				u:       annotated(map[string]string{"team": "payments"}),
+				matches: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: ".*"}},
+			},
+			want: want{match: false},
+		},
+		"AnyMatch": {
+			reason: "A resource should match when any of the matches matches",
+			args: args{
+				u: annotated(map[string]string{"team": "payments"}),
+				matches: []v1beta1.AnnotationRegex{
+					{Key: "environment", Pattern: "^prod"},
+					{Key: "team", Pattern: "^pay"},
+				},
+			},
+			want: want{match: true},
+		},
+		"InvalidPattern": {
+			reason: "An invalid pattern should return an error",
+			args: args{
+				u:       annotated(map[string]string{"environment": "production"}),
+				matches: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "[prod"}},
+			},
+			want: want{err: "invalid matchAnnotationRegex pattern for \"environment\": error parsing regexp: missing closing ]: `[prod`"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a, err := CompileAnnotationRegex(tc.args.matches)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, gotErr); diff != "" {
+				t.Errorf("%s\nCompileAnnotationRegex(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.match, a.Matches(tc.args.u)); diff != "" {
+				t.Errorf("%s\nAnnotationRegexps.Matches(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesOwnerKind(t *testing.T) {
+	type args struct {
+		u   *unstructured.Unstructured
+		gks []v1beta1.GroupKind
+	}
+	type want struct {
+		match bool
+	}
+
+	owned := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestComposed",
+		"metadata": map[string]any{
+			"name": "owned",
+			"ownerReferences": []any{
+				map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-xr", "uid": "1"},
+			},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"MatchingOwner": {
+			reason: "A resource should match when an owner has a listed kind",
+			args:   args{u: owned, gks: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XDatabase"}}},
+			want:   want{match: true},
+		},
+		"NonMatchingOwner": {
+			reason: "A resource should not match when no owner has a listed kind",
+			args:   args{u: owned, gks: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XNetwork"}}},
+			want:   want{match: false},
+		},
+		"NoOwners": {
+			reason: "A resource without owners should not match",
+			args: args{
+				u:   &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "Secret"}},
+				gks: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XDatabase"}},
+			},
+			want: want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesOwnerKind(tc.args.u, tc.args.gks)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesOwnerKind(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesLabelEquals(t *testing.T) {
+	type args struct {
+		u       *unstructured.Unstructured
+		matches []v1beta1.LabelMatch
+	}
+	type want struct {
+		match bool
+	}
+
+	withLabels := func(labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata":   map[string]any{"labels": labels},
+		}}
+	}
+	critical := []v1beta1.LabelMatch{{Key: "tier", Value: "critical"}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoMatches": {
+			reason: "A resource should not match an empty list",
+			args:   args{u: withLabels(map[string]any{"tier": "critical"})},
+			want:   want{match: false},
+		},
+		"Equal": {
+			reason: "A resource should match a label with the configured value",
+			args:   args{u: withLabels(map[string]any{"tier": "critical"}), matches: critical},
+			want:   want{match: true},
+		},
+		"Unequal": {
+			reason: "A resource should not match a label with another value",
+			args:   args{u: withLabels(map[string]any{"tier": "dev"}), matches: critical},
+			want:   want{match: false},
+		},
+		"Missing": {
+			reason: "A resource should not match if the label is missing",
+			args:   args{u: withLabels(map[string]any{"team": "critical"}), matches: critical},
+			want:   want{match: false},
+		},
+		"CaseSensitive": {
+			reason: "Label values should be compared case-sensitively by default",
+			args:   args{u: withLabels(map[string]any{"tier": "Critical"}), matches: critical},
+			want:   want{match: false},
+		},
+		"IgnoreCase": {
+			reason: "Label values should be compared case-insensitively when ignoreCase is set",
+			args: args{
+				u:       withLabels(map[string]any{"tier": "Critical"}),
+				matches: []v1beta1.LabelMatch{{Key: "tier", Value: "critical", IgnoreCase: true}},
+			},
+			want: want{match: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesLabelEquals(tc.args.u, tc.args.matches)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesLabelEquals(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesExpressions(t *testing.T) {
+	type args struct {
+		exprs []v1beta1.MatchExpression
+	}
+	type want struct {
+		match bool
+	}
+
+	db := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "rds.aws.upbound.io/v1beta1",
+		"kind":       "Instance",
+		"spec": map[string]any{
+			"forProvider": map[string]any{
+				"engine":           "postgres",
+				"allocatedStorage": int64(100),
+			},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoExpressions": {
+			reason: "An empty list of expressions should not match",
+			args:   args{},
+			want:   want{match: false},
+		},
+		"InMatch": {
+			reason: "In should match when the value is listed",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorIn, Values: []string{"mysql", "postgres"}}}},
+			want:   want{match: true},
+		},
+		"InNoMatch": {
+			reason: "In should not match when the value is not listed",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorIn, Values: []string{"mysql"}}}},
+			want:   want{match: false},
+		},
+		"InMissingField": {
+			reason: "In should not match when the field is absent",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.missing", Operator: v1beta1.MatchOperatorIn, Values: []string{"postgres"}}}},
+			want:   want{match: false},
+		},
+		"NotInMatch": {
+			reason: "NotIn should match when the value is not listed",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorNotIn, Values: []string{"mysql"}}}},
+			want:   want{match: true},
+		},
+		"NotInNoMatch": {
+			reason: "NotIn should not match when the value is listed",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorNotIn, Values: []string{"postgres"}}}},
+			want:   want{match: false},
+		},
+		"NotInMissingField": {
+			reason: "NotIn should match when the field is absent",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.missing", Operator: v1beta1.MatchOperatorNotIn, Values: []string{"postgres"}}}},
+			want:   want{match: true},
+		},
+		"ExistsMatch": {
+			reason: "Exists should match when the field is present",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorExists}}},
+			want:   want{match: true},
+		},
+		"ExistsNoMatch": {
+			reason: "Exists should not match when the field is absent",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.missing", Operator: v1beta1.MatchOperatorExists}}},
+			want:   want{match: false},
+		},
+		"EqualsNumber": {
+			reason: "Equals should compare non-string values by their string form",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.allocatedStorage", Operator: v1beta1.MatchOperatorEquals, Values: []string{"100"}}}},
+			want:   want{match: true},
+		},
+		"EqualsNoMatch": {
+			reason: "Equals should not match a different value",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorEquals, Values: []string{"mysql"}}}},
+			want:   want{match: false},
+		},
+		"AllMustMatch": {
+			reason: "A resource should only match if every expression matches",
+			args: args{exprs: []v1beta1.MatchExpression{
+				{FieldPath: "spec.forProvider.engine", Operator: v1beta1.MatchOperatorEquals, Values: []string{"postgres"}},
+				{FieldPath: "spec.forProvider.missing", Operator: v1beta1.MatchOperatorExists},
+			}},
+			want: want{match: false},
+		},
+		"UnknownOperator": {
+			reason: "An unknown operator should not match",
+			args:   args{exprs: []v1beta1.MatchExpression{{FieldPath: "spec.forProvider.engine", Operator: "Like", Values: []string{"postgres"}}}},
+			want:   want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesExpressions(db, tc.args.exprs)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesExpressions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesFieldValue(t *testing.T) {
+	type args struct {
+		u     *unstructured.Unstructured
+		path  string
+		value string
+	}
+	type want struct {
+		match bool
+	}
+
+	db := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "rds.aws.upbound.io/v1beta1",
+		"kind":       "Instance",
+		"status": map[string]any{
+			"atProvider": map[string]any{
+				"allocatedStorage": int64(20),
+				"status":           "available",
+			},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPath": {
+			reason: "Nothing should match without a path",
+			args:   args{u: db},
+			want:   want{match: false},
+		},
+		"EqualString": {
+			reason: "A field with the expected value should match",
+			args:   args{u: db, path: "status.atProvider.status", value: "available"},
+			want:   want{match: true},
+		},
+		"EqualNumber": {
+			reason: "A numeric field should be compared by its string form",
+			args:   args{u: db, path: "status.atProvider.allocatedStorage", value: "20"},
+			want:   want{match: true},
+		},
+		"NotEqual": {
+			reason: "A field with another value should not match",
+			args:   args{u: db, path: "status.atProvider.status", value: "creating"},
+			want:   want{match: false},
+		},
+		"Present": {
+			reason: "A present field should match if no value is configured",
+			args:   args{u: db, path: "status.atProvider.allocatedStorage"},
+			want:   want{match: true},
+		},
+		"MissingStatus": {
+			reason: "A resource without status should not match",
+			args: args{
+				u:    &unstructured.Unstructured{Object: map[string]any{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance"}},
+				path: "status.atProvider.allocatedStorage",
+			},
+			want: want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := MatchesFieldValue(tc.args.u, tc.args.path, tc.args.value)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesFieldValue(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesExternalName(t *testing.T) {
+	type args struct {
+		u       *unstructured.Unstructured
+		pattern string
+	}
+	type want struct {
+		match bool
+		err   string
+	}
+
+	withExternalName := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata": map[string]any{
+				"annotations": map[string]any{"crossplane.io/external-name": name},
+			},
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPattern": {
+			reason: "Nothing should match without a pattern",
+			args:   args{u: withExternalName("prod-db")},
+			want:   want{match: false},
+		},
+		"Matching": {
+			reason: "A resource whose external name matches should match",
+			args:   args{u: withExternalName("prod-db"), pattern: "^prod-"},
+			want:   want{match: true},
+		},
+		"NotMatching": {
+			reason: "A resource whose external name does not match should not match",
+			args:   args{u: withExternalName("dev-db"), pattern: "^prod-"},
+			want:   want{match: false},
+		},
+		"MissingExternalName": {
+			reason: "A resource without an external name should not match",
+			args:   args{u: &unstructured.Unstructured{Object: map[string]any{"kind": "Instance"}}, pattern: ".*"},
+			want:   want{match: false},
+		},
+		"InvalidPattern": {
+			reason: "An invalid pattern should return an error",
+			args:   args{u: withExternalName("prod-db"), pattern: "[prod"},
+			want:   want{match: false, err: "invalid protectExternalNameRegex: error parsing regexp: missing closing ]: `[prod`"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := CompileMatchers(&v1beta1.Input{ProtectExternalNameRegex: tc.args.pattern})
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, gotErr); diff != "" {
+				t.Errorf("%s\nCompileMatchers(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+
+			got := MatchesExternalName(tc.args.u, m.ExternalName)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesExternalName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNewestOfKind(t *testing.T) {
+	type args struct {
+		observed   map[resource.Name]resource.ObservedComposed
+		gk         v1beta1.GroupKind
+		n          int
+		annotation string
+	}
+	type want struct {
+		newest map[resource.Name]bool
+	}
+
+	instance := func(kind, created string) resource.ObservedComposed {
+		m := map[string]any{"name": "instance"}
+		if created != "" {
+			m["creationTimestamp"] = created
+		}
+		return resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "ec2.aws.upbound.io/v1beta1",
+			"kind":       kind,
+			"metadata":   m,
+		}}}}
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"instance-a":   instance("Instance", "2026-01-01T00:00:00Z"),
+		"instance-b":   instance("Instance", "2026-01-03T00:00:00Z"),
+		"instance-c":   instance("Instance", "2026-01-02T00:00:00Z"),
+		"instance-d":   instance("Instance", "2026-01-02T00:00:00Z"),
+		"instance-new": instance("Instance", ""),
+		"vpc":          instance("VPC", "2026-01-04T00:00:00Z"),
+	}
+	gk := v1beta1.GroupKind{Group: "ec2.aws.upbound.io", Kind: "Instance"}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Disabled": {
+			reason: "No resources should be returned if n is zero",
+			args:   args{observed: observed, gk: gk},
+			want:   want{newest: map[resource.Name]bool{}},
+		},
+		"NewestOne": {
+			reason: "The most recently created resource of the kind should be returned",
+			args:   args{observed: observed, gk: gk, n: 1},
+			want:   want{newest: map[resource.Name]bool{"instance-b": true}},
+		},
+		"TiesOrderedByName": {
+			reason: "Resources created at the same time should be ordered by name",
+			args:   args{observed: observed, gk: gk, n: 2},
+			want:   want{newest: map[resource.Name]bool{"instance-b": true, "instance-c": true}},
+		},
+		"MissingTimestampIsOldest": {
+			reason: "Resources without a creation timestamp should be considered the oldest",
+			args:   args{observed: observed, gk: gk, n: 4},
+			want: want{newest: map[resource.Name]bool{
+				"instance-a": true,
+				"instance-b": true,
+				"instance-c": true,
+				"instance-d": true,
+			}},
+		},
+		"CreationTimeAnnotation": {
+			reason: "The creation time annotation should be used for resources without a creation timestamp",
+			args: args{
+				observed: func() map[resource.Name]resource.ObservedComposed {
+					o := maps.Clone(observed)
+					o["instance-rendered"] = instance("Instance", "")
+					o["instance-rendered"].Resource.SetAnnotations(map[string]string{"example.org/created-at": "2026-01-04T00:00:00Z"})
+					return o
+				}(),
+				gk:         gk,
+				n:          2,
+				annotation: "example.org/created-at",
+			},
+			want: want{newest: map[resource.Name]bool{"instance-b": true, "instance-rendered": true}},
+		},
+		"FewerThanN": {
+			reason: "All resources of the kind should be returned if there are fewer than n",
+			args:   args{observed: observed, gk: v1beta1.GroupKind{Group: "ec2.aws.upbound.io", Kind: "VPC"}, n: 3},
+			want:   want{newest: map[resource.Name]bool{"vpc": true}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewestOfKind(tc.args.observed, tc.args.gk, tc.args.n, tc.args.annotation)
+
+			if diff := cmp.Diff(tc.want.newest, got); diff != "" {
+				t.Errorf("%s\nNewestOfKind(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestControlledBy(t *testing.T) {
+	type args struct {
+		u     *unstructured.Unstructured
+		owner *unstructured.Unstructured
+	}
+	type want struct {
+		controlled bool
+	}
+
+	xr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "platform.example.com/v1",
+		"kind":       "XDatabase",
+		"metadata":   map[string]any{"name": "my-db", "uid": "1234"},
+	}}
+	ownedBy := func(ref map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata":   map[string]any{"ownerReferences": []any{ref}},
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"MatchingUID": {
+			reason: "A resource controlled by the owner's UID should match",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-db", "uid": "1234", "controller": true}),
+				owner: xr,
+			},
+			want: want{controlled: true},
+		},
+		"DifferentUID": {
+			reason: "A resource controlled by another object with the same name should not match",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-db", "uid": "5678", "controller": true}),
+				owner: xr,
+			},
+			want: want{controlled: false},
+		},
+		"MatchingNameWithoutUID": {
+			reason: "A controller reference without a UID should be compared by API version, kind and name",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-db", "controller": true}),
+				owner: xr,
+			},
+			want: want{controlled: true},
+		},
+		"NotController": {
+			reason: "An owner reference that is not the controller should not match",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-db", "uid": "1234"}),
+				owner: xr,
+			},
+			want: want{controlled: false},
+		},
+		"NoOwner": {
+			reason: "A resource without owner references should not match",
+			args: args{
+				u:     &unstructured.Unstructured{Object: map[string]any{"kind": "Instance"}},
+				owner: xr,
+			},
+			want: want{controlled: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ControlledBy(tc.args.u, tc.args.owner)
+
+			if diff := cmp.Diff(tc.want.controlled, got); diff != "" {
+				t.Errorf("%s\nControlledBy(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestControlledByOther(t *testing.T) {
+	type args struct {
+		u     *unstructured.Unstructured
+		owner *unstructured.Unstructured
+	}
+	type want struct {
+		other bool
+	}
+
+	xr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "platform.example.com/v1",
+		"kind":       "XDatabase",
+		"metadata":   map[string]any{"name": "my-db", "uid": "1234"},
+	}}
+	ownedBy := func(refs ...any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata":   map[string]any{"ownerReferences": refs},
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ControlledByOwner": {
+			reason: "A resource controlled by the owner should not be controlled by another resource",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "my-db", "uid": "1234", "controller": true}),
+				owner: xr,
+			},
+			want: want{other: false},
+		},
+		"ControlledByOther": {
+			reason: "A resource controlled by another composite should be controlled by another resource",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "other-db", "uid": "5678", "controller": true}),
+				owner: xr,
+			},
+			want: want{other: true},
+		},
+		"NotController": {
+			reason: "A resource only owned, not controlled, by another resource should not be controlled by another resource",
+			args: args{
+				u:     ownedBy(map[string]any{"apiVersion": "platform.example.com/v1", "kind": "XDatabase", "name": "other-db", "uid": "5678"}),
+				owner: xr,
+			},
+			want: want{other: false},
+		},
+		"NoOwner": {
+			reason: "A resource without owner references should not be controlled by another resource",
+			args:   args{u: ownedBy(), owner: xr},
+			want:   want{other: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ControlledByOther(tc.args.u, tc.args.owner)
+
+			if diff := cmp.Diff(tc.want.other, got); diff != "" {
+				t.Errorf("%s\nControlledByOther(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveOnly(t *testing.T) {
+	type args struct {
+		u *unstructured.Unstructured
+	}
+	type want struct {
+		observeOnly bool
+	}
+
+	withSpec := func(spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"spec":       spec,
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPolicies": {
+			reason: "A resource without management policies is fully managed",
+			args:   args{u: withSpec(map[string]any{})},
+			want:   want{observeOnly: false},
+		},
+		"FullyManaged": {
+			reason: "A resource with the default management policy is fully managed",
+			args:   args{u: withSpec(map[string]any{"managementPolicies": []any{"*"}})},
+			want:   want{observeOnly: false},
+		},
+		"ObserveOnly": {
+			reason: "A resource that may only be observed is observe-only",
+			args:   args{u: withSpec(map[string]any{"managementPolicies": []any{"Observe"}})},
+			want:   want{observeOnly: true},
+		},
+		"ObserveAndDelete": {
+			reason: "A resource that may also be deleted is not observe-only",
+			args:   args{u: withSpec(map[string]any{"managementPolicies": []any{"Observe", "Delete"}})},
+			want:   want{observeOnly: false},
+		},
+		"LegacyObserveOnly": {
+			reason: "The legacy ObserveOnly management policy is observe-only",
+			args:   args{u: withSpec(map[string]any{"managementPolicy": "ObserveOnly"})},
+			want:   want{observeOnly: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ObserveOnly(tc.args.u)
+
+			if diff := cmp.Diff(tc.want.observeOnly, got); diff != "" {
+				t.Errorf("%s\nObserveOnly(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSampled(t *testing.T) {
+	type args struct {
+		percent int
+	}
+	type want struct {
+		sampled []string
+	}
+
+	names := []string{"bucket-a", "bucket-b", "bucket-c", "bucket-d", "bucket-e", "bucket-f"}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Disabled": {
+			reason: "No resource should be sampled at zero percent",
+			args:   args{percent: 0},
+			want:   want{},
+		},
+		"Quarter": {
+			reason: "The same resources should be sampled at 25 percent on every run",
+			args:   args{percent: 25},
+			want:   want{sampled: []string{"bucket-e"}},
+		},
+		"Half": {
+			reason: "The same resources should be sampled at 50 percent on every run",
+			args:   args{percent: 50},
+			want:   want{sampled: []string{"bucket-c", "bucket-e", "bucket-f"}},
+		},
+		"ThreeQuarters": {
+			reason: "Resources sampled at a lower percentage should stay sampled at a higher one",
+			args:   args{percent: 75},
+			want:   want{sampled: []string{"bucket-a", "bucket-c", "bucket-e", "bucket-f"}},
+		},
+		"All": {
+			reason: "Every resource should be sampled at 100 percent",
+			args:   args{percent: 100},
+			want:   want{sampled: names},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, n := range names {
+				u := &unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "s3.aws.upbound.io/v1beta1",
+					"kind":       "Bucket",
+					"metadata":   map[string]any{"name": n},
+				}}
+				if Sampled(u, tc.args.percent) {
+					got = append(got, n)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.sampled, got); diff != "" {
+				t.Errorf("%s\nSampled(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConditionTrue(t *testing.T) {
+	type args struct {
+		u             *unstructured.Unstructured
+		conditionType string
+	}
+	type want struct {
+		match bool
+	}
+
+	withConditions := func(conditions ...any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"status":     map[string]any{"conditions": conditions},
+		}}
+	}
+	ready := map[string]any{"type": "Ready", "status": "True"}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ConditionTrue": {
+			reason: "A resource with the condition True should match",
+			args:   args{u: withConditions(ready, map[string]any{"type": "Critical", "status": "True"}), conditionType: "Critical"},
+			want:   want{match: true},
+		},
+		"ConditionFalse": {
+			reason: "A resource with the condition False should not match",
+			args:   args{u: withConditions(ready, map[string]any{"type": "Critical", "status": "False"}), conditionType: "Critical"},
+			want:   want{match: false},
+		},
+		"ConditionAbsent": {
+			reason: "A resource without the condition should not match",
+			args:   args{u: withConditions(ready), conditionType: "Critical"},
+			want:   want{match: false},
+		},
+		"NoStatus": {
+			reason: "A resource without a status should not match",
+			args: args{u: &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "rds.aws.upbound.io/v1beta1",
+				"kind":       "Instance",
+			}}, conditionType: "Critical"},
+			want: want{match: false},
+		},
+		"NoConditionType": {
+			reason: "No resource should match without a condition type",
+			args:   args{u: withConditions(ready)},
+			want:   want{match: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ConditionTrue(tc.args.u, tc.args.conditionType)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nConditionTrue(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHasConnectionSecret(t *testing.T) {
+	type args struct {
+		u *unstructured.Unstructured
+	}
+	type want struct {
+		has bool
+	}
+
+	withSpec := func(spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"spec":       spec,
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoConnectionSecret": {
+			reason: "A resource without a connection secret should not match",
+			args:   args{u: withSpec(map[string]any{"forProvider": map[string]any{}})},
+			want:   want{has: false},
+		},
+		"WriteConnectionSecretToRef": {
+			reason: "A resource that writes a connection secret should match",
+			args:   args{u: withSpec(map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "db-conn", "namespace": "default"}})},
+			want:   want{has: true},
+		},
+		"PublishConnectionDetailsTo": {
+			reason: "A resource that publishes connection details should match",
+			args:   args{u: withSpec(map[string]any{"publishConnectionDetailsTo": map[string]any{"name": "db-conn"}})},
+			want:   want{has: true},
+		},
+		"EmptyName": {
+			reason: "A connection secret reference without a name should not match",
+			args:   args{u: withSpec(map[string]any{"writeConnectionSecretToRef": map[string]any{}})},
+			want:   want{has: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := HasConnectionSecret(tc.args.u)
+
+			if diff := cmp.Diff(tc.want.has, got); diff != "" {
+				t.Errorf("%s\nHasConnectionSecret(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeletesExternal(t *testing.T) {
+	type args struct {
+		u *unstructured.Unstructured
+	}
+	type want struct {
+		deletes bool
+	}
+
+	withSpec := func(spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"spec":       spec,
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Unset": {
+			reason: "A resource without a deletion policy defaults to Delete",
+			args:   args{u: withSpec(map[string]any{})},
+			want:   want{deletes: true},
+		},
+		"Delete": {
+			reason: "A resource with a Delete deletion policy deletes its external resource",
+			args:   args{u: withSpec(map[string]any{"deletionPolicy": "Delete"})},
+			want:   want{deletes: true},
+		},
+		"Orphan": {
+			reason: "A resource with an Orphan deletion policy keeps its external resource",
+			args:   args{u: withSpec(map[string]any{"deletionPolicy": "Orphan"})},
+			want:   want{deletes: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := DeletesExternal(tc.args.u)
+
+			if diff := cmp.Diff(tc.want.deletes, got); diff != "" {
+				t.Errorf("%s\nDeletesExternal(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesFieldRegex(t *testing.T) {
+	type args struct {
+		u       *unstructured.Unstructured
+		matches []v1beta1.FieldMatch
+	}
+	type want struct {
+		match bool
+		err   string
+	}
+
+	withForProvider := func(fp map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "ec2.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"spec":       map[string]any{"forProvider": fp},
+		}}
+	}
+	ami := []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.ami", Regex: `^ami-0abc[0-9a-f]+$`}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoMatches": {
+			reason: "A resource should not match an empty list",
+			args:   args{u: withForProvider(map[string]any{"ami": "ami-0abc123"})},
+			want:   want{match: false},
+		},
+		"Matching": {
+			reason: "A resource should match if the field matches the regex",
+			args:   args{u: withForProvider(map[string]any{"ami": "ami-0abc123"}), matches: ami},
+			want:   want{match: true},
+		},
+		"NotMatching": {
+			reason: "A resource should not match if the field does not match the regex",
+			args:   args{u: withForProvider(map[string]any{"ami": "ami-0def456"}), matches: ami},
+			want:   want{match: false},
+		},
+		"Missing": {
+			reason: "A resource should not match if the field is missing",
+			args:   args{u: withForProvider(map[string]any{"instanceType": "t3.micro"}), matches: ami},
+			want:   want{match: false},
+		},
+		"ListElement": {
+			reason: "A list field should match if any element matches",
+			args: args{
+				u:       withForProvider(map[string]any{"images": []any{"nginx:1.27", "registry.example.org/app@sha256:abc"}}),
+				matches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.images", Regex: `@sha256:abc$`}},
+			},
+			want: want{match: true},
+		},
+		"NonStringField": {
+			reason: "Non-string fields should be matched by their string representation",
+			args: args{
+				u:       withForProvider(map[string]any{"port": int64(5432)}),
+				matches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.port", Regex: `^5432$`}},
+			},
+			want: want{match: true},
+		},
+		"AnyEntry": {
+			reason: "A resource should match if any entry matches",
+			args: args{
+				u:       withForProvider(map[string]any{"ami": "ami-0abc123"}),
+				matches: append([]v1beta1.FieldMatch{{FieldPath: "spec.forProvider.image", Regex: `.*`}}, ami...),
+			},
+			want: want{match: true},
+		},
+		"InvalidRegex": {
+			reason: "An invalid regex should return an error and never match",
+			args: args{
+				u:       withForProvider(map[string]any{"ami": "ami-0abc123"}),
+				matches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.ami", Regex: `(`}},
+			},
+			want: want{match: false, err: "invalid protectIfFieldMatches regex for \"spec.forProvider.ami\": error parsing regexp: missing closing ): `(`"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := CompileMatchers(&v1beta1.Input{ProtectIfFieldMatches: tc.args.matches})
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, gotErr); diff != "" {
+				t.Errorf("%s\nCompileMatchers(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+
+			got := MatchesFieldRegex(tc.args.u, m.Fields)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesFieldRegex(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchingRule(t *testing.T) {
+	type args struct {
+		u     *unstructured.Unstructured
+		rules []v1beta1.ProtectionRule
+	}
+	type want struct {
+		rule  v1beta1.ProtectionRule
+		match bool
+	}
+
+	instance := func(labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "rds.aws.upbound.io/v1beta1",
+			"kind":       "Instance",
+			"metadata":   map[string]any{"labels": labels},
+		}}
+	}
+	byKind := v1beta1.ProtectionRule{Kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}, Reason: "databases"}
+	byLabel := v1beta1.ProtectionRule{MatchLabels: map[string]string{"tier": "critical"}}
+	byBoth := v1beta1.ProtectionRule{Kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}, MatchLabels: map[string]string{"tier": "critical"}}
+	otherKind := v1beta1.ProtectionRule{Kinds: []v1beta1.GroupKind{{Group: "s3.aws.upbound.io", Kind: "Bucket"}}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoRules": {
+			reason: "A resource should not match without rules",
+			args:   args{u: instance(nil)},
+			want:   want{},
+		},
+		"Kind": {
+			reason: "A resource should match a rule for its kind",
+			args:   args{u: instance(nil), rules: []v1beta1.ProtectionRule{otherKind, byKind}},
+			want:   want{rule: byKind, match: true},
+		},
+		"OtherKind": {
+			reason: "A resource should not match a rule for another kind",
+			args:   args{u: instance(nil), rules: []v1beta1.ProtectionRule{otherKind}},
+			want:   want{},
+		},
+		"Labels": {
+			reason: "A resource should match a rule whose labels it has",
+			args:   args{u: instance(map[string]any{"tier": "critical", "team": "a"}), rules: []v1beta1.ProtectionRule{byLabel}},
+			want:   want{rule: byLabel, match: true},
+		},
+		"KindWithoutLabels": {
+			reason: "A resource should only match a rule with kinds and labels if it has both",
+			args:   args{u: instance(map[string]any{"tier": "dev"}), rules: []v1beta1.ProtectionRule{byBoth}},
+			want:   want{},
+		},
+		"FirstMatch": {
+			reason: "The first matching rule should be returned",
+			args:   args{u: instance(map[string]any{"tier": "critical"}), rules: []v1beta1.ProtectionRule{byBoth, byKind}},
+			want:   want{rule: byBoth, match: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule, match := MatchingRule(tc.args.u, tc.args.rules)
+
+			if diff := cmp.Diff(tc.want.rule, rule); diff != "" {
+				t.Errorf("%s\nMatchingRule(...): -want rule, +got rule:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.match, match); diff != "" {
+				t.Errorf("%s\nMatchingRule(...): -want match, +got match:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInSharedGroup(t *testing.T) {
+	type args struct {
+		u     *unstructured.Unstructured
+		label string
+	}
+	type want struct {
+		grouped bool
+	}
+
+	withLabels := func(labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{"metadata": map[string]any{"labels": labels}}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoLabelConfigured": {
+			reason: "A resource should not be grouped if no group label is configured",
+			args:   args{u: withLabels(map[string]any{"example.org/protection-group": "payments"})},
+			want:   want{},
+		},
+		"Nil": {
+			reason: "A missing resource should not be grouped",
+			args:   args{label: "example.org/protection-group"},
+			want:   want{},
+		},
+		"Labeled": {
+			reason: "A resource that sets the group label should be grouped",
+			args:   args{u: withLabels(map[string]any{"example.org/protection-group": "payments"}), label: "example.org/protection-group"},
+			want:   want{grouped: true},
+		},
+		"EmptyValue": {
+			reason: "A resource that sets the group label to an empty value should not be grouped",
+			args:   args{u: withLabels(map[string]any{"example.org/protection-group": ""}), label: "example.org/protection-group"},
+			want:   want{},
+		},
+		"OtherLabel": {
+			reason: "A resource without the group label should not be grouped",
+			args:   args{u: withLabels(map[string]any{"team": "payments"}), label: "example.org/protection-group"},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := InSharedGroup(tc.args.u, tc.args.label)
+
+			if diff := cmp.Diff(tc.want.grouped, got); diff != "" {
+				t.Errorf("%s\nInSharedGroup(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesAllSelectors(t *testing.T) {
+	type args struct {
+		u         *unstructured.Unstructured
+		selectors []metav1.LabelSelector
+	}
+	type want struct {
+		match bool
+		err   string
+	}
+
+	withLabels := func(labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{"metadata": map[string]any{"labels": labels}}}
+	}
+	criticalProd := []metav1.LabelSelector{
+		{MatchLabels: map[string]string{"tier": "critical"}},
+		{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "production"}}}},
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoSelectors": {
+			reason: "A resource should not match an empty list of selectors",
+			args:   args{u: withLabels(map[string]any{"tier": "critical"})},
+			want:   want{},
+		},
+		"All": {
+			reason: "A resource should match if it matches every selector",
+			args:   args{u: withLabels(map[string]any{"tier": "critical", "env": "prod", "team": "a"}), selectors: criticalProd},
+			want:   want{match: true},
+		},
+		"Some": {
+			reason: "A resource should not match if it only matches some of the selectors",
+			args:   args{u: withLabels(map[string]any{"tier": "critical", "env": "dev"}), selectors: criticalProd},
+			want:   want{},
+		},
+		"None": {
+			reason: "A resource should not match if it matches none of the selectors",
+			args:   args{u: withLabels(map[string]any{"tier": "standard"}), selectors: criticalProd},
+			want:   want{},
+		},
+		"InvalidSelector": {
+			reason: "A selector that cannot be compiled should return an error and never match",
+			args: args{u: withLabels(map[string]any{"tier": "critical"}), selectors: []metav1.LabelSelector{
+				{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Like", Values: []string{"critical"}}}},
+			}},
+			want: want{err: `invalid requireAllSelectors entry 0: "Like" is not a valid label selector operator`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := CompileMatchers(&v1beta1.Input{RequireAllSelectors: tc.args.selectors})
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, gotErr); diff != "" {
+				t.Errorf("%s\nCompileMatchers(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+
+			got := MatchesAllSelectors(tc.args.u, m.AllSelectors)
+
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("%s\nMatchesAllSelectors(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
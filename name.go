@@ -4,6 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"strings"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const (
@@ -15,7 +18,35 @@ const (
 
 // GenerateName generates a valid Kubernetes name.
 func GenerateName(name, suffix string) string {
-	h := sha256.Sum256([]byte(name))
+	return generateName(name, name, suffix)
+}
+
+// usageName returns the name of the supplied Usage according to the naming
+// scheme. The Usage is named after the resource it protects and, if it is not
+// empty, the subresource it documents.
+func usageName(usage map[string]any, subresource string, scheme v1beta1.NamingScheme) string {
+	str := func(fields ...string) string {
+		v, _, _ := unstructured.NestedString(usage, fields...)
+		return v
+	}
+	apiVersion, kind, name := str("spec", "of", "apiVersion"), str("spec", "of", "kind"), str("spec", "of", "resourceRef", "name")
+	namespace := str("metadata", "namespace")
+
+	parts := []string{kind, name}
+	if subresource != "" {
+		parts = append(parts, subresource)
+	}
+	base := strings.ToLower(strings.Join(parts, "-"))
+	if scheme != v1beta1.NamingSchemeHash {
+		return GenerateName(base, UsageNameSuffix)
+	}
+	return generateName(base, strings.Join(append([]string{apiVersion, namespace}, parts...), "/"), UsageNameSuffix)
+}
+
+// generateName generates a valid Kubernetes name from the supplied name,
+// followed by a hash of key and the suffix.
+func generateName(name, key, suffix string) string {
+	h := sha256.Sum256([]byte(key))
 	hEncoded := hex.EncodeToString(h[:])[:hashLength]
 	fullSuffix := hEncoded + "-" + suffix
 	fullName := name + "-" + fullSuffix
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/protobuf/testing/protocmp"
 )
@@ -97,3 +98,110 @@ func TestGenerateName(t *testing.T) {
 		})
 	}
 }
+
+func TestUsageName(t *testing.T) {
+	usage := func(apiVersion, kind, name, namespace string) map[string]any {
+		u := map[string]any{
+			"metadata": map[string]any{},
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":  apiVersion,
+					"kind":        kind,
+					"resourceRef": map[string]any{"name": name},
+				},
+			},
+		}
+		if namespace != "" {
+			u["metadata"] = map[string]any{"namespace": namespace}
+		}
+		return u
+	}
+
+	type args struct {
+		usage       map[string]any
+		subresource string
+		scheme      v1beta1.NamingScheme
+	}
+	type want struct {
+		name string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Suffix": {
+			reason: "The suffix scheme should name a Usage after the kind and name of the protected resource",
+			args:   args{usage: usage("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", "")},
+			want:   want{name: GenerateName("bucket-my-bucket", UsageNameSuffix)},
+		},
+		"SuffixSubresource": {
+			reason: "The suffix scheme should include the subresource in the name",
+			args:   args{usage: usage("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", ""), subresource: "storage"},
+			want:   want{name: GenerateName("bucket-my-bucket-storage", UsageNameSuffix)},
+		},
+		"Hash": {
+			reason: "The hash scheme should follow the base name with a hash of the full reference",
+			args:   args{usage: usage("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", ""), scheme: v1beta1.NamingSchemeHash},
+			want:   want{name: "bucket-my-bucket-16e648-fn-protection"},
+		},
+		"HashNamespaced": {
+			reason: "The hash scheme should include the namespace of the protected resource in the hash",
+			args:   args{usage: usage("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", "team-a"), scheme: v1beta1.NamingSchemeHash},
+			want:   want{name: "bucket-my-bucket-3fc1a0-fn-protection"},
+		},
+		"HashLongName": {
+			reason: "The hash scheme should truncate the base name to bound the length of the name",
+			args:   args{usage: usage("s3.aws.upbound.io/v1beta1", "Bucket", "a-very-long-bucket-name-that-is-more-than-sixty-three-characters", ""), scheme: v1beta1.NamingSchemeHash},
+			want:   want{name: "bucket-a-very-long-bucket-name-that-is-mor-742ae3-fn-protection"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := usageName(tc.args.usage, tc.args.subresource, tc.args.scheme)
+
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("%s\nusageName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if again := usageName(tc.args.usage, tc.args.subresource, tc.args.scheme); again != got {
+				t.Errorf("%s\nusageName(...): name is not stable, got %q and %q", tc.reason, got, again)
+			}
+			if len(got) > 63 {
+				t.Errorf("%s\nusageName(...): name exceeds Kubernetes limit: %d characters (max 63)", tc.reason, len(got))
+			}
+		})
+	}
+}
+
+func TestUsageNameHashUnique(t *testing.T) {
+	targets := map[string][4]string{
+		"Bucket":          {"s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", ""},
+		"OtherGroup":      {"storage.gcp.upbound.io/v1beta1", "Bucket", "my-bucket", ""},
+		"OtherNamespace":  {"s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", "team-a"},
+		"OtherName":       {"s3.aws.upbound.io/v1beta1", "Bucket", "my-other-bucket", ""},
+		"OtherKind":       {"s3.aws.upbound.io/v1beta1", "BucketPolicy", "my-bucket", ""},
+		"LongName":        {"s3.aws.upbound.io/v1beta1", "Bucket", "a-very-long-bucket-name-that-is-more-than-sixty-three-characters-1", ""},
+		"LongNameSibling": {"s3.aws.upbound.io/v1beta1", "Bucket", "a-very-long-bucket-name-that-is-more-than-sixty-three-characters-2", ""},
+	}
+
+	seen := map[string]string{}
+	for target, ref := range targets {
+		u := map[string]any{
+			"metadata": map[string]any{"namespace": ref[3]},
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":  ref[0],
+					"kind":        ref[1],
+					"resourceRef": map[string]any{"name": ref[2]},
+				},
+			},
+		}
+		got := usageName(u, "", v1beta1.NamingSchemeHash)
+		if other, ok := seen[got]; ok {
+			t.Errorf("usageName(...): targets %s and %s share the name %q", other, target, got)
+		}
+		seen[got] = target
+	}
+}
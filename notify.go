@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+
+	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// ContextKeyNotification is the context key a notification describing the
+// protections added by a run is written to, for use by a downstream notifier
+// function.
+const ContextKeyNotification = "protection.fn.crossplane.io/notification"
+
+// Notification describes the protections added by a run.
+type Notification struct {
+	// Composite is the composite resource the protections belong to.
+	Composite NotificationResource `json:"composite"`
+
+	// Protections that did not exist before this run, ordered by kind,
+	// namespace and name.
+	Protections []Decision `json:"protections"`
+}
+
+// NotificationResource identifies a resource in a notification.
+type NotificationResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// BuildNotification returns a notification for the protected decisions that
+// are not yet observed. A protection by Usage is new if no Usage of that name
+// is observed, and a protection by annotation is new if the observed resource
+// does not carry the AnnotationProtected annotation. It returns false if no
+// protection is new.
+func BuildNotification(observedComposite *resource.Composite, observedComposed map[resource.Name]resource.ObservedComposed, d Decisions) (Notification, bool) {
+	xr := observedComposite.Resource
+	n := Notification{
+		Composite:   NotificationResource{APIVersion: xr.GetAPIVersion(), Kind: xr.GetKind(), Name: xr.GetName(), Namespace: xr.GetNamespace()},
+		Protections: []Decision{},
+	}
+	for _, p := range d.Protected {
+		if !protectionObserved(observedComposite, observedComposed, p) {
+			n.Protections = append(n.Protections, p)
+		}
+	}
+	return n, len(n.Protections) > 0
+}
+
+// protectionObserved returns true if the supplied protection already exists
+// in the observed state.
+func protectionObserved(observedComposite *resource.Composite, observedComposed map[resource.Name]resource.ObservedComposed, p Decision) bool {
+	if p.Usage != "" {
+		for _, oc := range observedComposed {
+			if oc.Resource == nil {
+				continue
+			}
+			if k := oc.Resource.GetKind(); (k == protectionv1beta1.UsageKind || k == protectionv1beta1.ClusterUsageKind) && oc.Resource.GetName() == p.Usage {
+				return true
+			}
+		}
+		return false
+	}
+	if xr := observedComposite.Resource; xr.GetKind() == p.Kind && xr.GetName() == p.Name && xr.GetNamespace() == p.Namespace {
+		_, ok := xr.GetAnnotations()[AnnotationProtected]
+		return ok
+	}
+	for _, oc := range observedComposed {
+		if oc.Resource == nil {
+			continue
+		}
+		if u := oc.Resource; u.GetKind() == p.Kind && u.GetName() == p.Name && u.GetNamespace() == p.Namespace {
+			_, ok := u.GetAnnotations()[AnnotationProtected]
+			return ok
+		}
+	}
+	return false
+}
+
+// AsValue converts the notification to a context value.
+func (n Notification) AsValue() (*structpb.Value, error) {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(m)
+}
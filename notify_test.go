@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+func TestBuildNotification(t *testing.T) {
+	type args struct {
+		oxr      *resource.Composite
+		observed map[resource.Name]resource.ObservedComposed
+		d        Decisions
+	}
+	type want struct {
+		n  Notification
+		ok bool
+	}
+
+	xr := func(annotations map[string]any) *resource.Composite {
+		c := &resource.Composite{Resource: composite.New()}
+		c.Resource.Object = map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestXR",
+			"metadata":   map[string]any{"name": "my-xr", "namespace": "default", "annotations": annotations},
+		}
+		return c
+	}
+	oc := func(obj map[string]any) resource.ObservedComposed {
+		return resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}}
+	}
+	identity := NotificationResource{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Namespace: "default"}
+	bucket := Decision{
+		APIVersion: "s3.aws.m.upbound.io/v1beta1",
+		Kind:       "Bucket",
+		Name:       "my-bucket",
+		Namespace:  "default",
+		Reason:     ProtectionReasonLabel,
+		Usage:      "bucket-my-bucket-6a2d8c-fn-protection",
+	}
+	annotated := Decision{
+		APIVersion: "test.crossplane.io/v1",
+		Kind:       "TestXR",
+		Name:       "my-xr",
+		Namespace:  "default",
+		Reason:     ProtectionReasonCompositeChildResource,
+	}
+	observedUsage := oc(map[string]any{
+		"apiVersion": ProtectionGroupVersion,
+		"kind":       "Usage",
+		"metadata":   map[string]any{"name": "bucket-my-bucket-6a2d8c-fn-protection", "namespace": "default"},
+	})
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NothingProtected": {
+			reason: "No notification should be built when nothing is protected",
+			args:   args{oxr: xr(nil), d: Decisions{Protected: []Decision{}}},
+			want:   want{n: Notification{Composite: identity, Protections: []Decision{}}, ok: false},
+		},
+		"NewUsage": {
+			reason: "A protection by a Usage that is not observed yet should be notified",
+			args:   args{oxr: xr(nil), d: Decisions{Protected: []Decision{bucket}}},
+			want:   want{n: Notification{Composite: identity, Protections: []Decision{bucket}}, ok: true},
+		},
+		"ObservedUsage": {
+			reason: "A protection by a Usage that is already observed should not be notified",
+			args: args{
+				oxr:      xr(nil),
+				observed: map[resource.Name]resource.ObservedComposed{"bucket-usage": observedUsage},
+				d:        Decisions{Protected: []Decision{bucket}},
+			},
+			want: want{n: Notification{Composite: identity, Protections: []Decision{}}, ok: false},
+		},
+		"NewAnnotation": {
+			reason: "A protection by an annotation the observed composite does not carry yet should be notified",
+			args:   args{oxr: xr(nil), d: Decisions{Protected: []Decision{annotated}}},
+			want:   want{n: Notification{Composite: identity, Protections: []Decision{annotated}}, ok: true},
+		},
+		"ObservedAnnotation": {
+			reason: "A protection by an annotation the observed composite already carries should not be notified",
+			args:   args{oxr: xr(map[string]any{AnnotationProtected: ProtectionReasonCompositeChildResource}), d: Decisions{Protected: []Decision{annotated}}},
+			want:   want{n: Notification{Composite: identity, Protections: []Decision{}}, ok: false},
+		},
+		"OnlyNew": {
+			reason: "Only protections that are not observed yet should be notified",
+			args: args{
+				oxr:      xr(map[string]any{AnnotationProtected: ProtectionReasonCompositeChildResource}),
+				observed: map[resource.Name]resource.ObservedComposed{"bucket-usage": observedUsage},
+				d: Decisions{Protected: []Decision{
+					bucket,
+					annotated,
+					{APIVersion: "rds.aws.m.upbound.io/v1beta1", Kind: "Instance", Name: "my-db", Namespace: "default", Reason: ProtectionReasonLabel, Usage: "instance-my-db-1f3a2b-fn-protection"},
+				}},
+			},
+			want: want{n: Notification{Composite: identity, Protections: []Decision{
+				{APIVersion: "rds.aws.m.upbound.io/v1beta1", Kind: "Instance", Name: "my-db", Namespace: "default", Reason: ProtectionReasonLabel, Usage: "instance-my-db-1f3a2b-fn-protection"},
+			}}, ok: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n, ok := BuildNotification(tc.args.oxr, tc.args.observed, tc.args.d)
+			if diff := cmp.Diff(tc.want.n, n); diff != "" {
+				t.Errorf("%s\nBuildNotification(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nBuildNotification(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionNotifyOnProtect(t *testing.T) {
+	req := func(observedUsage bool) *fnv1.RunFunctionRequest {
+		r := &fnv1.RunFunctionRequest{
+			Input: resource.MustStructJSON(`{
+				"apiVersion": "protection.fn.crossplane.io/v1beta1",
+				"kind": "Input",
+				"notifyOnProtect": true
+			}`),
+			Observed: &fnv1.State{
+				Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestXR",
+					"metadata": {"name": "my-xr"}
+				}`)},
+				Resources: map[string]*fnv1.Resource{
+					"bucket": {Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestComposed",
+						"metadata": {
+							"name": "my-bucket",
+							"labels": {"protection.fn.crossplane.io/block-deletion": "true"}
+						}
+					}`)},
+				},
+			},
+			Desired: &fnv1.State{
+				Resources: map[string]*fnv1.Resource{
+					"bucket": {Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestComposed"
+					}`)},
+				},
+			},
+		}
+		if observedUsage {
+			r.Observed.Resources["bucket-usage"] = &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "protection.crossplane.io/v1beta1",
+				"kind": "Usage",
+				"metadata": {"name": "testcomposed-my-bucket-05156c-fn-protection"}
+			}`)}
+		}
+		return r
+	}
+
+	cases := map[string]struct {
+		reason string
+		req    *fnv1.RunFunctionRequest
+		want   *structpb.Value
+	}{
+		"NewProtections": {
+			reason: "Protections added by the run should be written to the notification context key",
+			req:    req(false),
+			want: structpb.NewStructValue(resource.MustStructJSON(`{
+				"composite": {
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestXR",
+					"name": "my-xr"
+				},
+				"protections": [
+					{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestComposed",
+						"name": "my-bucket",
+						"reason": "created by function-deletion-protection via label protection.fn.crossplane.io/block-deletion",
+						"usage": "testcomposed-my-bucket-05156c-fn-protection"
+					},
+					{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"name": "my-xr",
+						"reason": "created by function-deletion-protection because a composed resource is protected",
+						"usage": "testxr-my-xr-479e7a-fn-protection"
+					}
+				]
+			}`)),
+		},
+		"ExistingProtection": {
+			reason: "Protections that are already observed should not be notified again",
+			req:    req(true),
+			want: structpb.NewStructValue(resource.MustStructJSON(`{
+				"composite": {
+					"apiVersion": "test.crossplane.io/v1",
+					"kind": "TestXR",
+					"name": "my-xr"
+				},
+				"protections": [
+					{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"name": "my-xr",
+						"reason": "created by function-deletion-protection because a composed resource is protected",
+						"usage": "testxr-my-xr-479e7a-fn-protection"
+					}
+				]
+			}`)),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), tc.req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, rsp.GetContext().GetFields()[ContextKeyNotification], protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want notification, +got notification:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
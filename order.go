@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// AnnotationOrder is set on generated Usages to their position in UsageOrder
+// when AnnotateUsageOrder is enabled.
+const AnnotationOrder = "protection.fn.crossplane.io/order"
+
+// UsageOrder returns the keys of the supplied Usages in the order they are
+// generated in: Usages of resources other than the composite ordered by key,
+// followed by the Usages of the composite. The order only depends on the keys
+// and targets of the Usages, so it is stable across runs.
+func UsageOrder(usages map[resource.Name]*resource.DesiredComposed, xr *unstructured.Unstructured) []resource.Name {
+	names := make([]resource.Name, 0, len(usages))
+	var composite []resource.Name
+	for name, u := range usages {
+		if protectsComposite(u, xr) {
+			composite = append(composite, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	slices.Sort(composite)
+	return append(names, composite...)
+}
+
+// AnnotateUsageOrder sets AnnotationOrder on each of the supplied Usages to its
+// position in UsageOrder.
+func AnnotateUsageOrder(usages map[resource.Name]*resource.DesiredComposed, xr *unstructured.Unstructured) {
+	for i, name := range UsageOrder(usages, xr) {
+		meta.AddAnnotations(usages[name].Resource, map[string]string{AnnotationOrder: strconv.Itoa(i)})
+	}
+}
+
+// protectsComposite returns true if the Usage protects the supplied composite.
+func protectsComposite(u *resource.DesiredComposed, xr *unstructured.Unstructured) bool {
+	if u == nil || u.Resource == nil || xr == nil {
+		return false
+	}
+	str := func(path string) string {
+		v, _ := u.Resource.GetString(path)
+		return v
+	}
+	if str("spec.of.kind") != xr.GetKind() || u.Resource.GetNamespace() != xr.GetNamespace() {
+		return false
+	}
+	if name := str("spec.of.resourceRef.name"); name != "" {
+		return name == xr.GetName()
+	}
+	// A Usage may select the composite by its labels instead of its name.
+	matchLabels, _, _ := unstructured.NestedStringMap(u.Resource.Object, "spec", "of", "resourceSelector", "matchLabels")
+	if len(matchLabels) == 0 {
+		return false
+	}
+	for k, v := range matchLabels {
+		if xr.GetLabels()[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestUsageOrder(t *testing.T) {
+	xr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestXR",
+		"metadata":   map[string]any{"name": "my-xr", "labels": map[string]any{"team": "a"}},
+	}}
+	usage := func(kind, name string) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":  "test.crossplane.io/v1",
+					"kind":        kind,
+					"resourceRef": map[string]any{"name": name},
+				},
+			},
+		}}}}
+	}
+	selecting := func(kind string, matchLabels map[string]any) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":       "test.crossplane.io/v1",
+					"kind":             kind,
+					"resourceSelector": map[string]any{"matchLabels": matchLabels},
+				},
+			},
+		}}}}
+	}
+
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		order []resource.Name
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Empty": {
+			reason: "No Usages should result in an empty order",
+			args:   args{usages: map[resource.Name]*resource.DesiredComposed{}},
+			want:   want{order: []resource.Name{}},
+		},
+		"CompositeLast": {
+			reason: "The Usage of the composite should follow the Usages of all other resources",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"xr-my-xr-usage":  usage("TestXR", "my-xr"),
+				"db-usage":        usage("TestComposed", "my-db"),
+				"bucket-usage":    usage("TestComposed", "my-bucket"),
+				"vpc-usage":       usage("TestComposed", "my-vpc"),
+				"secret-my-usage": usage("Secret", "my-secret"),
+			}},
+			want: want{order: []resource.Name{"bucket-usage", "db-usage", "secret-my-usage", "vpc-usage", "xr-my-xr-usage"}},
+		},
+		"OtherComposite": {
+			reason: "The Usage of a resource of the composite's kind with another name should be ordered by key",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"a-usage":        usage("TestXR", "other-xr"),
+				"xr-my-xr-usage": usage("TestXR", "my-xr"),
+				"z-usage":        usage("TestComposed", "my-bucket"),
+			}},
+			want: want{order: []resource.Name{"a-usage", "z-usage", "xr-my-xr-usage"}},
+		},
+		"CompositeSelectedByLabels": {
+			reason: "A Usage selecting the composite by its labels should follow the Usages of all other resources",
+			args: args{usages: map[resource.Name]*resource.DesiredComposed{
+				"a-usage":        selecting("TestXR", map[string]any{"team": "b"}),
+				"xr-my-xr-usage": selecting("TestXR", map[string]any{"team": "a"}),
+				"z-usage":        usage("TestComposed", "my-bucket"),
+			}},
+			want: want{order: []resource.Name{"a-usage", "z-usage", "xr-my-xr-usage"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := UsageOrder(tc.args.usages, xr)
+
+			if diff := cmp.Diff(tc.want.order, got); diff != "" {
+				t.Errorf("%s\nUsageOrder(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			// Map iteration order is random, so the order must not depend on
+			// it.
+			for range 20 {
+				if diff := cmp.Diff(got, UsageOrder(tc.args.usages, xr)); diff != "" {
+					t.Fatalf("%s\nUsageOrder(...): order is not stable, -first, +later:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// AnnotationPaused is set on desired composed resources that this function
+// paused because they are protected, so they can be resumed once they are no
+// longer protected.
+const AnnotationPaused = "protection.fn.crossplane.io/paused"
+
+// PauseProtected pauses the desired composed resources protected by the
+// supplied composed usages if pause is true. Resources that were paused by this
+// function but are no longer protected, or no longer need to be paused, are
+// resumed. Resources that are already paused by an earlier pipeline step are
+// left untouched. It returns the number of resumed resources.
+func PauseProtected(desiredComposed map[resource.Name]*resource.DesiredComposed, observedComposed map[resource.Name]resource.ObservedComposed, composedUsages map[resource.Name]*resource.DesiredComposed, pause bool) int {
+	var resumed int
+	for name, d := range desiredComposed {
+		if d == nil || d.Resource == nil {
+			continue
+		}
+		_, protected := composedUsages[name+"-usage"]
+		switch {
+		case pause && protected:
+			if meta.IsPaused(d.Resource) && d.Resource.GetAnnotations()[AnnotationPaused] == "" {
+				continue
+			}
+			meta.AddAnnotations(d.Resource, map[string]string{
+				meta.AnnotationKeyReconciliationPaused: "true",
+				AnnotationPaused:                       "true",
+			})
+		case pausedByFunction(observedComposed[name]):
+			meta.RemoveAnnotations(d.Resource, meta.AnnotationKeyReconciliationPaused, AnnotationPaused)
+			resumed++
+		}
+	}
+	return resumed
+}
+
+// pausedByFunction returns true if the observed resource was paused by this
+// function.
+func pausedByFunction(o resource.ObservedComposed) bool {
+	return o.Resource != nil && o.Resource.GetAnnotations()[AnnotationPaused] == "true"
+}
+
+// RetainedUsages returns the supplied composed usages that are still part of
+// the final usages, which may have dropped or renamed some of them, keyed by
+// their original name.
+func RetainedUsages(composedUsages, usages map[resource.Name]*resource.DesiredComposed) map[resource.Name]*resource.DesiredComposed {
+	final := make(map[*resource.DesiredComposed]struct{}, len(usages))
+	for _, u := range usages {
+		final[u] = struct{}{}
+	}
+	retained := map[resource.Name]*resource.DesiredComposed{}
+	for name, u := range composedUsages {
+		if _, ok := final[u]; ok {
+			retained[name] = u
+		}
+	}
+	return retained
+}
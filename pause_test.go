@@ -0,0 +1,170 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestPauseProtected(t *testing.T) {
+	paused := map[string]string{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"}
+	desired := func(annotations map[string]any) map[resource.Name]*resource.DesiredComposed {
+		return map[resource.Name]*resource.DesiredComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"annotations": annotations},
+			}}}},
+		}
+	}
+	observed := func(annotations map[string]any) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"name": "my-bucket", "annotations": annotations},
+			}}}},
+		}
+	}
+	protected := map[resource.Name]*resource.DesiredComposed{"bucket-usage": {}}
+
+	type args struct {
+		desired  map[resource.Name]*resource.DesiredComposed
+		observed map[resource.Name]resource.ObservedComposed
+		usages   map[resource.Name]*resource.DesiredComposed
+		pause    bool
+	}
+	type want struct {
+		annotations map[string]string
+		resumed     int
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Pause": {
+			reason: "A protected resource should be paused",
+			args:   args{desired: desired(nil), observed: observed(nil), usages: protected, pause: true},
+			want:   want{annotations: paused},
+		},
+		"StillPaused": {
+			reason: "A resource paused by the function should stay paused while it is protected",
+			args:   args{desired: desired(nil), observed: observed(map[string]any{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"}), usages: protected, pause: true},
+			want:   want{annotations: paused},
+		},
+		"NotProtected": {
+			reason: "A resource that is not protected should not be paused",
+			args:   args{desired: desired(nil), observed: observed(nil), pause: true},
+			want:   want{},
+		},
+		"PauseDisabled": {
+			reason: "A protected resource should not be paused unless enabled",
+			args:   args{desired: desired(nil), observed: observed(nil), usages: protected},
+			want:   want{},
+		},
+		"PausedByEarlierStep": {
+			reason: "A resource paused by an earlier pipeline step should be left untouched",
+			args:   args{desired: desired(map[string]any{meta.AnnotationKeyReconciliationPaused: "true"}), observed: observed(nil), usages: protected, pause: true},
+			want:   want{annotations: map[string]string{meta.AnnotationKeyReconciliationPaused: "true"}},
+		},
+		"ResumeUnprotected": {
+			reason: "A resource paused by the function should be resumed once it is no longer protected",
+			args: args{
+				desired:  desired(map[string]any{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"}),
+				observed: observed(map[string]any{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"}),
+				pause:    true,
+			},
+			want: want{annotations: map[string]string{}, resumed: 1},
+		},
+		"ResumePauseDisabled": {
+			reason: "A resource paused by the function should be resumed once pausing is disabled",
+			args: args{
+				desired:  desired(nil),
+				observed: observed(map[string]any{meta.AnnotationKeyReconciliationPaused: "true", AnnotationPaused: "true"}),
+				usages:   protected,
+			},
+			want: want{resumed: 1},
+		},
+		"KeepPausedByOthers": {
+			reason: "A resource that was not paused by the function should not be resumed",
+			args: args{
+				desired:  desired(map[string]any{meta.AnnotationKeyReconciliationPaused: "true"}),
+				observed: observed(map[string]any{meta.AnnotationKeyReconciliationPaused: "true"}),
+			},
+			want: want{annotations: map[string]string{meta.AnnotationKeyReconciliationPaused: "true"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resumed := PauseProtected(tc.args.desired, tc.args.observed, tc.args.usages, tc.args.pause)
+
+			if diff := cmp.Diff(tc.want.annotations, tc.args.desired["bucket"].Resource.GetAnnotations()); diff != "" {
+				t.Errorf("%s\nPauseProtected(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.resumed, resumed); diff != "" {
+				t.Errorf("%s\nPauseProtected(...): -want resumed, +got resumed:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetainedUsages(t *testing.T) {
+	bucket := &resource.DesiredComposed{Resource: composed.New()}
+	db := &resource.DesiredComposed{Resource: composed.New()}
+	composedUsages := map[resource.Name]*resource.DesiredComposed{"bucket-usage": bucket, "db-usage": db}
+
+	type args struct {
+		composedUsages map[resource.Name]*resource.DesiredComposed
+		usages         map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		names []resource.Name
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"AllRetained": {
+			reason: "Composed usages that are part of the final usages should be retained",
+			args:   args{composedUsages: composedUsages, usages: map[resource.Name]*resource.DesiredComposed{"bucket-usage": bucket, "db-usage": db}},
+			want:   want{names: []resource.Name{"bucket-usage", "db-usage"}},
+		},
+		"Dropped": {
+			reason: "Composed usages that were dropped from the final usages should not be retained",
+			args:   args{composedUsages: composedUsages, usages: map[resource.Name]*resource.DesiredComposed{"bucket-usage": bucket}},
+			want:   want{names: []resource.Name{"bucket-usage"}},
+		},
+		"Renamed": {
+			reason: "Composed usages that were renamed should be retained under their original name",
+			args:   args{composedUsages: composedUsages, usages: map[resource.Name]*resource.DesiredComposed{"bucket-usage-fn-protection": bucket}},
+			want:   want{names: []resource.Name{"bucket-usage"}},
+		},
+		"NoneRetained": {
+			reason: "No composed usages should be retained if there are no final usages",
+			args:   args{composedUsages: composedUsages},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RetainedUsages(tc.args.composedUsages, tc.args.usages)
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(got))); diff != "" {
+				t.Errorf("%s\nRetainedUsages(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
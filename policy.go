@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+const (
+	// RequirementsNamePolicy is the name the policy ConfigMap is required as.
+	RequirementsNamePolicy = "protection.fn.crossplane.io/policy"
+	// PolicyDataKey is the ConfigMap data key that contains the policy.
+	PolicyDataKey = "policy"
+	// RequirementsNameProtectionPolicies is the name the selected
+	// ProtectionPolicies are required as.
+	RequirementsNameProtectionPolicies = "protection.fn.crossplane.io/protection-policies"
+)
+
+// PolicySelector returns a selector for the referenced policy ConfigMap.
+func PolicySelector(ref *v1beta1.ConfigMapReference) *fnv1.ResourceSelector {
+	return &fnv1.ResourceSelector{
+		ApiVersion: "v1",
+		Kind:       "ConfigMap",
+		Match:      &fnv1.ResourceSelector_MatchName{MatchName: ref.Name},
+		Namespace:  &ref.Namespace,
+	}
+}
+
+// MergePolicy merges the policy in the supplied ConfigMap into the Input.
+// Fields that are set in the Input take precedence over the policy. The
+// supplied inline Input is the Input as it was sent to the function, so that
+// fields explicitly set to false, 0 or "" inline also take precedence.
+func MergePolicy(in *v1beta1.Input, inline map[string]any, cm *unstructured.Unstructured) error {
+	data, _, _ := unstructured.NestedString(cm.Object, "data", PolicyDataKey)
+	if data == "" {
+		return nil
+	}
+	policy := &v1beta1.Input{}
+	if err := yaml.Unmarshal([]byte(data), policy); err != nil {
+		return errors.Wrapf(err, "cannot parse %q of policy ConfigMap %s/%s", PolicyDataKey, cm.GetNamespace(), cm.GetName())
+	}
+	// Zero values are omitted when the Input is marshalled, so the fields of
+	// the inline Input are applied again to override the policy with them.
+	current, err := json.Marshal(in)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal Function input")
+	}
+	if err := json.Unmarshal(current, policy); err != nil {
+		return errors.Wrap(err, "cannot merge Function input with policy")
+	}
+	if len(inline) > 0 {
+		set, err := json.Marshal(inline)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal inline Function input")
+		}
+		if err := json.Unmarshal(set, policy); err != nil {
+			return errors.Wrap(err, "cannot merge inline Function input with policy")
+		}
+	}
+	*in = *policy
+	return nil
+}
+
+// ProtectionPoliciesSelector returns a selector for the ProtectionPolicies
+// selected by the supplied selector.
+func ProtectionPoliciesSelector(sel *v1beta1.ProtectionPolicySelector) *fnv1.ResourceSelector {
+	return &fnv1.ResourceSelector{
+		ApiVersion: v1beta1.ProtectionPolicyAPIVersion,
+		Kind:       v1beta1.ProtectionPolicyKind,
+		Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: sel.MatchLabels}},
+	}
+}
+
+// MergeProtectionPolicies adds the rules and exclusions of the supplied
+// ProtectionPolicies to the Input. Policies are merged in order of their name,
+// so the result does not depend on the order they were supplied in.
+func MergeProtectionPolicies(in *v1beta1.Input, policies []resource.Required) error {
+	sorted := slices.Clone(policies)
+	slices.SortFunc(sorted, func(a, b resource.Required) int {
+		return strings.Compare(a.Resource.GetName(), b.Resource.GetName())
+	})
+	for _, r := range sorted {
+		p := &v1beta1.ProtectionPolicy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(r.Resource.Object, p); err != nil {
+			return errors.Wrapf(err, "cannot parse ProtectionPolicy %s", r.Resource.GetName())
+		}
+		in.ProtectionRules = append(in.ProtectionRules, p.Spec.Rules...)
+		in.ExcludeKinds = append(in.ExcludeKinds, p.Spec.ExcludeKinds...)
+	}
+	return nil
+}
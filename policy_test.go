@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+func TestMergePolicy(t *testing.T) {
+	type args struct {
+		in     *v1beta1.Input
+		inline map[string]any
+		cm     *unstructured.Unstructured
+	}
+	type want struct {
+		in  *v1beta1.Input
+		err string
+	}
+
+	cm := func(policy string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": "protection-policy", "namespace": "crossplane-system"},
+			"data":       map[string]any{PolicyDataKey: policy},
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPolicy": {
+			reason: "A ConfigMap without a policy should leave the Input unchanged",
+			args:   args{in: &v1beta1.Input{StrictTrueOnly: true}, cm: &unstructured.Unstructured{Object: map[string]any{"kind": "ConfigMap"}}},
+			want:   want{in: &v1beta1.Input{StrictTrueOnly: true}},
+		},
+		"PolicyApplied": {
+			reason: "Fields that are only set in the policy should be applied",
+			args: args{
+				in: &v1beta1.Input{StrictTrueOnly: true},
+				cm: cm("presenceOnlyLabels: [example.com/critical]\nprotectByOwnerKinds:\n- group: platform.example.com\n  kind: XDatabase\nreasonPrefix: \"[POLICY]\"\n"),
+			},
+			want: want{in: &v1beta1.Input{
+				StrictTrueOnly:      true,
+				PresenceOnlyLabels:  []string{"example.com/critical"},
+				ProtectByOwnerKinds: []v1beta1.GroupKind{{Group: "platform.example.com", Kind: "XDatabase"}},
+				ReasonPrefix:        "[POLICY]",
+			}},
+		},
+		"InlineWins": {
+			reason: "Fields set inline should take precedence over the policy",
+			args: args{
+				in: &v1beta1.Input{ReasonPrefix: "[INLINE]"},
+				cm: cm("reasonPrefix: \"[POLICY]\"\ndefaultProtect: true\n"),
+			},
+			want: want{in: &v1beta1.Input{ReasonPrefix: "[INLINE]", DefaultProtect: true}},
+		},
+		"InlineZeroValuesWin": {
+			reason: "Fields explicitly set to a zero value inline should take precedence over the policy",
+			args: args{
+				in:     &v1beta1.Input{},
+				inline: map[string]any{"defaultProtect": false, "reasonPrefix": "", "maxProtectedPerRun": 0},
+				cm:     cm("reasonPrefix: \"[POLICY]\"\ndefaultProtect: true\nmaxProtectedPerRun: 10\nstrictTrueOnly: true\n"),
+			},
+			want: want{in: &v1beta1.Input{StrictTrueOnly: true}},
+		},
+		"InvalidPolicy": {
+			reason: "A policy that cannot be parsed should return an error",
+			args:   args{in: &v1beta1.Input{}, cm: cm("defaultProtect: [")},
+			want:   want{in: &v1beta1.Input{}, err: `cannot parse "policy" of policy ConfigMap crossplane-system/protection-policy: error converting YAML to JSON: yaml: line 1: did not find expected node content`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := MergePolicy(tc.args.in, tc.args.inline, tc.args.cm)
+
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, got); diff != "" {
+				t.Errorf("%s\nMergePolicy(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.in, tc.args.in); diff != "" {
+				t.Errorf("%s\nMergePolicy(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionPolicyConfigMap(t *testing.T) {
+	type args struct {
+		required map[string]*fnv1.Resources
+	}
+	type want struct {
+		names []string
+	}
+
+	policy := &fnv1.Resources{Items: []*fnv1.Resource{{Resource: resource.MustStructJSON(`{
+		"apiVersion": "v1",
+		"kind": "ConfigMap",
+		"metadata": {"name": "protection-policy", "namespace": "crossplane-system"},
+		"data": {"policy": "defaultProtect: true"}
+	}`)}}}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"PolicyMissing": {
+			reason: "The inline Input should be used until the policy ConfigMap is supplied",
+			args:   args{},
+			want:   want{names: []string{"bucket"}},
+		},
+		"PolicySupplied": {
+			reason: "The policy ConfigMap should be merged with the inline Input",
+			args:   args{required: map[string]*fnv1.Resources{RequirementsNamePolicy: policy}},
+			want:   want{names: []string{"bucket", "bucket-usage", "xr-my-xr-usage"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"policyConfigMapRef": {"name": "protection-policy", "namespace": "crossplane-system"}
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed",
+							"metadata": {"name": "my-bucket"}
+						}`)},
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": {Resource: resource.MustStructJSON(`{
+							"apiVersion": "test.crossplane.io/v1",
+							"kind": "TestComposed"
+						}`)},
+					},
+				},
+				RequiredResources: tc.args.required,
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			ns := "crossplane-system"
+			wantReq := &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+				RequirementsNamePolicy: {
+					ApiVersion: "v1",
+					Kind:       "ConfigMap",
+					Match:      &fnv1.ResourceSelector_MatchName{MatchName: "protection-policy"},
+					Namespace:  &ns,
+				},
+			}}
+			if diff := cmp.Diff(wantReq, rsp.GetRequirements(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want requirements, +got requirements:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMergeProtectionPolicies(t *testing.T) {
+	type args struct {
+		in       *v1beta1.Input
+		policies []resource.Required
+	}
+	type want struct {
+		in  *v1beta1.Input
+		err string
+	}
+
+	policy := func(name string, spec map[string]any) resource.Required {
+		return resource.Required{Resource: &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": v1beta1.ProtectionPolicyAPIVersion,
+			"kind":       v1beta1.ProtectionPolicyKind,
+			"metadata":   map[string]any{"name": name},
+			"spec":       spec,
+		}}}
+	}
+	databases := policy("databases", map[string]any{
+		"rules": []any{map[string]any{
+			"kinds":  []any{map[string]any{"group": "rds.aws.upbound.io", "kind": "Instance"}},
+			"reason": "databases are protected by the platform team",
+		}},
+		"excludeKinds": []any{map[string]any{"group": "rds.aws.upbound.io", "kind": "Snapshot"}},
+	})
+	critical := policy("critical", map[string]any{
+		"rules": []any{map[string]any{"matchLabels": map[string]any{"tier": "critical"}}},
+	})
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoPolicies": {
+			reason: "The Input should be unchanged without policies",
+			args:   args{in: &v1beta1.Input{ProtectionRules: []v1beta1.ProtectionRule{{MatchLabels: map[string]string{"team": "a"}}}}},
+			want:   want{in: &v1beta1.Input{ProtectionRules: []v1beta1.ProtectionRule{{MatchLabels: map[string]string{"team": "a"}}}}},
+		},
+		"OnePolicy": {
+			reason: "The rules and exclusions of a policy should be added to the Input's",
+			args: args{
+				in:       &v1beta1.Input{ProtectionRules: []v1beta1.ProtectionRule{{MatchLabels: map[string]string{"team": "a"}}}},
+				policies: []resource.Required{databases},
+			},
+			want: want{in: &v1beta1.Input{
+				ProtectionRules: []v1beta1.ProtectionRule{
+					{MatchLabels: map[string]string{"team": "a"}},
+					{Kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}, Reason: "databases are protected by the platform team"},
+				},
+				ExcludeKinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Snapshot"}},
+			}},
+		},
+		"TwoPolicies": {
+			reason: "The rules and exclusions of all policies should be merged in order of their name",
+			args: args{
+				in:       &v1beta1.Input{},
+				policies: []resource.Required{databases, critical},
+			},
+			want: want{in: &v1beta1.Input{
+				ProtectionRules: []v1beta1.ProtectionRule{
+					{MatchLabels: map[string]string{"tier": "critical"}},
+					{Kinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Instance"}}, Reason: "databases are protected by the platform team"},
+				},
+				ExcludeKinds: []v1beta1.GroupKind{{Group: "rds.aws.upbound.io", Kind: "Snapshot"}},
+			}},
+		},
+		"InvalidPolicy": {
+			reason: "A policy that cannot be parsed should return an error",
+			args: args{
+				in:       &v1beta1.Input{},
+				policies: []resource.Required{policy("broken", map[string]any{"rules": "everything"})},
+			},
+			want: want{in: &v1beta1.Input{}, err: "cannot parse ProtectionPolicy broken: cannot restore slice from string"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := MergeProtectionPolicies(tc.args.in, tc.args.policies)
+
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, got); diff != "" {
+				t.Errorf("%s\nMergeProtectionPolicies(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.in, tc.args.in); diff != "" {
+				t.Errorf("%s\nMergeProtectionPolicies(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunFunctionProtectionPolicies(t *testing.T) {
+	type args struct {
+		required map[string]*fnv1.Resources
+	}
+	type want struct {
+		names []string
+	}
+
+	databases := &fnv1.Resource{Resource: resource.MustStructJSON(`{
+		"apiVersion": "protection.fn.crossplane.io/v1beta1",
+		"kind": "ProtectionPolicy",
+		"metadata": {"name": "databases"},
+		"spec": {"rules": [{"kinds": [{"group": "rds.aws.upbound.io", "kind": "Instance"}]}]}
+	}`)}
+	critical := &fnv1.Resource{Resource: resource.MustStructJSON(`{
+		"apiVersion": "protection.fn.crossplane.io/v1beta1",
+		"kind": "ProtectionPolicy",
+		"metadata": {"name": "critical"},
+		"spec": {
+			"rules": [{"matchLabels": {"tier": "critical"}}],
+			"excludeKinds": [{"group": "s3.aws.upbound.io", "kind": "BucketPolicy"}]
+		}
+	}`)}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"PoliciesMissing": {
+			reason: "Nothing should be protected until the policies are supplied",
+			args:   args{},
+			want:   want{names: []string{"bucket", "db", "policy"}},
+		},
+		"OnePolicy": {
+			reason: "Resources matching the rules of a single policy should be protected",
+			args:   args{required: map[string]*fnv1.Resources{RequirementsNameProtectionPolicies: {Items: []*fnv1.Resource{databases}}}},
+			want:   want{names: []string{"bucket", "db", "db-usage", "policy", "xr-my-xr-usage"}},
+		},
+		"TwoPolicies": {
+			reason: "Resources matching the rules of any policy should be protected, unless a policy excludes their kind",
+			args:   args{required: map[string]*fnv1.Resources{RequirementsNameProtectionPolicies: {Items: []*fnv1.Resource{databases, critical}}}},
+			want:   want{names: []string{"bucket", "bucket-usage", "db", "db-usage", "policy", "xr-my-xr-usage"}},
+		},
+	}
+
+	composed := func(apiVersion, kind, name, labels string) *fnv1.Resource {
+		return &fnv1.Resource{Resource: resource.MustStructJSON(`{
+			"apiVersion": "` + apiVersion + `",
+			"kind": "` + kind + `",
+			"metadata": {"name": "` + name + `", "labels": ` + labels + `}
+		}`)}
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{
+				Input: resource.MustStructJSON(`{
+					"apiVersion": "protection.fn.crossplane.io/v1beta1",
+					"kind": "Input",
+					"protectionPolicySelector": {"matchLabels": {"platform.example.org/policy": "true"}}
+				}`),
+				Observed: &fnv1.State{
+					Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+						"apiVersion": "test.crossplane.io/v1",
+						"kind": "TestXR",
+						"metadata": {"name": "my-xr"}
+					}`)},
+					Resources: map[string]*fnv1.Resource{
+						"bucket": composed("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", `{"tier": "critical"}`),
+						"db":     composed("rds.aws.upbound.io/v1beta1", "Instance", "my-db", `{}`),
+						"policy": composed("s3.aws.upbound.io/v1beta1", "BucketPolicy", "my-policy", `{"tier": "critical"}`),
+					},
+				},
+				Desired: &fnv1.State{
+					Resources: map[string]*fnv1.Resource{
+						"bucket": composed("s3.aws.upbound.io/v1beta1", "Bucket", "my-bucket", `{}`),
+						"db":     composed("rds.aws.upbound.io/v1beta1", "Instance", "my-db", `{}`),
+						"policy": composed("s3.aws.upbound.io/v1beta1", "BucketPolicy", "my-policy", `{}`),
+					},
+				},
+				RequiredResources: tc.args.required,
+			}
+
+			f := &Function{log: logging.NewNopLogger()}
+			rsp, err := f.RunFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s\nf.RunFunction(...): unexpected error: %v", tc.reason, err)
+			}
+
+			wantReq := &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+				RequirementsNameProtectionPolicies: {
+					ApiVersion: "protection.fn.crossplane.io/v1beta1",
+					Kind:       "ProtectionPolicy",
+					Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: map[string]string{"platform.example.org/policy": "true"}}},
+				},
+			}}
+			if diff := cmp.Diff(wantReq, rsp.GetRequirements(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want requirements, +got requirements:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.names, slices.Sorted(maps.Keys(rsp.GetDesired().GetResources()))); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AnnotationProtectedDays lists the weekdays on which a resource is protected,
+// e.g. "Mon,Tue,Wed,Thu,Fri".
+const AnnotationProtectedDays = "protection.fn.crossplane.io/days"
+
+// ProtectedOn returns true if the supplied time falls on one of the weekdays
+// listed in the resource's AnnotationProtectedDays annotation. Resources
+// without the annotation, or without any recognized weekday, are always
+// protected.
+func ProtectedOn(u *unstructured.Unstructured, now time.Time) bool {
+	if u == nil || u.Object == nil {
+		return true
+	}
+	days, ok := u.GetAnnotations()[AnnotationProtectedDays]
+	if !ok {
+		return true
+	}
+	var scheduled bool
+	for _, d := range strings.Split(days, ",") {
+		day, ok := parseWeekday(d)
+		if !ok {
+			continue
+		}
+		if day == now.Weekday() {
+			return true
+		}
+		scheduled = true
+	}
+	return !scheduled
+}
+
+// parseWeekday parses a weekday name, e.g. "Mon" or "Monday", ignoring case.
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 {
+		return 0, false
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// scheduleSource returns the desired resource if it has a protection schedule,
+// falling back to the observed resource.
+func scheduleSource(desired, observed *unstructured.Unstructured) *unstructured.Unstructured {
+	if desired != nil && desired.Object != nil {
+		if _, ok := desired.GetAnnotations()[AnnotationProtectedDays]; ok {
+			return desired
+		}
+	}
+	return observed
+}
+
+// ReadySince returns the time the resource's Ready condition last became
+// True. It returns false if the resource is not Ready or the transition time is
+// unknown.
+func ReadySince(u *unstructured.Unstructured) (time.Time, bool) {
+	if u == nil || u.Object == nil {
+		return time.Time{}, false
+	}
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]any)
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+		if cond["status"] != "True" {
+			return time.Time{}, false
+		}
+		ts, _ := cond["lastTransitionTime"].(string)
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+var (
+	monday   = time.Date(2026, time.October, 12, 9, 0, 0, 0, time.UTC)
+	saturday = time.Date(2026, time.October, 17, 9, 0, 0, 0, time.UTC)
+)
+
+func TestProtectedOn(t *testing.T) {
+	withDays := func(days string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{
+				"annotations": map[string]any{AnnotationProtectedDays: days},
+			},
+		}}
+	}
+
+	type args struct {
+		u   *unstructured.Unstructured
+		now time.Time
+	}
+	type want struct {
+		protected bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoAnnotation": {
+			reason: "Resources without a schedule should always be protected",
+			args:   args{u: &unstructured.Unstructured{Object: map[string]any{}}, now: saturday},
+			want:   want{protected: true},
+		},
+		"InSchedule": {
+			reason: "A resource should be protected on a scheduled day",
+			args:   args{u: withDays("Mon,Tue,Wed,Thu,Fri"), now: monday},
+			want:   want{protected: true},
+		},
+		"OutOfSchedule": {
+			reason: "A resource should not be protected on an unscheduled day",
+			args:   args{u: withDays("Mon,Tue,Wed,Thu,Fri"), now: saturday},
+			want:   want{protected: false},
+		},
+		"FullNamesAndWhitespace": {
+			reason: "Full weekday names should be matched ignoring case and whitespace",
+			args:   args{u: withDays("monday, Saturday"), now: saturday},
+			want:   want{protected: true},
+		},
+		"NoRecognizedDays": {
+			reason: "A schedule without any recognized weekday should always protect",
+			args:   args{u: withDays("weekdays"), now: saturday},
+			want:   want{protected: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ProtectedOn(tc.args.u, tc.args.now)
+
+			if diff := cmp.Diff(tc.want.protected, got); diff != "" {
+				t.Errorf("%s\nProtectedOn(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectComposedResourcesSchedule(t *testing.T) {
+	desired := map[resource.Name]*resource.DesiredComposed{
+		"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata": map[string]any{
+				"labels":      map[string]any{ProtectionLabelBlockDeletion: "true"},
+				"annotations": map[string]any{AnnotationProtectedDays: "Mon,Tue,Wed,Thu,Fri"},
+			},
+		}}}},
+		"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata": map[string]any{
+				"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+			},
+		}}}},
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"bucket": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-bucket"},
+		}}}},
+		"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata":   map[string]any{"name": "my-db"},
+		}}}},
+	}
+
+	type args struct {
+		now time.Time
+	}
+	type want struct {
+		usages []resource.Name
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"InSchedule": {
+			reason: "A scheduled resource should be protected on a scheduled day",
+			args:   args{now: monday},
+			want:   want{usages: []resource.Name{"bucket-usage", "db-usage"}},
+		},
+		"OutOfSchedule": {
+			reason: "A scheduled resource should not be protected on an unscheduled day, unscheduled resources should",
+			args:   args{now: saturday},
+			want:   want{usages: []resource.Name{"db-usage"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return tc.args.now }}
+			dc, _, err := f.ProtectComposedResources(context.Background(), nil, desired, observed, &v1beta1.Input{}, Matchers{}, nil)
+			if err != nil {
+				t.Fatalf("%s\nf.ProtectComposedResources(...): unexpected error: %v", tc.reason, err)
+			}
+
+			got := slices.Sorted(maps.Keys(dc))
+			if diff := cmp.Diff(tc.want.usages, got); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want usages, +got usages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReadySince(t *testing.T) {
+	type args struct {
+		u *unstructured.Unstructured
+	}
+	type want struct {
+		since time.Time
+		ready bool
+	}
+
+	withReady := func(status, transition string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{
+				"conditions": []any{
+					map[string]any{"type": "Synced", "status": "True", "lastTransitionTime": "2026-10-01T00:00:00Z"},
+					map[string]any{"type": "Ready", "status": status, "lastTransitionTime": transition},
+				},
+			},
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoConditions": {
+			reason: "A resource without conditions should not be ready",
+			args:   args{u: &unstructured.Unstructured{Object: map[string]any{}}},
+			want:   want{},
+		},
+		"Ready": {
+			reason: "The Ready transition time should be returned",
+			args:   args{u: withReady("True", "2026-10-12T08:00:00Z")},
+			want:   want{since: time.Date(2026, time.October, 12, 8, 0, 0, 0, time.UTC), ready: true},
+		},
+		"NotReady": {
+			reason: "A resource whose Ready condition is not True should not be ready",
+			args:   args{u: withReady("False", "2026-10-12T08:00:00Z")},
+			want:   want{},
+		},
+		"InvalidTransitionTime": {
+			reason: "A resource with an unknown transition time should not be ready",
+			args:   args{u: withReady("True", "yesterday")},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			since, ready := ReadySince(tc.args.u)
+
+			if diff := cmp.Diff(tc.want.since, since); diff != "" {
+				t.Errorf("%s\nReadySince(...): -want since, +got since:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ready, ready); diff != "" {
+				t.Errorf("%s\nReadySince(...): -want ready, +got ready:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectComposedResourcesMinReadyDuration(t *testing.T) {
+	desired := map[resource.Name]*resource.DesiredComposed{
+		"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.crossplane.io/v1",
+			"kind":       "TestComposed",
+			"metadata": map[string]any{
+				"labels": map[string]any{ProtectionLabelBlockDeletion: "true"},
+			},
+		}}}},
+	}
+	observed := func(transition string) map[resource.Name]resource.ObservedComposed {
+		return map[resource.Name]resource.ObservedComposed{
+			"db": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"name": "my-db"},
+				"status": map[string]any{
+					"conditions": []any{
+						map[string]any{"type": "Ready", "status": "True", "lastTransitionTime": transition},
+					},
+				},
+			}}}},
+		}
+	}
+
+	type args struct {
+		observed map[resource.Name]resource.ObservedComposed
+	}
+	type want struct {
+		usages  []resource.Name
+		skipped []SkippedResource
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ReadyLongEnough": {
+			reason: "A resource that has been ready long enough should be protected",
+			args:   args{observed: observed("2026-10-12T08:00:00Z")},
+			want:   want{usages: []resource.Name{"db-usage"}},
+		},
+		"RecentlyReady": {
+			reason: "A resource that only recently became ready should be skipped",
+			args:   args{observed: observed("2026-10-12T08:59:00Z")},
+			want: want{
+				usages:  nil,
+				skipped: []SkippedResource{{Name: "db", Reason: SkipReasonNotReadyLongEnough}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &Function{log: logging.NewNopLogger(), clock: func() time.Time { return monday }}
+			dc, skipped, err := f.ProtectComposedResources(context.Background(), nil, desired, tc.args.observed, &v1beta1.Input{MinReadyDurationSeconds: 600}, Matchers{}, nil)
+			if err != nil {
+				t.Fatalf("%s\nf.ProtectComposedResources(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.usages, slices.Sorted(maps.Keys(dc))); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want usages, +got usages:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.skipped, skipped); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want skipped, +got skipped:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"regexp"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+// AnnotationSubresource is set on Usages generated for the SubresourceReasons
+// to the subresource they document.
+const AnnotationSubresource = "protection.fn.crossplane.io/subresource"
+
+// SubresourceUsages returns an additional Usage per entry of the Input's
+// SubresourceReasons for each of the supplied Usages. Each is a copy of the
+// Usage it is derived from, with the entry's value as its reason. The supplied
+// redactions are the compiled RedactReasonPatterns of the Input.
+func SubresourceUsages(usages map[resource.Name]*resource.DesiredComposed, in *v1beta1.Input, redact []*regexp.Regexp) map[resource.Name]*resource.DesiredComposed {
+	sub := map[resource.Name]*resource.DesiredComposed{}
+	if len(in.SubresourceReasons) == 0 {
+		return sub
+	}
+	for name, u := range usages {
+		for key, reason := range in.SubresourceReasons {
+			s := asComposed(u.Resource.DeepCopy().Object)
+			meta.AddAnnotations(s, map[string]string{AnnotationSubresource: key})
+			_ = unstructured.SetNestedField(s.Object, reason, "spec", "reason")
+			ApplyUsageOptions(s.Object, in, redact)
+			s.SetName(usageName(s.Object, key, in.NamingScheme))
+			sub[name+"-"+resource.Name(key)] = &resource.DesiredComposed{Resource: s}
+		}
+	}
+	return sub
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+func TestSubresourceUsages(t *testing.T) {
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+		in     *v1beta1.Input
+	}
+	type want struct {
+		usages map[resource.Name]map[string]any
+	}
+
+	bucket := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "s3.aws.upbound.io/v1beta1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"name": name},
+		}}
+	}
+	usages := func(names ...string) map[resource.Name]*resource.DesiredComposed {
+		u := map[resource.Name]*resource.DesiredComposed{}
+		for _, n := range names {
+			u[resource.Name(n+"-usage")] = &resource.DesiredComposed{Resource: asComposed(GenerateUsage(bucket(n), ProtectionReasonLabel, &v1beta1.Input{}, nil))}
+		}
+		return u
+	}
+	subresource := func(name, usage, key, reason string) map[string]any {
+		return map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata": map[string]any{
+				"name":        usage,
+				"labels":      map[string]any{LabelManagedBy: ManagedByValue},
+				"annotations": map[string]any{AnnotationSubresource: key},
+			},
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion":  "s3.aws.upbound.io/v1beta1",
+					"kind":        "Bucket",
+					"resourceRef": map[string]any{"name": name},
+				},
+				"reason": reason,
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoSubresources": {
+			reason: "No Usages should be generated without subresourceReasons",
+			args:   args{usages: usages("a"), in: &v1beta1.Input{}},
+			want:   want{usages: map[resource.Name]map[string]any{}},
+		},
+		"MultipleSubresources": {
+			reason: "A uniquely named Usage should be generated per subresource and protected resource",
+			args: args{usages: usages("a", "b"), in: &v1beta1.Input{SubresourceReasons: map[string]string{
+				"storage": "holds customer data",
+				"backups": "holds point-in-time backups",
+			}}},
+			want: want{usages: map[resource.Name]map[string]any{
+				"a-usage-storage": subresource("a", "bucket-a-storage-dbfd8c-fn-protection", "storage", "holds customer data"),
+				"a-usage-backups": subresource("a", "bucket-a-backups-556504-fn-protection", "backups", "holds point-in-time backups"),
+				"b-usage-storage": subresource("b", "bucket-b-storage-75c7c7-fn-protection", "storage", "holds customer data"),
+				"b-usage-backups": subresource("b", "bucket-b-backups-ca4326-fn-protection", "backups", "holds point-in-time backups"),
+			}},
+		},
+		"DecoratedReason": {
+			reason: "Subresource reasons should be decorated like other reasons",
+			args: args{usages: usages("a"), in: &v1beta1.Input{
+				SubresourceReasons: map[string]string{"storage": "holds customer data"},
+				ReasonPrefix:       "[PROD]",
+			}},
+			want: want{usages: map[resource.Name]map[string]any{
+				"a-usage-storage": subresource("a", "bucket-a-storage-dbfd8c-fn-protection", "storage", "[PROD] holds customer data"),
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := SubresourceUsages(tc.args.usages, tc.args.in, nil)
+
+			objs := map[resource.Name]map[string]any{}
+			for n, u := range got {
+				objs[n] = u.Resource.Object
+			}
+			if diff := cmp.Diff(tc.want.usages, objs); diff != "" {
+				t.Errorf("%s\nSubresourceUsages(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+)
+
+const (
+	// LabelTracking is set on tracking ConfigMaps created in the
+	// TrackingNamespace.
+	LabelTracking = "protection.fn.crossplane.io/tracking"
+	// TrackingNameSuffix is the suffix applied when generating tracking
+	// ConfigMap names.
+	TrackingNameSuffix = "fn-tracking"
+)
+
+// GenerateTrackingConfigMap creates a ConfigMap in the supplied namespace that
+// records a ClusterUsage and the resource it protects.
+func GenerateTrackingConfigMap(usage *unstructured.Unstructured, namespace string) map[string]any {
+	str := func(fields ...string) string {
+		v, _, _ := unstructured.NestedString(usage.Object, fields...)
+		return v
+	}
+
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":      GenerateName(usage.GetName(), TrackingNameSuffix),
+			"namespace": namespace,
+			"labels": map[string]any{
+				LabelTracking:  "true",
+				LabelManagedBy: ManagedByValue,
+			},
+		},
+		"data": map[string]any{
+			"usageAPIVersion":    usage.GetAPIVersion(),
+			"usageKind":          usage.GetKind(),
+			"usageName":          usage.GetName(),
+			"resourceAPIVersion": str("spec", "of", "apiVersion"),
+			"resourceKind":       str("spec", "of", "kind"),
+			"resourceName":       str("spec", "of", "resourceRef", "name"),
+			"reason":             str("spec", "reason"),
+		},
+	}
+}
+
+// TrackClusterUsages returns a tracking ConfigMap in the supplied namespace for
+// every ClusterUsage in usages. Nothing is returned if namespace is empty.
+func TrackClusterUsages(usages map[resource.Name]*resource.DesiredComposed, namespace string) (map[resource.Name]*resource.DesiredComposed, error) {
+	tracking := map[resource.Name]*resource.DesiredComposed{}
+	if namespace == "" {
+		return tracking, nil
+	}
+	for name, u := range usages {
+		if u == nil || u.Resource == nil || u.Resource.GetKind() != protectionv1beta1.ClusterUsageKind {
+			continue
+		}
+		cm := asComposed(GenerateTrackingConfigMap(&u.Resource.Unstructured, namespace))
+		tracking[name+"-tracking"] = &resource.DesiredComposed{Resource: cm}
+	}
+	return tracking, nil
+}
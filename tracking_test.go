@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestTrackClusterUsages(t *testing.T) {
+	usage := func(kind, namespace string) *resource.DesiredComposed {
+		meta := map[string]any{"name": "bucket-my-bucket-018c9b-fn-protection"}
+		if namespace != "" {
+			meta["namespace"] = namespace
+		}
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       kind,
+			"metadata":   meta,
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion": "s3.aws.upbound.io/v1beta1",
+					"kind":       "Bucket",
+					"resourceRef": map[string]any{
+						"name": "my-bucket",
+					},
+				},
+				"reason": ProtectionReasonLabel,
+			},
+		}}}}
+	}
+
+	type args struct {
+		usages    map[resource.Name]*resource.DesiredComposed
+		namespace string
+	}
+	type want struct {
+		tracking map[resource.Name]*resource.DesiredComposed
+		err      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoNamespace": {
+			reason: "No tracking ConfigMaps should be generated without a tracking namespace",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{"bucket-usage": usage("ClusterUsage", "")},
+			},
+			want: want{tracking: map[resource.Name]*resource.DesiredComposed{}},
+		},
+		"ClusterUsage": {
+			reason: "A ClusterUsage should be tracked by a ConfigMap in the tracking namespace",
+			args: args{
+				usages:    map[resource.Name]*resource.DesiredComposed{"bucket-usage": usage("ClusterUsage", "")},
+				namespace: "governance",
+			},
+			want: want{tracking: map[resource.Name]*resource.DesiredComposed{
+				"bucket-usage-tracking": {Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]any{
+						"name":      GenerateName("bucket-my-bucket-018c9b-fn-protection", TrackingNameSuffix),
+						"namespace": "governance",
+						"labels": map[string]any{
+							LabelTracking:  "true",
+							LabelManagedBy: ManagedByValue,
+						},
+					},
+					"data": map[string]any{
+						"usageAPIVersion":    ProtectionGroupVersion,
+						"usageKind":          "ClusterUsage",
+						"usageName":          "bucket-my-bucket-018c9b-fn-protection",
+						"resourceAPIVersion": "s3.aws.upbound.io/v1beta1",
+						"resourceKind":       "Bucket",
+						"resourceName":       "my-bucket",
+						"reason":             ProtectionReasonLabel,
+					},
+				}}}},
+			}},
+		},
+		"NamespacedUsage": {
+			reason: "A namespaced Usage should not be tracked",
+			args: args{
+				usages:    map[resource.Name]*resource.DesiredComposed{"bucket-usage": usage("Usage", "default")},
+				namespace: "governance",
+			},
+			want: want{tracking: map[resource.Name]*resource.DesiredComposed{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := TrackClusterUsages(tc.args.usages, tc.args.namespace)
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nTrackClusterUsages(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.tracking, got); diff != "" {
+				t.Errorf("%s\nTrackClusterUsages(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+// Trigger is the kind of trigger that caused a resource to be protected.
+type Trigger string
+
+// Triggers that cause a resource to be protected.
+const (
+	TriggerLabel     Trigger = "label"
+	TriggerPolicy    Trigger = "policy"
+	TriggerKindMatch Trigger = "kind-match"
+	TriggerReference Trigger = "reference"
+	TriggerChild     Trigger = "child"
+	TriggerOperation Trigger = "operation"
+	TriggerSelector  Trigger = "selector"
+)
+
+// TriggerReasonPrefix prefixes reasons that name the trigger that caused
+// protection.
+const TriggerReasonPrefix = "Protected due to: "
+
+// triggeredBy maps the reasons generated by this function to the trigger that
+// caused protection and the setting behind it.
+var triggeredBy = map[string]struct {
+	trigger Trigger
+	detail  string
+}{
+	ProtectionReasonLabel:                  {TriggerLabel, ProtectionLabelBlockDeletion},
+	ProtectionReasonLabelValue:             {TriggerLabel, "matchLabelEquals"},
+	ProtectionReasonAllSelectors:           {TriggerLabel, "requireAllSelectors"},
+	ProtectionReasonSharedGroup:            {TriggerLabel, "sharedProtectionGroupLabel"},
+	ProtectionReasonConnectionSource:       {TriggerLabel, LabelConnectionSource},
+	ProtectionReasonDefault:                {TriggerPolicy, "defaultProtect"},
+	ProtectionReasonOwnerKind:              {TriggerKindMatch, "protectByOwnerKinds"},
+	ProtectionReasonProvider:               {TriggerKindMatch, "protectProviders"},
+	ProtectionReasonExpression:             {TriggerPolicy, "protectWhen"},
+	ProtectionReasonStatus:                 {TriggerPolicy, "protectIfStatusPath"},
+	ProtectionReasonCondition:              {TriggerPolicy, "protectIfConditionTrue"},
+	ProtectionReasonSample:                 {TriggerPolicy, "sampleProtectPercent"},
+	ProtectionReasonExternalName:           {TriggerPolicy, "protectExternalNameRegex"},
+	ProtectionReasonNewest:                 {TriggerPolicy, "protectNewestN"},
+	ProtectionReasonControllerRef:          {TriggerPolicy, "protectByControllerRef"},
+	ProtectionReasonConnectionSecret:       {TriggerPolicy, "protectIfConnectionSecret"},
+	ProtectionReasonFieldMatch:             {TriggerPolicy, "protectIfFieldMatches"},
+	ProtectionReasonAnnotationRegex:        {TriggerPolicy, "matchAnnotationRegex"},
+	ProtectionReasonPriority:               {TriggerPolicy, "protectIfPriorityAtLeast"},
+	ProtectionReasonNamespaceGroup:         {TriggerPolicy, "protectNamespaceAsGroup"},
+	ProtectionReasonSpec:                   {TriggerPolicy, "protectionSpecPath"},
+	ProtectionReasonRule:                   {TriggerPolicy, "protectionRules"},
+	ProtectionReasonWebhook:                {TriggerPolicy, "decisionWebhookURL"},
+	ProtectionReasonReferenced:             {TriggerReference, "refPaths"},
+	ProtectionReasonCrossComposition:       {TriggerReference, "crossCompositionRefs"},
+	ProtectionReasonCompositeChildResource: {TriggerChild, "protected composed resource"},
+	ProtectionReasonRequiredSelector:       {TriggerSelector, "requiredSelectors"},
+	ProtectionReasonOperation:              {TriggerOperation, "Operation"},
+	ProtectionReasonWatchOperation:         {TriggerOperation, "WatchOperation"},
+}
+
+// TriggerReason returns a reason naming the trigger behind one of the reasons
+// generated by this function, e.g. "Protected due to: policy(protectWhen)".
+func TriggerReason(reason string) (string, bool) {
+	t, ok := triggeredBy[reason]
+	if !ok {
+		return reason, false
+	}
+	return TriggerReasonPrefix + string(t.trigger) + "(" + t.detail + ")", true
+}
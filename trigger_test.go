@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTriggerReason(t *testing.T) {
+	type args struct {
+		reason string
+	}
+	type want struct {
+		reason string
+		ok     bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Label": {
+			reason: "The label reason should name the label trigger",
+			args:   args{reason: ProtectionReasonLabel},
+			want:   want{reason: "Protected due to: label(" + ProtectionLabelBlockDeletion + ")", ok: true},
+		},
+		"Policy": {
+			reason: "The default protection reason should name the policy trigger",
+			args:   args{reason: ProtectionReasonDefault},
+			want:   want{reason: "Protected due to: policy(defaultProtect)", ok: true},
+		},
+		"KindMatch": {
+			reason: "The owner kind reason should name the kind-match trigger",
+			args:   args{reason: ProtectionReasonOwnerKind},
+			want:   want{reason: "Protected due to: kind-match(protectByOwnerKinds)", ok: true},
+		},
+		"Child": {
+			reason: "The composite reason should name the child trigger",
+			args:   args{reason: ProtectionReasonCompositeChildResource},
+			want:   want{reason: "Protected due to: child(protected composed resource)", ok: true},
+		},
+		"Unknown": {
+			reason: "A reason not generated by the function should be returned unchanged",
+			args:   args{reason: "protected by the platform team"},
+			want:   want{reason: "protected by the platform team", ok: false},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := TriggerReason(tc.args.reason)
+
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("%s\nTriggerReason(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("%s\nTriggerReason(...): -want ok, +got ok:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	protectionv1beta1 "github.com/crossplane/crossplane/v2/apis/protection/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+// conditionReasonPattern matches valid Kubernetes condition reasons.
+var conditionReasonPattern = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+
+// ValidateInput checks that the Function's Input is well-formed.
+func ValidateInput(in *v1beta1.Input) error {
+	for _, p := range in.RedactReasonPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return errors.Wrapf(err, "invalid redactReasonPatterns entry %q", p)
+		}
+	}
+	if in.ProtectExternalNameRegex != "" {
+		if _, err := regexp.Compile(in.ProtectExternalNameRegex); err != nil {
+			return errors.Wrap(err, "invalid protectExternalNameRegex")
+		}
+	}
+	for _, m := range in.ProtectIfFieldMatches {
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return errors.Wrapf(err, "invalid protectIfFieldMatches regex for %q", m.FieldPath)
+		}
+	}
+	if _, err := CompileAnnotationRegex(in.MatchAnnotationRegex); err != nil {
+		return err
+	}
+	for i, s := range in.RequireAllSelectors {
+		if len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0 {
+			return errors.Errorf("requireAllSelectors entry %d must have matchLabels or matchExpressions", i)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(&s); err != nil {
+			return errors.Wrapf(err, "invalid requireAllSelectors entry %d", i)
+		}
+	}
+	for i, ref := range in.CrossCompositionRefs {
+		if ref.FieldPath == "" || ref.APIVersion == "" || ref.Kind == "" {
+			return errors.Errorf("crossCompositionRefs entry %d must have fieldPath, apiVersion and kind", i)
+		}
+		if ref.IdentifyBy == v1beta1.CrossCompositionIdentityExternalName && len(ref.MatchLabels) == 0 {
+			return errors.Errorf("crossCompositionRefs entry %d must have matchLabels to identify resources by external name", i)
+		}
+	}
+	for _, d := range in.ProtectProviders {
+		if errs := validation.IsDNS1123Subdomain(d); len(errs) > 0 {
+			return errors.Errorf("invalid protectProviders entry %q: %s", d, strings.Join(errs, "; "))
+		}
+	}
+	for i, r := range in.ProtectionRules {
+		if len(r.Kinds) == 0 && len(r.MatchLabels) == 0 {
+			return errors.Errorf("protectionRules entry %d must have kinds or matchLabels", i)
+		}
+	}
+	if in.ProtectionTTL != "" {
+		d, err := time.ParseDuration(in.ProtectionTTL)
+		if err != nil {
+			return errors.Wrap(err, "invalid protectionTTL")
+		}
+		if d <= 0 {
+			return errors.Errorf("invalid protectionTTL %q: must be positive", in.ProtectionTTL)
+		}
+	}
+	if in.DecisionWebhookURL != "" {
+		u, err := url.Parse(in.DecisionWebhookURL)
+		if err != nil {
+			return errors.Wrap(err, "invalid decisionWebhookURL")
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf("invalid decisionWebhookURL %q: must be an absolute http or https URL", in.DecisionWebhookURL)
+		}
+	}
+	if in.DecisionWebhookTimeout != "" {
+		d, err := time.ParseDuration(in.DecisionWebhookTimeout)
+		if err != nil {
+			return errors.Wrap(err, "invalid decisionWebhookTimeout")
+		}
+		if d <= 0 {
+			return errors.Errorf("invalid decisionWebhookTimeout %q: must be positive", in.DecisionWebhookTimeout)
+		}
+	}
+	if gv := in.UsageGroupVersion; gv != "" {
+		parsed, err := schema.ParseGroupVersion(gv)
+		if err != nil || parsed.Group == "" || parsed.Version == "" {
+			return errors.Errorf("invalid usageGroupVersion %q: must be of the form group/version", gv)
+		}
+		if errs := validation.IsDNS1123Subdomain(parsed.Group); len(errs) > 0 {
+			return errors.Errorf("invalid usageGroupVersion group %q: %s", parsed.Group, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsDNS1035Label(parsed.Version); len(errs) > 0 {
+			return errors.Errorf("invalid usageGroupVersion version %q: %s", parsed.Version, strings.Join(errs, "; "))
+		}
+	}
+	if p := in.SampleProtectPercent; p < 0 || p > 100 {
+		return errors.Errorf("invalid sampleProtectPercent %d: must be between 0 and 100", p)
+	}
+	if in.XRUseResourceSelector && len(in.XRSelectorLabels) == 0 {
+		return errors.New("xrSelectorLabels must not be empty if xrUseResourceSelector is set")
+	}
+	for _, l := range in.XRSelectorLabels {
+		if errs := validation.IsQualifiedName(l); len(errs) > 0 {
+			return errors.Errorf("invalid xrSelectorLabels entry %q: %s", l, strings.Join(errs, "; "))
+		}
+	}
+	if t := in.ProtectIfConditionTrue; t != "" {
+		if errs := validation.IsQualifiedName(t); len(errs) > 0 {
+			return errors.Errorf("invalid protectIfConditionTrue %q: %s", t, strings.Join(errs, "; "))
+		}
+	}
+	if t := in.SuccessConditionType; t != "" {
+		if errs := validation.IsQualifiedName(t); len(errs) > 0 {
+			return errors.Errorf("invalid successConditionType %q: %s", t, strings.Join(errs, "; "))
+		}
+	}
+	if a := in.PriorityAnnotation; a != "" {
+		if errs := validation.IsQualifiedName(a); len(errs) > 0 {
+			return errors.Errorf("invalid priorityAnnotation %q: %s", a, strings.Join(errs, "; "))
+		}
+	}
+	if a := in.CreationTimeAnnotation; a != "" {
+		if errs := validation.IsQualifiedName(a); len(errs) > 0 {
+			return errors.Errorf("invalid creationTimeAnnotation %q: %s", a, strings.Join(errs, "; "))
+		}
+	}
+	if a := in.OptOutAnnotation; a != "" {
+		if errs := validation.IsQualifiedName(a); len(errs) > 0 {
+			return errors.Errorf("invalid optOutAnnotation %q: %s", a, strings.Join(errs, "; "))
+		}
+	}
+	if l := in.SharedProtectionGroupLabel; l != "" {
+		if errs := validation.IsQualifiedName(l); len(errs) > 0 {
+			return errors.Errorf("invalid sharedProtectionGroupLabel %q: %s", l, strings.Join(errs, "; "))
+		}
+	}
+	if r := in.SuccessConditionReason; r != "" && !conditionReasonPattern.MatchString(r) {
+		return errors.Errorf("invalid successConditionReason %q: must start with a letter and contain only letters, digits, '_', ',' and ':'", r)
+	}
+	for key, reason := range in.SubresourceReasons {
+		if errs := validation.IsDNS1123Label(key); len(errs) > 0 {
+			return errors.Errorf("invalid subresourceReasons key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if reason == "" {
+			return errors.Errorf("subresourceReasons entry %q must have a reason", key)
+		}
+	}
+	names := map[string]bool{}
+	for _, sel := range in.RequiredSelectors {
+		switch {
+		case sel.Name == "":
+			return errors.New("requiredSelectors entries must have a name")
+		case sel.Name == RequirementsNamePolicy || sel.Name == RequirementsNameWatchedResource || sel.Name == RequirementsNameProtectionPolicies || strings.HasPrefix(sel.Name, RequirementsNameCrossComposition):
+			return errors.Errorf("requiredSelectors entry name %q is reserved", sel.Name)
+		case names[sel.Name]:
+			return errors.Errorf("duplicate requiredSelectors entry name %q", sel.Name)
+		case sel.APIVersion == "" || sel.Kind == "":
+			return errors.Errorf("requiredSelectors entry %q must have an apiVersion and kind", sel.Name)
+		case len(sel.MatchLabels) == 0:
+			return errors.Errorf("requiredSelectors entry %q must have matchLabels", sel.Name)
+		}
+		names[sel.Name] = true
+	}
+	return nil
+}
+
+// ValidateUsage checks that a generated Usage is well-formed before it is sent
+// to Crossplane.
+func ValidateUsage(u *composed.Unstructured) error {
+	if u == nil || u.Object == nil {
+		return errors.New("usage is empty")
+	}
+	gv := u.GetAPIVersion()
+	if gv == "" || !strings.Contains(gv, "/") {
+		return errors.Errorf("invalid apiVersion %q", gv)
+	}
+	switch u.GetKind() {
+	case protectionv1beta1.UsageKind, protectionv1beta1.ClusterUsageKind:
+	default:
+		return errors.Errorf("invalid kind %q", u.GetKind())
+	}
+	if u.GetName() == "" {
+		return errors.New("metadata.name is required")
+	}
+	required := [][]string{{"spec", "of", "apiVersion"}, {"spec", "of", "kind"}, {"spec", "reason"}}
+	// A Usage may select the resource by its labels instead of its name.
+	if ml, _, _ := unstructured.NestedStringMap(u.Object, "spec", "of", "resourceSelector", "matchLabels"); len(ml) == 0 {
+		required = slices.Insert(required, 2, []string{"spec", "of", "resourceRef", "name"})
+	}
+	for _, path := range required {
+		v, _, err := unstructured.NestedString(u.Object, path...)
+		if err != nil || v == "" {
+			return errors.Errorf("%s is required", strings.Join(path, "."))
+		}
+	}
+	return nil
+}
+
+// ValidateUsages removes any Usages that fail validation from the supplied map
+// and returns the names of the Usages that were dropped along with the reason.
+func ValidateUsages(usages map[resource.Name]*resource.DesiredComposed) []SkippedResource {
+	var invalid []SkippedResource
+	for name, u := range usages {
+		if u == nil {
+			delete(usages, name)
+			invalid = append(invalid, SkippedResource{Name: name, Reason: "usage is empty"})
+			continue
+		}
+		if err := ValidateUsage(u.Resource); err != nil {
+			delete(usages, name)
+			invalid = append(invalid, SkippedResource{Name: name, Reason: err.Error()})
+		}
+	}
+	slices.SortFunc(invalid, func(a, b SkippedResource) int { return strings.Compare(string(a.Name), string(b.Name)) })
+	return invalid
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"testing"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+func TestValidateUsages(t *testing.T) {
+	usage := func(obj map[string]any) *resource.DesiredComposed {
+		return &resource.DesiredComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}}
+	}
+	valid := func() map[string]any {
+		return map[string]any{
+			"apiVersion": ProtectionGroupVersion,
+			"kind":       "ClusterUsage",
+			"metadata": map[string]any{
+				"name": "testresource-test-resource-bcd955-fn-protection",
+			},
+			"spec": map[string]any{
+				"of": map[string]any{
+					"apiVersion": "test.crossplane.io/v1",
+					"kind":       "TestResource",
+					"resourceRef": map[string]any{
+						"name": "test-resource",
+					},
+				},
+				"reason": ProtectionReasonLabel,
+			},
+		}
+	}
+
+	type args struct {
+		usages map[resource.Name]*resource.DesiredComposed
+	}
+	type want struct {
+		invalid []SkippedResource
+		kept    []resource.Name
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ValidUsage": {
+			reason: "A well-formed Usage should be kept",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{"valid": usage(valid())},
+			},
+			want: want{
+				kept: []resource.Name{"valid"},
+			},
+		},
+		"MissingAPIVersion": {
+			reason: "A Usage without an apiVersion should be dropped",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"no-api-version": usage(func() map[string]any { u := valid(); delete(u, "apiVersion"); return u }()),
+				},
+			},
+			want: want{
+				invalid: []SkippedResource{{Name: "no-api-version", Reason: `invalid apiVersion ""`}},
+			},
+		},
+		"InvalidKind": {
+			reason: "A Usage with an unknown kind should be dropped",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"bad-kind": usage(func() map[string]any { u := valid(); u["kind"] = "ConfigMap"; return u }()),
+				},
+			},
+			want: want{
+				invalid: []SkippedResource{{Name: "bad-kind", Reason: `invalid kind "ConfigMap"`}},
+			},
+		},
+		"MissingResourceRefName": {
+			reason: "A Usage without a resourceRef name should be dropped",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"no-ref": usage(func() map[string]any {
+						u := valid()
+						u["spec"].(map[string]any)["of"].(map[string]any)["resourceRef"] = map[string]any{"name": ""}
+						return u
+					}()),
+				},
+			},
+			want: want{
+				invalid: []SkippedResource{{Name: "no-ref", Reason: "spec.of.resourceRef.name is required"}},
+			},
+		},
+		"ResourceSelector": {
+			reason: "A Usage selecting the resource by its labels should be kept without a resourceRef",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"selector": usage(func() map[string]any {
+						u := valid()
+						of := u["spec"].(map[string]any)["of"].(map[string]any)
+						delete(of, "resourceRef")
+						of["resourceSelector"] = map[string]any{"matchLabels": map[string]any{"team": "a"}}
+						return u
+					}()),
+				},
+			},
+			want: want{
+				kept: []resource.Name{"selector"},
+			},
+		},
+		"MissingReason": {
+			reason: "A Usage without a reason should be dropped while valid Usages are kept",
+			args: args{
+				usages: map[resource.Name]*resource.DesiredComposed{
+					"no-reason": usage(func() map[string]any {
+						u := valid()
+						delete(u["spec"].(map[string]any), "reason")
+						return u
+					}()),
+					"valid": usage(valid()),
+				},
+			},
+			want: want{
+				invalid: []SkippedResource{{Name: "no-reason", Reason: "spec.reason is required"}},
+				kept:    []resource.Name{"valid"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			invalid := ValidateUsages(tc.args.usages)
+
+			if diff := cmp.Diff(tc.want.invalid, invalid); diff != "" {
+				t.Errorf("%s\nValidateUsages(...): -want invalid, +got invalid:\n%s", tc.reason, diff)
+			}
+
+			var kept []resource.Name
+			for n := range tc.args.usages {
+				kept = append(kept, n)
+			}
+			if diff := cmp.Diff(tc.want.kept, kept); diff != "" {
+				t.Errorf("%s\nValidateUsages(...): -want kept, +got kept:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	type args struct {
+		in *v1beta1.Input
+	}
+	type want struct {
+		err string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"EmptyInput": {
+			reason: "An empty Input should be valid",
+			args:   args{in: &v1beta1.Input{}},
+			want:   want{},
+		},
+		"ValidRedactPattern": {
+			reason: "A valid redaction pattern should be accepted",
+			args:   args{in: &v1beta1.Input{RedactReasonPatterns: []string{`SEC-[0-9]+`}}},
+			want:   want{},
+		},
+		"InvalidRedactPattern": {
+			reason: "An invalid redaction pattern should be rejected",
+			args:   args{in: &v1beta1.Input{RedactReasonPatterns: []string{`SEC-[0-9+`}}},
+			want:   want{err: "invalid redactReasonPatterns entry \"SEC-[0-9+\": error parsing regexp: missing closing ]: `[0-9+`"},
+		},
+		"InvalidExternalNameRegex": {
+			reason: "An invalid external name pattern should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectExternalNameRegex: `^prod-(`}},
+			want:   want{err: "invalid protectExternalNameRegex: error parsing regexp: missing closing ): `^prod-(`"},
+		},
+		"InvalidFieldMatchRegex": {
+			reason: "An invalid protectIfFieldMatches regex should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectIfFieldMatches: []v1beta1.FieldMatch{{FieldPath: "spec.forProvider.ami", Regex: `ami-(`}}}},
+			want:   want{err: "invalid protectIfFieldMatches regex for \"spec.forProvider.ami\": error parsing regexp: missing closing ): `ami-(`"},
+		},
+		"EmptyRequireAllSelector": {
+			reason: "An empty requireAllSelectors entry would match every resource and should be rejected",
+			args:   args{in: &v1beta1.Input{RequireAllSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"tier": "critical"}}, {}}}},
+			want:   want{err: "requireAllSelectors entry 1 must have matchLabels or matchExpressions"},
+		},
+		"InvalidRequireAllSelector": {
+			reason: "A requireAllSelectors entry that cannot be compiled should be rejected",
+			args: args{in: &v1beta1.Input{RequireAllSelectors: []metav1.LabelSelector{
+				{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Like", Values: []string{"critical"}}}},
+			}}},
+			want: want{err: `invalid requireAllSelectors entry 0: "Like" is not a valid label selector operator`},
+		},
+		"ProtectionRuleWithoutSelector": {
+			reason: "A protection rule without kinds or labels would match every resource and should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectionRules: []v1beta1.ProtectionRule{{Reason: "everything"}}}},
+			want:   want{err: "protectionRules entry 0 must have kinds or matchLabels"},
+		},
+		"IncompleteCrossCompositionRef": {
+			reason: "A crossCompositionRefs entry without a kind should be rejected",
+			args:   args{in: &v1beta1.Input{CrossCompositionRefs: []v1beta1.CrossCompositionRef{{FieldPath: "spec.forProvider.vpcId", APIVersion: "ec2.aws.upbound.io/v1beta1"}}}},
+			want:   want{err: "crossCompositionRefs entry 0 must have fieldPath, apiVersion and kind"},
+		},
+		"CrossCompositionRefExternalNameWithoutLabels": {
+			reason: "A crossCompositionRefs entry identified by external name without matchLabels should be rejected",
+			args: args{in: &v1beta1.Input{CrossCompositionRefs: []v1beta1.CrossCompositionRef{{
+				FieldPath:  "spec.forProvider.vpcId",
+				APIVersion: "ec2.aws.upbound.io/v1beta1",
+				Kind:       "VPC",
+				IdentifyBy: v1beta1.CrossCompositionIdentityExternalName,
+			}}}},
+			want: want{err: "crossCompositionRefs entry 0 must have matchLabels to identify resources by external name"},
+		},
+		"InvalidMatchAnnotationRegex": {
+			reason: "A matchAnnotationRegex entry with an invalid pattern should be rejected",
+			args:   args{in: &v1beta1.Input{MatchAnnotationRegex: []v1beta1.AnnotationRegex{{Key: "environment", Pattern: "(prod"}}}},
+			want:   want{err: "invalid matchAnnotationRegex pattern for \"environment\": error parsing regexp: missing closing ): `(prod`"},
+		},
+		"InvalidPriorityAnnotation": {
+			reason: "A priorityAnnotation that is not a qualified name should be rejected",
+			args:   args{in: &v1beta1.Input{PriorityAnnotation: "not a name"}},
+			want:   want{err: `invalid priorityAnnotation "not a name": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"SampleProtectPercentTooHigh": {
+			reason: "A sampleProtectPercent above 100 should be rejected",
+			args:   args{in: &v1beta1.Input{SampleProtectPercent: 101}},
+			want:   want{err: "invalid sampleProtectPercent 101: must be between 0 and 100"},
+		},
+		"XRUseResourceSelectorWithoutLabels": {
+			reason: "xrUseResourceSelector without xrSelectorLabels should be rejected",
+			args:   args{in: &v1beta1.Input{XRUseResourceSelector: true}},
+			want:   want{err: "xrSelectorLabels must not be empty if xrUseResourceSelector is set"},
+		},
+		"InvalidXRSelectorLabel": {
+			reason: "An xrSelectorLabels entry that is not a qualified name should be rejected",
+			args:   args{in: &v1beta1.Input{XRUseResourceSelector: true, XRSelectorLabels: []string{"not a label"}}},
+			want:   want{err: `invalid xrSelectorLabels entry "not a label": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"InvalidProtectIfConditionTrue": {
+			reason: "A protectIfConditionTrue that is not a qualified name should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectIfConditionTrue: "not a condition"}},
+			want:   want{err: `invalid protectIfConditionTrue "not a condition": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"InvalidCreationTimeAnnotation": {
+			reason: "A creationTimeAnnotation that is not a qualified name should be rejected",
+			args:   args{in: &v1beta1.Input{CreationTimeAnnotation: "not a name"}},
+			want:   want{err: `invalid creationTimeAnnotation "not a name": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"ValidUsageGroupVersion": {
+			reason: "A well-formed usageGroupVersion should be accepted",
+			args:   args{in: &v1beta1.Input{UsageGroupVersion: "protection.example.org/v1alpha1"}},
+			want:   want{},
+		},
+		"UsageGroupVersionWithoutGroup": {
+			reason: "A usageGroupVersion without a group should be rejected",
+			args:   args{in: &v1beta1.Input{UsageGroupVersion: "v1beta1"}},
+			want:   want{err: `invalid usageGroupVersion "v1beta1": must be of the form group/version`},
+		},
+		"InvalidUsageGroupVersionGroup": {
+			reason: "A usageGroupVersion whose group is not a DNS subdomain should be rejected",
+			args:   args{in: &v1beta1.Input{UsageGroupVersion: "Protection/v1beta1"}},
+			want:   want{err: `invalid usageGroupVersion group "Protection": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`},
+		},
+		"InvalidUsageGroupVersionVersion": {
+			reason: "A usageGroupVersion whose version is not a DNS label should be rejected",
+			args:   args{in: &v1beta1.Input{UsageGroupVersion: "protection.example.org/V1"}},
+			want:   want{err: `invalid usageGroupVersion version "V1": a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')`},
+		},
+		"InvalidProtectProvider": {
+			reason: "A protectProviders entry that is not a DNS subdomain should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectProviders: []string{"AWS"}}},
+			want:   want{err: `invalid protectProviders entry "AWS": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`},
+		},
+		"InvalidProtectionTTL": {
+			reason: "A protectionTTL that is not a duration should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectionTTL: "3 days"}},
+			want:   want{err: `invalid protectionTTL: time: unknown unit " days" in duration "3 days"`},
+		},
+		"InvalidDecisionWebhookURL": {
+			reason: "A decisionWebhookURL that is not an http or https URL should be rejected",
+			args:   args{in: &v1beta1.Input{DecisionWebhookURL: "ftp://policy.example.org"}},
+			want:   want{err: `invalid decisionWebhookURL "ftp://policy.example.org": must be an absolute http or https URL`},
+		},
+		"NegativeDecisionWebhookTimeout": {
+			reason: "A decisionWebhookTimeout that is not positive should be rejected",
+			args:   args{in: &v1beta1.Input{DecisionWebhookURL: "https://policy.example.org/decide", DecisionWebhookTimeout: "0s"}},
+			want:   want{err: `invalid decisionWebhookTimeout "0s": must be positive`},
+		},
+		"NegativeProtectionTTL": {
+			reason: "A protectionTTL that is not positive should be rejected",
+			args:   args{in: &v1beta1.Input{ProtectionTTL: "-1h"}},
+			want:   want{err: `invalid protectionTTL "-1h": must be positive`},
+		},
+		"InvalidSubresourceKey": {
+			reason: "A subresourceReasons key that is not a DNS label should be rejected",
+			args:   args{in: &v1beta1.Input{SubresourceReasons: map[string]string{"Storage": "holds customer data"}}},
+			want:   want{err: `invalid subresourceReasons key "Storage": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+		},
+		"EmptySubresourceReason": {
+			reason: "A subresourceReasons entry without a reason should be rejected",
+			args:   args{in: &v1beta1.Input{SubresourceReasons: map[string]string{"storage": ""}}},
+			want:   want{err: `subresourceReasons entry "storage" must have a reason`},
+		},
+		"InvalidOptOutAnnotation": {
+			reason: "An optOutAnnotation that is not a valid annotation key should be rejected",
+			args:   args{in: &v1beta1.Input{OptOutAnnotation: "skip protection"}},
+			want:   want{err: `invalid optOutAnnotation "skip protection": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"InvalidSharedProtectionGroupLabel": {
+			reason: "A sharedProtectionGroupLabel that is not a valid label key should be rejected",
+			args:   args{in: &v1beta1.Input{SharedProtectionGroupLabel: "example.org/"}},
+			want:   want{err: `invalid sharedProtectionGroupLabel "example.org/": name part must be non-empty; name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`},
+		},
+		"ValidSuccessCondition": {
+			reason: "A valid success condition type and reason should be accepted",
+			args:   args{in: &v1beta1.Input{SuccessConditionType: "protection.example.org/Ready", SuccessConditionReason: "Protected"}},
+			want:   want{},
+		},
+		"InvalidSuccessConditionReason": {
+			reason: "A success condition reason with invalid characters should be rejected",
+			args:   args{in: &v1beta1.Input{SuccessConditionReason: "Input Accepted"}},
+			want:   want{err: `invalid successConditionReason "Input Accepted": must start with a letter and contain only letters, digits, '_', ',' and ':'`},
+		},
+		"ValidRequiredSelectors": {
+			reason: "Required selectors with unique names should be accepted",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: "a", APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+				{Name: "b", APIVersion: "v1", Kind: "Secret", Namespace: "default", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{},
+		},
+		"UnnamedRequiredSelector": {
+			reason: "A required selector without a name should be rejected",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: "requiredSelectors entries must have a name"},
+		},
+		"ReservedRequiredSelectorName": {
+			reason: "A required selector must not use a requirement name reserved by the function",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: RequirementsNamePolicy, APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `requiredSelectors entry name "protection.fn.crossplane.io/policy" is reserved`},
+		},
+		"ReservedWatchedResourceName": {
+			reason: "A required selector must not use the requirement name of the watched resource",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: RequirementsNameWatchedResource, APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `requiredSelectors entry name "ops.crossplane.io/watched-resource" is reserved`},
+		},
+		"ReservedProtectionPoliciesName": {
+			reason: "A required selector must not use the requirement name of the ProtectionPolicies",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: RequirementsNameProtectionPolicies, APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `requiredSelectors entry name "protection.fn.crossplane.io/protection-policies" is reserved`},
+		},
+		"ReservedCrossCompositionName": {
+			reason: "A required selector must not use a name with the prefix of the cross-composition requirements",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: CrossCompositionRequirement(0), APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `requiredSelectors entry name "protection.fn.crossplane.io/cross-composition-0" is reserved`},
+		},
+		"DuplicateRequiredSelectorName": {
+			reason: "Required selector names should be unique",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: "a", APIVersion: "v1", Kind: "ConfigMap", MatchLabels: map[string]string{"tier": "critical"}},
+				{Name: "a", APIVersion: "v1", Kind: "Secret", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `duplicate requiredSelectors entry name "a"`},
+		},
+		"RequiredSelectorWithoutKind": {
+			reason: "A required selector without a kind should be rejected",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: "a", APIVersion: "v1", MatchLabels: map[string]string{"tier": "critical"}},
+			}}},
+			want: want{err: `requiredSelectors entry "a" must have an apiVersion and kind`},
+		},
+		"RequiredSelectorWithoutLabels": {
+			reason: "A required selector without labels would select every resource of its kind and should be rejected",
+			args: args{in: &v1beta1.Input{RequiredSelectors: []v1beta1.RequiredSelector{
+				{Name: "a", APIVersion: "v1", Kind: "ConfigMap"},
+			}}},
+			want: want{err: `requiredSelectors entry "a" must have matchLabels`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateInput(tc.args.in)
+
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.want.err, got); diff != "" {
+				t.Errorf("%s\nValidateInput(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/errors"
+)
+
+// DefaultDecisionWebhookTimeout is how long the decision webhook calls of a run
+// may take if the Input does not configure a timeout.
+const DefaultDecisionWebhookTimeout = 5 * time.Second
+
+// maxDecisionResponseSize is the maximum size of a decision webhook response.
+const maxDecisionResponseSize = 64 * 1024
+
+// DecisionRequest is POSTed to the decision webhook for each candidate
+// resource.
+type DecisionRequest struct {
+	// Resource is the observed resource.
+	Resource map[string]any `json:"resource"`
+
+	// Composite references the composite the resource is composed by.
+	Composite DecisionComposite `json:"composite"`
+}
+
+// DecisionComposite references the composite of a DecisionRequest.
+type DecisionComposite struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// DecisionResponse is the decision webhook's response.
+type DecisionResponse struct {
+	// Protect is true if the resource must be protected.
+	Protect bool `json:"protect"`
+
+	// Reason is recorded on the Usage protecting the resource. The function
+	// generates a reason if it is empty.
+	Reason string `json:"reason,omitempty"`
+}
+
+// DecisionContext returns a context that is done once the Input's
+// DecisionWebhookTimeout has passed. It bounds all decision webhook calls of a
+// run, so a slow webhook delays the function at most once rather than once
+// per resource.
+func DecisionContext(ctx context.Context, in *v1beta1.Input) (context.Context, context.CancelFunc) {
+	timeout := DefaultDecisionWebhookTimeout
+	if d, err := time.ParseDuration(in.DecisionWebhookTimeout); err == nil && d > 0 {
+		timeout = d
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Decide POSTs the supplied resource to the Input's DecisionWebhookURL and
+// returns the webhook's decision. The request is bounded by the supplied
+// context, see DecisionContext.
+func Decide(ctx context.Context, client *http.Client, u, xr *unstructured.Unstructured, in *v1beta1.Input) (DecisionResponse, error) {
+	dr := DecisionRequest{Resource: u.Object}
+	if xr != nil {
+		dr.Composite = DecisionComposite{APIVersion: xr.GetAPIVersion(), Kind: xr.GetKind(), Name: xr.GetName(), Namespace: xr.GetNamespace()}
+	}
+	body, err := json.Marshal(dr)
+	if err != nil {
+		return DecisionResponse{}, errors.Wrap(err, "cannot encode decision request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.DecisionWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return DecisionResponse{}, errors.Wrap(err, "cannot create decision request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return DecisionResponse{}, errors.Wrap(err, "cannot call decision webhook")
+	}
+	defer rsp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+	if rsp.StatusCode != http.StatusOK {
+		return DecisionResponse{}, errors.Errorf("decision webhook returned status %d", rsp.StatusCode)
+	}
+	d := DecisionResponse{}
+	if err := json.NewDecoder(io.LimitReader(rsp.Body, maxDecisionResponseSize)).Decode(&d); err != nil {
+		return DecisionResponse{}, errors.Wrap(err, "cannot decode decision webhook response")
+	}
+	return d, nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1beta1 "github.com/crossplane-contrib/function-deletion-protection/input/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/function-sdk-go/logging"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+// stubWebhook returns a decision webhook that protects resources named
+// my-db and records the requests it receives.
+func stubWebhook(t *testing.T, got *[]DecisionRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dr := DecisionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&dr); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if got != nil {
+			*got = append(*got, dr)
+		}
+		u := unstructured.Unstructured{Object: dr.Resource}
+		_ = json.NewEncoder(w).Encode(DecisionResponse{Protect: u.GetName() == "my-db", Reason: "required by governance"})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDecide(t *testing.T) {
+	protect := stubWebhook(t, nil)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	t.Cleanup(invalid.Close)
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		// The request context is only cancelled once the body has been read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	named := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("test.crossplane.io/v1")
+		u.SetKind("TestComposed")
+		u.SetName(name)
+		return u
+	}
+
+	type args struct {
+		u  *unstructured.Unstructured
+		in *v1beta1.Input
+	}
+	type want struct {
+		d   DecisionResponse
+		err bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Protect": {
+			reason: "The webhook's decision to protect should be returned",
+			args:   args{u: named("my-db"), in: &v1beta1.Input{DecisionWebhookURL: protect.URL}},
+			want:   want{d: DecisionResponse{Protect: true, Reason: "required by governance"}},
+		},
+		"Skip": {
+			reason: "The webhook's decision not to protect should be returned",
+			args:   args{u: named("my-cache"), in: &v1beta1.Input{DecisionWebhookURL: protect.URL}},
+			want:   want{d: DecisionResponse{Reason: "required by governance"}},
+		},
+		"ErrorStatus": {
+			reason: "A webhook responding with an error status should return an error",
+			args:   args{u: named("my-db"), in: &v1beta1.Input{DecisionWebhookURL: failing.URL}},
+			want:   want{err: true},
+		},
+		"InvalidResponse": {
+			reason: "A webhook responding with invalid JSON should return an error",
+			args:   args{u: named("my-db"), in: &v1beta1.Input{DecisionWebhookURL: invalid.URL}},
+			want:   want{err: true},
+		},
+		"Timeout": {
+			reason: "A webhook not responding within decisionWebhookTimeout should return an error",
+			args:   args{u: named("my-db"), in: &v1beta1.Input{DecisionWebhookURL: slow.URL, DecisionWebhookTimeout: "10ms"}},
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := DecisionContext(context.Background(), tc.args.in)
+			defer cancel()
+			d, err := Decide(ctx, http.DefaultClient, tc.args.u, nil, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.d, d); diff != "" {
+				t.Errorf("%s\nDecide(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			if (err != nil) != tc.want.err {
+				t.Errorf("%s\nDecide(...): want error %t, got %v", tc.reason, tc.want.err, err)
+			}
+		})
+	}
+}
+
+func TestDecideRequest(t *testing.T) {
+	var got []DecisionRequest
+	srv := stubWebhook(t, &got)
+
+	xr := composite.New()
+	xr.SetAPIVersion("test.crossplane.io/v1")
+	xr.SetKind("TestXR")
+	xr.SetName("my-xr")
+	xr.SetNamespace("team-a")
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "TestComposed",
+		"metadata":   map[string]any{"name": "my-db", "namespace": "team-a"},
+	}}
+
+	if _, err := Decide(context.Background(), http.DefaultClient, u, &xr.Unstructured, &v1beta1.Input{DecisionWebhookURL: srv.URL}); err != nil {
+		t.Fatalf("Decide(...): unexpected error: %v", err)
+	}
+
+	want := []DecisionRequest{{
+		Resource:  u.Object,
+		Composite: DecisionComposite{APIVersion: "test.crossplane.io/v1", Kind: "TestXR", Name: "my-xr", Namespace: "team-a"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decide(...): -want request, +got request:\n%s", diff)
+	}
+}
+
+func TestProtectComposedResourcesDecisionWebhook(t *testing.T) {
+	protect := stubWebhook(t, nil)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	cd := func(obj map[string]any) *composed.Unstructured {
+		return &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: obj}}
+	}
+	resources := func() (map[resource.Name]*resource.DesiredComposed, map[resource.Name]resource.ObservedComposed) {
+		desired := map[resource.Name]*resource.DesiredComposed{}
+		observed := map[resource.Name]resource.ObservedComposed{}
+		for _, n := range []string{"db", "cache"} {
+			desired[resource.Name(n)] = &resource.DesiredComposed{Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+			})}
+			observed[resource.Name(n)] = resource.ObservedComposed{Resource: cd(map[string]any{
+				"apiVersion": "test.crossplane.io/v1",
+				"kind":       "TestComposed",
+				"metadata":   map[string]any{"name": "my-" + n},
+			})}
+		}
+		return desired, observed
+	}
+
+	type want struct {
+		reasons map[resource.Name]string
+		skipped []SkippedResource
+	}
+
+	cases := map[string]struct {
+		reason string
+		in     *v1beta1.Input
+		want   want
+	}{
+		"WebhookDecides": {
+			reason: "Only resources the webhook decides to protect should be protected, with the webhook's reason",
+			in:     &v1beta1.Input{DecisionWebhookURL: protect.URL},
+			want:   want{reasons: map[resource.Name]string{"db-usage": "required by governance"}},
+		},
+		"FailClosed": {
+			reason: "All resources should be protected if the webhook fails and the failure policy is protect",
+			in:     &v1beta1.Input{DecisionWebhookURL: failing.URL, DecisionWebhookFailurePolicy: v1beta1.WebhookFailurePolicyProtect},
+			want: want{reasons: map[resource.Name]string{
+				"db-usage":    ProtectionReasonWebhook,
+				"cache-usage": ProtectionReasonWebhook,
+			}},
+		},
+		"FailOpen": {
+			reason: "No resources should be protected and all should be reported as skipped if the webhook fails and the failure policy is skip",
+			in:     &v1beta1.Input{DecisionWebhookURL: failing.URL, DecisionWebhookFailurePolicy: v1beta1.WebhookFailurePolicySkip},
+			want: want{
+				reasons: map[resource.Name]string{},
+				skipped: []SkippedResource{
+					{Name: "cache", Reason: SkipReasonWebhookFailed},
+					{Name: "db", Reason: SkipReasonWebhookFailed},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired, observed := resources()
+			f := &Function{log: logging.NewNopLogger(), client: &http.Client{Timeout: time.Second}}
+			dc, skipped, err := f.ProtectComposedResources(context.Background(), nil, desired, observed, tc.in, Matchers{}, nil)
+			if err != nil {
+				t.Fatalf("%s\nf.ProtectComposedResources(...): unexpected error: %v", tc.reason, err)
+			}
+
+			got := map[resource.Name]string{}
+			for n, u := range dc {
+				got[n], _ = u.Resource.GetString("spec.reason")
+			}
+			if diff := cmp.Diff(tc.want.reasons, got); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want reasons, +got reasons:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.skipped, skipped); diff != "" {
+				t.Errorf("%s\nf.ProtectComposedResources(...): -want skipped, +got skipped:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProtectComposedResourcesDecisionWebhookDeadline(t *testing.T) {
+	var requests atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// The request context is only cancelled once the body has been read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	desired := map[resource.Name]*resource.DesiredComposed{}
+	observed := map[resource.Name]resource.ObservedComposed{}
+	for _, n := range []string{"db", "cache", "queue"} {
+		desired[resource.Name(n)] = &resource.DesiredComposed{Resource: composed.New()}
+		o := composed.New()
+		o.SetAPIVersion("test.crossplane.io/v1")
+		o.SetKind("TestComposed")
+		o.SetName("my-" + n)
+		observed[resource.Name(n)] = resource.ObservedComposed{Resource: o}
+	}
+	in := &v1beta1.Input{DecisionWebhookURL: slow.URL, DecisionWebhookTimeout: "50ms", DecisionWebhookFailurePolicy: v1beta1.WebhookFailurePolicySkip}
+
+	f := &Function{log: logging.NewNopLogger()}
+	_, skipped, err := f.ProtectComposedResources(context.Background(), nil, desired, observed, in, Matchers{}, nil)
+	if err != nil {
+		t.Fatalf("f.ProtectComposedResources(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(3, len(skipped)); diff != "" {
+		t.Errorf("f.ProtectComposedResources(...): -want skipped, +got skipped:\n%s", diff)
+	}
+	// Calls after the first one exceeded decisionWebhookTimeout are never
+	// sent, rather than each waiting for the timeout.
+	if diff := cmp.Diff(int32(1), requests.Load()); diff != "" {
+		t.Errorf("f.ProtectComposedResources(...): -want requests, +got requests:\n%s", diff)
+	}
+}